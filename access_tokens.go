@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+const (
+	tokenScopeRead  = "read"
+	tokenScopeWrite = "write"
+
+	// makes leaked tokens easy to recognise, e.g. by secret scanners
+	accessTokenPrefix = "slpat_"
+
+	defaultAccessTokenDays = 30
+	maxAccessTokenDays     = 365
+	maxAccessTokenNameLen  = 100
+)
+
+type tokenScopeKey struct{}
+
+func withTokenScope(ctx context.Context, scope string) context.Context {
+	return context.WithValue(ctx, tokenScopeKey{}, scope)
+}
+
+// tokenScopeFromContext returns the scope of the personal access token the
+// request was made with, or "" for requests made with the session cookies.
+func tokenScopeFromContext(ctx context.Context) string {
+	scope, _ := ctx.Value(tokenScopeKey{}).(string)
+	return scope
+}
+
+// authenticateBearer is middlewareAuth for requests that carry a personal
+// access token instead of cookies. A bad token is answered with 401: scripts
+// have no sign-in page to be sent to.
+func (cfg *apiConfig) authenticateBearer(w http.ResponseWriter, req *http.Request, handler authedHandler) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		respondWithError(w, http.StatusUnauthorized, err.Error(), nil)
+		return
+	}
+
+	pat, err := cfg.Db.UsePersonalAccessToken(req.Context(), auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			respondWithError(w, http.StatusUnauthorized, "invalid, expired or revoked token", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to check token", err)
+		return
+	}
+
+	if pat.Scope != tokenScopeWrite && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		respondWithError(w, http.StatusForbidden, "token is read-only", nil)
+		return
+	}
+
+	handler(w, req.WithContext(withTokenScope(req.Context(), pat.Scope)), pat.UserID)
+}
+
+// middlewareSessionOnly keeps personal access tokens away from the account's
+// own security settings, so a leaked token can't mint more tokens or lock the
+// owner out.
+func (cfg *apiConfig) middlewareSessionOnly(next authedHandler) authedHandler {
+	return func(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+		if tokenScopeFromContext(req.Context()) != "" {
+			respondWithError(w, http.StatusForbidden, "not available with an access token", nil)
+			return
+		}
+		next(w, req, userID)
+	}
+}
+
+type accessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func toAccessTokenResponse(pat database.PersonalAccessToken) accessTokenResponse {
+	resp := accessTokenResponse{
+		ID:        pat.ID,
+		Name:      pat.Name,
+		Scope:     pat.Scope,
+		CreatedAt: pat.CreatedAt,
+		ExpiresAt: pat.ExpiresAt,
+	}
+	if pat.LastUsedAt.Valid {
+		resp.LastUsedAt = &pat.LastUsedAt.Time
+	}
+	return resp
+}
+
+func (cfg *apiConfig) HandleGetAccessTokens(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	tokens, err := cfg.Db.GetUserPersonalAccessTokens(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load tokens", err)
+		return
+	}
+
+	resp := make([]accessTokenResponse, 0, len(tokens))
+	for _, pat := range tokens {
+		resp = append(resp, toAccessTokenResponse(pat))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// HandleCreateAccessToken makes a new personal access token. The token itself
+// is only ever in this response; only its hash is kept.
+func (cfg *apiConfig) HandleCreateAccessToken(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	var body struct {
+		Name          string `json:"name"`
+		Scope         string `json:"scope"`
+		ExpiresInDays *int   `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode token payload", err)
+		return
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAccessTokenNameLen {
+		respondWithError(w, http.StatusBadRequest, "name is required and must be at most 100 characters", nil)
+		return
+	}
+
+	if body.Scope != tokenScopeRead && body.Scope != tokenScopeWrite {
+		respondWithError(w, http.StatusBadRequest, "scope must be read or write", nil)
+		return
+	}
+
+	days := defaultAccessTokenDays
+	if body.ExpiresInDays != nil {
+		days = *body.ExpiresInDays
+	}
+	if days < 1 || days > maxAccessTokenDays {
+		respondWithError(w, http.StatusBadRequest, "expires_in_days must be between 1 and 365", nil)
+		return
+	}
+
+	secret, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create token", err)
+		return
+	}
+	token := accessTokenPrefix + secret
+
+	pat, err := cfg.Db.CreatePersonalAccessToken(req.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: auth.HashToken(token),
+		Scope:     body.Scope,
+		ExpiresAt: time.Now().AddDate(0, 0, days),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create token", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "token.create", pat.ID.String(), "success")
+
+	resp := toAccessTokenResponse(pat)
+	resp.Token = token
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) HandleRevokeAccessToken(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	tokenID, err := uuid.Parse(req.PathValue("token_id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "token not found", nil)
+		return
+	}
+
+	n, err := cfg.Db.RevokePersonalAccessToken(req.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke token", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "token not found", nil)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "token.revoke", tokenID.String(), "success")
+
+	w.WriteHeader(http.StatusNoContent)
+}
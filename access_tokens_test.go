@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+)
+
+var accessTokenCols = []string{"id", "user_id", "name", "token_hash", "scope", "created_at", "expires_at", "last_used_at", "revoked_at"}
+
+// accessTokenFor simulates UsePersonalAccessToken for a single live token.
+func accessTokenFor(userID uuid.UUID, token, scope string) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		if args[0].Value != auth.HashToken(token) {
+			return fakeResult{cols: accessTokenCols}
+		}
+		now := time.Now()
+		return fakeRow(accessTokenCols, uuid.NewString(), userID.String(), "ci", auth.HashToken(token), scope, now, now.Add(time.Hour), now, nil)
+	}
+}
+
+func bearerRequest(method, target, token string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestMiddleware_Bearer_SkipsOriginCheck(t *testing.T) {
+	userID := uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"UsePersonalAccessToken": accessTokenFor(userID, "slpat_good", tokenScopeWrite),
+	})
+
+	var got uuid.UUID
+	h := cfg.middlewareAuth(cfg.middlewareApi(func(w http.ResponseWriter, _ *http.Request, id uuid.UUID) {
+		got = id
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, bearerRequest("POST", "/api/lists/", "slpat_good"))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got != userID {
+		t.Fatalf("want user %s, got %s", userID, got)
+	}
+	if fdb.called("TouchSession") || responseCookie(rr, "sl_auth") != nil {
+		t.Fatalf("bearer requests must not touch cookie sessions")
+	}
+}
+
+func TestMiddleware_Bearer_InvalidToken(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"UsePersonalAccessToken": accessTokenFor(uuid.New(), "slpat_good", tokenScopeWrite),
+	})
+
+	h := cfg.middlewareAuth(func(w http.ResponseWriter, _ *http.Request, _ uuid.UUID) {
+		t.Fatalf("handler must not run")
+	})
+
+	for _, header := range []string{"Bearer slpat_bad", "Basic Zm9vOmJhcg==", "Bearer"} {
+		req := httptest.NewRequest("GET", "/api/lists", nil)
+		req.Header.Set("Authorization", header)
+		req.AddCookie(&http.Cookie{Name: "sl_refresh", Value: "current"})
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%q: want 401, got %d", header, rr.Code)
+		}
+		if rr.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%q: want a WWW-Authenticate challenge", header)
+		}
+	}
+	if fdb.called("GetSessionForRefresh") {
+		t.Fatalf("a bad bearer token must not fall back to the cookies")
+	}
+}
+
+func TestMiddleware_Bearer_ReadScope(t *testing.T) {
+	userID := uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"UsePersonalAccessToken": accessTokenFor(userID, "slpat_ro", tokenScopeRead),
+	})
+
+	h := cfg.middlewareAuth(cfg.middlewareApi(func(w http.ResponseWriter, _ *http.Request, _ uuid.UUID) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, bearerRequest("GET", "/api/lists", "slpat_ro"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("read: want 200, got %d", rr.Code)
+	}
+
+	for _, method := range []string{"POST", "PATCH", "DELETE"} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, bearerRequest(method, "/api/lists/x", "slpat_ro"))
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("%s: want 403, got %d", method, rr.Code)
+		}
+	}
+}
+
+func TestMiddleware_SessionOnly_RejectsBearer(t *testing.T) {
+	userID := uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"UsePersonalAccessToken": accessTokenFor(userID, "slpat_good", tokenScopeWrite),
+	})
+
+	h := cfg.middlewareAuth(cfg.middlewareSessionOnly(func(w http.ResponseWriter, _ *http.Request, _ uuid.UUID) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, bearerRequest("GET", "/api/tokens", "slpat_good"))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("want 403, got %d", rr.Code)
+	}
+}
+
+func TestHandleCreateAccessToken(t *testing.T) {
+	userID := uuid.New()
+	var storedHash, scope driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"CreatePersonalAccessToken": func(args []driver.NamedValue) fakeResult {
+			storedHash, scope = args[2].Value, args[3].Value
+			now := time.Now()
+			return fakeRow(accessTokenCols, uuid.NewString(), userID.String(), args[1].Value, args[2].Value, args[3].Value, now, args[4].Value, nil, nil)
+		},
+		"CreateAuditEvent": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/tokens", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		cfg.HandleCreateAccessToken(rr, req, userID)
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"name": "", "scope": "read"}`,
+		`{"name": "ci", "scope": "admin"}`,
+		`{"name": "ci", "scope": "read", "expires_in_days": 0}`,
+		`{"name": "ci", "scope": "read", "expires_in_days": 1000}`,
+	} {
+		if rr := create(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: want 400, got %d", body, rr.Code)
+		}
+	}
+
+	rr := create(`{"name": " ci ", "scope": "write", "expires_in_days": 7}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status: want 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var got accessTokenResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.HasPrefix(got.Token, accessTokenPrefix) || auth.HashToken(got.Token) != storedHash {
+		t.Fatalf("only the hash of the returned token should be stored, got %q / %v", got.Token, storedHash)
+	}
+	if got.Name != "ci" || scope != tokenScopeWrite {
+		t.Fatalf("unexpected token: %+v", got)
+	}
+	if until := time.Until(got.ExpiresAt); until < 6*24*time.Hour || until > 7*24*time.Hour {
+		t.Fatalf("want the token to expire in 7 days, got %s", until)
+	}
+}
+
+func TestHandleRevokeAccessToken(t *testing.T) {
+	tokenID, userID := uuid.New(), uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"RevokePersonalAccessToken": func(args []driver.NamedValue) fakeResult {
+			if args[0].Value != tokenID.String() || args[1].Value != userID.String() {
+				return fakeResult{affected: 0}
+			}
+			return fakeResult{affected: 1}
+		},
+		"CreateAuditEvent": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+
+	revoke := func(id string, caller uuid.UUID) int {
+		req := httptest.NewRequest("DELETE", "/api/tokens/"+id, nil)
+		req.SetPathValue("token_id", id)
+		rr := httptest.NewRecorder()
+		cfg.HandleRevokeAccessToken(rr, req, caller)
+		return rr.Code
+	}
+
+	if code := revoke(tokenID.String(), uuid.New()); code != http.StatusNotFound {
+		t.Fatalf("another user's token: want 404, got %d", code)
+	}
+	if code := revoke(tokenID.String(), userID); code != http.StatusNoContent {
+		t.Fatalf("own token: want 204, got %d", code)
+	}
+}
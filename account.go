@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+	"github.com/lib/pq"
+)
+
+// how long the link sent to a new email address works
+const emailChangeTTL = 24 * time.Hour
+
+var errWrongPassword = errors.New("current password is incorrect")
+
+type accountResponse struct {
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func toAccountResponse(user database.User) accountResponse {
+	return accountResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+}
+
+func (cfg *apiConfig) HandleGetAccount(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	user, err := cfg.Db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load account", err)
+		return
+	}
+
+	resp := toAccountResponse(user)
+
+	change, err := cfg.Db.GetPendingEmailChange(req.Context(), userID)
+	switch {
+	case err == nil:
+		resp.PendingEmail = change.NewEmail
+	case !errors.Is(err, sql.ErrNoRows):
+		respondWithError(w, http.StatusInternalServerError, "failed to load account", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// HandleUpdateProfile changes the user's name, with the same rules as the
+// signup form.
+func (cfg *apiConfig) HandleUpdateProfile(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	var body struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode profile payload", err)
+		return
+	}
+
+	firstName, lastName, _, err := auth.ValidateInput("profile", map[string]string{
+		"firstName": body.FirstName,
+		"lastName":  body.LastName,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	user, err := cfg.Db.UpdateUserProfile(req.Context(), database.UpdateUserProfileParams{
+		ID:        userID,
+		FirstName: firstName,
+		LastName:  lastName,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update profile", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, toAccountResponse(user))
+}
+
+// checkCurrentPassword makes sure whoever holds the session also knows the
+// password, before it can be used to take over the account. Wrong guesses
+// are throttled like those at the sign-in form.
+func (cfg *apiConfig) checkCurrentPassword(w http.ResponseWriter, req *http.Request, user database.User, pwd string) bool {
+	attempts := cfg.passwordAttempts(user.ID)
+	if wait := attemptWait(req.Context(), attempts); wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return false
+	}
+
+	if err := auth.CheckPasswordHash(user.HashedPassword, pwd); err != nil {
+		cfg.failAttempt(req, user.ID, attempts)
+		respondWithError(w, http.StatusBadRequest, errWrongPassword.Error(), nil)
+		return false
+	}
+
+	resetAttempts(req.Context(), attempts, "password-check")
+	return true
+}
+
+// HandleChangePassword sets a new password. Every other session of the
+// account is signed out, and reset links sent before stop working.
+func (cfg *apiConfig) HandleChangePassword(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode password payload", err)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change password", err)
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, req, user, body.CurrentPassword) {
+		return
+	}
+
+	hashedPwd, err := auth.HashPassword(body.NewPassword)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	err = qtx.UpdateUserPassword(req.Context(), database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPwd,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change password", err)
+		return
+	}
+
+	if err = qtx.UsePasswordResets(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change password", err)
+		return
+	}
+
+	if _, err = qtx.RevokeOtherSessions(req.Context(), database.RevokeOtherSessionsParams{
+		UserID: userID,
+		ID:     sessionIDFromContext(req.Context()),
+	}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change password", err)
+		return
+	}
+
+	if err = qtx.RevokeUserPersonalAccessTokens(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change password", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change password", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "password.change", userID.String(), "success")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleChangeEmail starts moving the account to a new address. Nothing
+// changes until the link sent to that address is opened, see
+// HandleConfirmEmailChange.
+func (cfg *apiConfig) HandleChangeEmail(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode email payload", err)
+		return
+	}
+
+	_, _, email, err := auth.ValidateInput("login", map[string]string{
+		"email": body.Email,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change email address", err)
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, req, user, body.Password) {
+		return
+	}
+
+	if strings.EqualFold(email, user.Email) {
+		respondWithError(w, http.StatusBadRequest, "this is already your email address", nil)
+		return
+	}
+
+	if _, err := cfg.Db.GetUserByEmail(req.Context(), email); err == nil {
+		respondWithError(w, http.StatusConflict, "This email address is already in use", nil)
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "failed to change email address", err)
+		return
+	}
+
+	if err := cfg.startEmailChange(req.Context(), user, email); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change email address", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "email.change.request", userID.String(), "success")
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"pending_email": email})
+}
+
+// startEmailChange mails a confirmation link to the new address. Links sent
+// for earlier requests stop working.
+func (cfg *apiConfig) startEmailChange(ctx context.Context, user database.User, email string) error {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	if err := qtx.UseEmailChanges(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := qtx.CreateEmailChange(ctx, database.CreateEmailChangeParams{
+		UserID:    user.ID,
+		NewEmail:  email,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(emailChangeTTL),
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	link := cfg.Origin + "/confirm-email?token=" + url.QueryEscape(token)
+	cfg.sendMail(mailer.Message{
+		To:      email,
+		Subject: "Confirm your new Smart List email address",
+		Body: fmt.Sprintf(`Hi %s,
+
+You asked to use this address for your Smart List account. To confirm, open
+this link within the next day:
+
+%s
+
+Until then you keep signing in with %s. If it wasn't you, you can ignore
+this email.
+`, user.FirstName, link, user.Email),
+	})
+	return nil
+}
+
+// HandleConfirmEmailChange is where the link sent to a new address lands. It
+// works without a session, as it is often opened on another device. The
+// old address is told about the change.
+func (cfg *apiConfig) HandleConfirmEmailChange(w http.ResponseWriter, req *http.Request) {
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		http.Redirect(w, req, "/login.html?email=invalid", http.StatusSeeOther)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	change, err := qtx.GetEmailChangeForUpdate(req.Context(), auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Redirect(w, req, "/login.html?email=invalid", http.StatusSeeOther)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to change email address", err)
+		return
+	}
+	if change.UsedAt.Valid || time.Now().After(change.ExpiresAt) {
+		http.Redirect(w, req, "/login.html?email=invalid", http.StatusSeeOther)
+		return
+	}
+
+	user, err := qtx.GetUserByID(req.Context(), change.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change email address", err)
+		return
+	}
+
+	// opening the link proves the new address, so it is verified as well
+	err = qtx.UpdateUserEmail(req.Context(), database.UpdateUserEmailParams{
+		ID:    change.UserID,
+		Email: change.NewEmail,
+	})
+	if err != nil {
+		// someone signed up with the address in the meantime
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			http.Redirect(w, req, "/login.html?email=taken", http.StatusSeeOther)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to change email address", err)
+		return
+	}
+
+	if err = qtx.UseEmailChanges(req.Context(), change.UserID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change email address", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change email address", err)
+		return
+	}
+	cfg.audit(req.Context(), req, change.UserID, "email.change", change.UserID.String(), "success")
+
+	cfg.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Smart List email address was changed",
+		Body: fmt.Sprintf(`Hi %s,
+
+The email address of your Smart List account was changed to %s.
+
+If it wasn't you, please get in touch with us right away.
+`, user.FirstName, change.NewEmail),
+	})
+
+	http.Redirect(w, req, "/login.html?email=changed", http.StatusSeeOther)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+)
+
+const (
+	// how long a deleted account can still be restored
+	accountDeletionGrace = 30 * 24 * time.Hour
+
+	accountDeletionInterval = time.Hour
+	accountDeletionBatch    = 100
+)
+
+// HandleDeleteAccount deactivates the account and schedules it for deletion.
+// Every session and access token stops working right away; the account and
+// everything it owns are deleted for good after the grace period, unless the
+// link mailed to the user is opened before.
+func (cfg *apiConfig) HandleDeleteAccount(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	var body struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode account payload", err)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete account", err)
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, req, user, body.Password) {
+		return
+	}
+
+	token, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete account", err)
+		return
+	}
+	deleteAfter := time.Now().Add(accountDeletionGrace)
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	if err = qtx.SetUserActive(req.Context(), database.SetUserActiveParams{ID: userID, IsActive: false}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete account", err)
+		return
+	}
+
+	if err = qtx.ScheduleAccountDeletion(req.Context(), database.ScheduleAccountDeletionParams{
+		UserID:      userID,
+		TokenHash:   auth.HashToken(token),
+		DeleteAfter: deleteAfter,
+	}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete account", err)
+		return
+	}
+
+	if _, err = qtx.RevokeUserSessions(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete account", err)
+		return
+	}
+
+	if err = qtx.RevokeUserPersonalAccessTokens(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete account", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete account", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "account.delete.request", userID.String(), "success")
+
+	link := cfg.Origin + "/restore-account?token=" + url.QueryEscape(token)
+	cfg.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Smart List account will be deleted",
+		Body: fmt.Sprintf(`Hi %s,
+
+Your Smart List account was closed and will be deleted with all its lists on
+%s. Until then you can get it back by opening this link:
+
+%s
+
+After that date it can't be restored.
+`, user.FirstName, deleteAfter.UTC().Format("January 2, 2006"), link),
+	})
+
+	cfg.clearSessionCookies(w)
+	respondWithJSON(w, http.StatusAccepted, map[string]time.Time{"delete_after": deleteAfter})
+}
+
+// HandleRestoreAccount is where the link in the deletion email lands. It
+// reactivates the account if the grace period isn't over yet.
+func (cfg *apiConfig) HandleRestoreAccount(w http.ResponseWriter, req *http.Request) {
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		http.Redirect(w, req, "/login.html?restore=invalid", http.StatusSeeOther)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	deletion, err := qtx.GetAccountDeletionForUpdate(req.Context(), auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Redirect(w, req, "/login.html?restore=invalid", http.StatusSeeOther)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to restore account", err)
+		return
+	}
+	if time.Now().After(deletion.DeleteAfter) {
+		http.Redirect(w, req, "/login.html?restore=invalid", http.StatusSeeOther)
+		return
+	}
+
+	if err = qtx.SetUserActive(req.Context(), database.SetUserActiveParams{ID: deletion.UserID, IsActive: true}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to restore account", err)
+		return
+	}
+
+	if err = qtx.CancelAccountDeletion(req.Context(), deletion.UserID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to restore account", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to restore account", err)
+		return
+	}
+	cfg.audit(req.Context(), req, deletion.UserID, "account.restore", deletion.UserID.String(), "success")
+
+	http.Redirect(w, req, "/login.html?restore=1", http.StatusSeeOther)
+}
+
+// runAccountDeletion deletes accounts whose grace period is over, every
+// interval until ctx is done.
+func (cfg *apiConfig) runAccountDeletion(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := cfg.deleteDueAccounts(ctx, time.Now()); err != nil {
+			log.Printf("account deletion: %v", err)
+		} else if deleted > 0 {
+			log.Printf("account deletion: deleted %d accounts", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteDueAccounts deletes the users whose grace period ended before now.
+// Their lists, sessions and everything else go with them by cascade; audit
+// events stay, without an actor, see deleteAccount.
+func (cfg *apiConfig) deleteDueAccounts(ctx context.Context, now time.Time) (int, error) {
+	ids, err := cfg.Db.GetDueAccountDeletions(ctx, database.GetDueAccountDeletionsParams{
+		Now:      now,
+		MaxUsers: accountDeletionBatch,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		ok, err := cfg.deleteAccount(ctx, id)
+		if err != nil {
+			return deleted, err
+		}
+		if !ok {
+			// reactivated some other way in the meantime
+			if err := cfg.Db.CancelAccountDeletion(ctx, id); err != nil {
+				return deleted, err
+			}
+			continue
+		}
+		deleted++
+
+		if err := cfg.Db.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+			Action:  "account.delete",
+			Target:  id.String(),
+			Outcome: "success",
+		}); err != nil {
+			log.Printf("audit: failed to record account.delete %s: %v", id, err)
+		}
+	}
+
+	return deleted, nil
+}
+
+// deleteAccount deletes an account that is still deactivated. Its own audit
+// events lose the client address, user agent and any email address they were
+// recorded with; what happened, and when, is kept.
+func (cfg *apiConfig) deleteAccount(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	err = qtx.RedactUserAuditEvents(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return false, err
+	}
+
+	n, err := qtx.DeleteInactiveUser(ctx, userID)
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
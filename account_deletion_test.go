@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+)
+
+var accountDeletionCols = []string{"user_id", "token_hash", "requested_at", "delete_after"}
+
+func accountDeletion(userID uuid.UUID, token string, deleteAfter time.Time) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		if args[0].Value != auth.HashToken(token) {
+			return fakeResult{cols: accountDeletionCols}
+		}
+		return fakeRow(accountDeletionCols, userID.String(), auth.HashToken(token), time.Now(), deleteAfter)
+	}
+}
+
+func TestHandleDeleteAccount(t *testing.T) {
+	userID := uuid.New()
+	var active driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID": userWithPassword(t),
+		"SetUserActive": func(args []driver.NamedValue) fakeResult {
+			active = args[1].Value
+			return fakeResult{affected: 1}
+		},
+		"ScheduleAccountDeletion":        func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeUserSessions":             func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeUserPersonalAccessTokens": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateAuditEvent":               func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+	sent := make(chanMailer, 1)
+	cfg.Mailer = sent
+
+	rr := httptest.NewRecorder()
+	cfg.HandleDeleteAccount(rr, jsonRequest("DELETE", "/api/account", `{"password":"correct horse"}`), userID)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status: want 202, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if active != false {
+		t.Fatalf("the account should be deactivated, got %v", active)
+	}
+	if !fdb.called("RevokeUserSessions") || !fdb.called("RevokeUserPersonalAccessTokens") {
+		t.Fatalf("sessions and access tokens should stop working")
+	}
+	if c := responseCookie(rr, "sl_auth"); c == nil || c.MaxAge >= 0 {
+		t.Fatalf("the session cookies should be cleared")
+	}
+
+	select {
+	case msg := <-sent:
+		if msg.To != "ana@example.com" {
+			t.Fatalf("the restore link should go to the account, got %s", msg.To)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no email sent")
+	}
+}
+
+func TestHandleDeleteAccount_WrongPassword(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID": userWithPassword(t),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleDeleteAccount(rr, jsonRequest("DELETE", "/api/account", `{"password":"wrong"}`), uuid.New())
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+	if fdb.called("SetUserActive") || fdb.called("ScheduleAccountDeletion") {
+		t.Fatalf("a wrong password must change nothing")
+	}
+}
+
+func TestHandleRestoreAccount(t *testing.T) {
+	userID := uuid.New()
+	var active driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetAccountDeletionForUpdate": accountDeletion(userID, "tok", time.Now().Add(time.Hour)),
+		"SetUserActive": func(args []driver.NamedValue) fakeResult {
+			active = args[1].Value
+			return fakeResult{affected: 1}
+		},
+		"CancelAccountDeletion": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateAuditEvent":      func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleRestoreAccount(rr, httptest.NewRequest("GET", "/restore-account?token=tok", nil))
+
+	if rr.Header().Get("Location") != "/login.html?restore=1" {
+		t.Fatalf("want the restored page, got %d %q (%s)", rr.Code, rr.Header().Get("Location"), rr.Body.String())
+	}
+	if active != true || !fdb.called("CancelAccountDeletion") {
+		t.Fatalf("the account should be active again and no longer scheduled")
+	}
+}
+
+func TestHandleRestoreAccount_Unusable(t *testing.T) {
+	cases := map[string]fakeHandler{
+		"unknown":  accountDeletion(uuid.New(), "other", time.Now().Add(time.Hour)),
+		"too late": accountDeletion(uuid.New(), "tok", time.Now().Add(-time.Minute)),
+	}
+
+	for name, handler := range cases {
+		cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+			"GetAccountDeletionForUpdate": handler,
+		})
+
+		rr := httptest.NewRecorder()
+		cfg.HandleRestoreAccount(rr, httptest.NewRequest("GET", "/restore-account?token=tok", nil))
+
+		if rr.Header().Get("Location") != "/login.html?restore=invalid" {
+			t.Fatalf("%s link: want the invalid page, got %q", name, rr.Header().Get("Location"))
+		}
+		if fdb.called("SetUserActive") {
+			t.Fatalf("%s link: the account must stay closed", name)
+		}
+	}
+}
+
+func TestDeleteDueAccounts(t *testing.T) {
+	gone, restored := uuid.New(), uuid.New()
+	var cancelled, redacted driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"RedactUserAuditEvents": func(args []driver.NamedValue) fakeResult {
+			if args[0].Value == gone.String() {
+				redacted = args[0].Value
+			}
+			return fakeResult{affected: 3}
+		},
+		"GetDueAccountDeletions": func([]driver.NamedValue) fakeResult {
+			return fakeResult{cols: []string{"user_id"}, rows: [][]driver.Value{{gone.String()}, {restored.String()}}}
+		},
+		"DeleteInactiveUser": func(args []driver.NamedValue) fakeResult {
+			// the second one was reactivated by other means
+			if args[0].Value == restored.String() {
+				return fakeResult{affected: 0}
+			}
+			return fakeResult{affected: 1}
+		},
+		"CancelAccountDeletion": func(args []driver.NamedValue) fakeResult {
+			cancelled = args[0].Value
+			return fakeResult{affected: 1}
+		},
+		"CreateAuditEvent": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+
+	deleted, err := cfg.deleteDueAccounts(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("deleteDueAccounts: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("want 1 account deleted, got %d", deleted)
+	}
+	if cancelled != restored.String() {
+		t.Fatalf("an active account should only lose its schedule, got %v", cancelled)
+	}
+	if redacted != gone.String() {
+		t.Fatalf("the deleted account's audit events should be redacted, got %v", redacted)
+	}
+}
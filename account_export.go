@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type identityExport struct {
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+type purchaseExport struct {
+	ListID      *uuid.UUID `json:"list_id"`
+	ItemName    string     `json:"item_name"`
+	Qty         *int16     `json:"qty"`
+	Unit        string     `json:"unit"`
+	Kind        string     `json:"kind"`
+	PurchasedAt time.Time  `json:"purchased_at"`
+}
+
+// exportFile is one JSON file of a data export.
+type exportFile struct {
+	name string
+	data any
+}
+
+// HandleExportAccount sends everything stored about the user as a ZIP of
+// JSON files: profile, lists with their items, templates, purchase history,
+// devices, access tokens and security events. Secrets, i.e. password and
+// token hashes, are left out.
+func (cfg *apiConfig) HandleExportAccount(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	files, err := cfg.exportFiles(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to export data", err)
+		return
+	}
+
+	// built in memory, so that a failure is still a proper error response
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to export data", err)
+			return
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to export data", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to export data", err)
+		return
+	}
+
+	cfg.audit(req.Context(), req, userID, "account.export", userID.String(), "success")
+
+	filename := fmt.Sprintf("smart-list-export-%s.zip", time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func (cfg *apiConfig) exportFiles(ctx context.Context, userID uuid.UUID) ([]exportFile, error) {
+	user, err := cfg.Db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	profile := toAccountResponse(user)
+	change, err := cfg.Db.GetPendingEmailChange(ctx, userID)
+	if err == nil {
+		profile.PendingEmail = change.NewEmail
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	identityRows, err := cfg.Db.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities := make([]identityExport, 0, len(identityRows))
+	for _, i := range identityRows {
+		identities = append(identities, identityExport{
+			Provider:    i.Provider,
+			Subject:     i.Subject,
+			Email:       i.Email,
+			CreatedAt:   i.CreatedAt,
+			LastLoginAt: i.LastLoginAt,
+		})
+	}
+
+	listRows, err := cfg.Db.GetListsByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	templateRows, err := cfg.Db.GetTemplatesByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	purchaseRows, err := cfg.Db.GetUserPurchaseEvents(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	purchases := make([]purchaseExport, 0, len(purchaseRows))
+	for _, p := range purchaseRows {
+		e := purchaseExport{
+			ListID:      nullUUIDPtr(p.ListID),
+			ItemName:    p.ItemName,
+			Unit:        p.Unit.String,
+			Kind:        p.Kind,
+			PurchasedAt: p.PurchasedAt,
+		}
+		if p.Qty.Valid {
+			e.Qty = &p.Qty.Int16
+		}
+		purchases = append(purchases, e)
+	}
+
+	sessionRows, err := cfg.Db.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]sessionResponse, 0, len(sessionRows))
+	for _, s := range sessionRows {
+		sessions = append(sessions, sessionResponse{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.Ip,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+		})
+	}
+
+	tokenRows, err := cfg.Db.GetUserPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]accessTokenResponse, 0, len(tokenRows))
+	for _, t := range tokenRows {
+		tokens = append(tokens, toAccessTokenResponse(t))
+	}
+
+	eventRows, err := cfg.Db.GetUserAuditEvents(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]auditEventResponse, 0, len(eventRows))
+	for _, e := range eventRows {
+		events = append(events, toAuditEventResponse(e))
+	}
+
+	return []exportFile{
+		{"profile.json", profile},
+		{"sign_in_providers.json", identities},
+		{"lists.json", groupListRows(listRows)},
+		{"templates.json", groupTemplateRows(templateRows)},
+		{"purchase_history.json", purchases},
+		{"devices.json", sessions},
+		{"access_tokens.json", tokens},
+		{"security_events.json", events},
+	}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var purchaseEventCols = []string{"id", "user_id", "list_id", "item_id", "item_name", "qty", "unit", "kind", "purchased_at"}
+
+func noRows([]driver.NamedValue) fakeResult { return fakeResult{} }
+
+func TestHandleExportAccount(t *testing.T) {
+	userID := uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID":           activeUser,
+		"GetPendingEmailChange": func([]driver.NamedValue) fakeResult { return fakeResult{cols: emailChangeCols} },
+		"GetUserIdentities":     noRows,
+		"GetListsByUserId":      noRows,
+		"GetTemplatesByUserId":  noRows,
+		"GetUserSessions":       noRows,
+		"GetUserAuditEvents":    noRows,
+		"CreateAuditEvent":      func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"GetUserPurchaseEvents": func(args []driver.NamedValue) fakeResult {
+			return fakeRow(purchaseEventCols, int64(1), args[0].Value, nil, int64(7), "milk", int64(2), "l", "checked", time.Now())
+		},
+		"GetUserPersonalAccessTokens": func(args []driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(accessTokenCols, uuid.NewString(), args[0].Value, "ci", "secret-hash", "read", now, now.Add(time.Hour), nil, nil)
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleExportAccount(rr, httptest.NewRequest("GET", "/api/account/export", nil), userID)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/zip" || !strings.HasPrefix(rr.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("want a zip download, got %q %q", rr.Header().Get("Content-Type"), rr.Header().Get("Content-Disposition"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var profile accountResponse
+	if err := json.Unmarshal(files["profile.json"], &profile); err != nil || profile.Email != "ana@example.com" {
+		t.Fatalf("profile.json: %+v, %v", profile, err)
+	}
+	var purchases []purchaseExport
+	if err := json.Unmarshal(files["purchase_history.json"], &purchases); err != nil || len(purchases) != 1 || purchases[0].ItemName != "milk" {
+		t.Fatalf("purchase_history.json: %+v, %v", purchases, err)
+	}
+	if string(bytes.TrimSpace(files["lists.json"])) != "[]" {
+		t.Fatalf("lists.json: want an empty list, got %s", files["lists.json"])
+	}
+	for name, data := range files {
+		if bytes.Contains(data, []byte("secret-hash")) || bytes.Contains(data, []byte(`"hash"`)) {
+			t.Fatalf("%s must not contain password or token hashes", name)
+		}
+	}
+}
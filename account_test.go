@@ -0,0 +1,269 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+	"github.com/lib/pq"
+)
+
+var emailChangeCols = []string{"id", "user_id", "new_email", "token_hash", "created_at", "expires_at", "used_at"}
+
+// userWithPassword simulates GetUserByID for an account whose password is
+// "correct horse".
+func userWithPassword(t *testing.T) fakeHandler {
+	t.Helper()
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	return func(args []driver.NamedValue) fakeResult {
+		now := time.Now()
+		return fakeRow(userCols, args[0].Value, now, now, "ana@example.com", hash, true, "Ana", "Silva", now, nil, "user")
+	}
+}
+
+func emailChange(userID uuid.UUID, token, email string, expiresAt time.Time, usedAt driver.Value) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		if args[0].Value != auth.HashToken(token) {
+			return fakeResult{cols: emailChangeCols}
+		}
+		return fakeRow(emailChangeCols, uuid.NewString(), userID.String(), email, auth.HashToken(token), time.Now(), expiresAt, usedAt)
+	}
+}
+
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestHandleUpdateProfile(t *testing.T) {
+	userID := uuid.New()
+	var first, last driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"UpdateUserProfile": func(args []driver.NamedValue) fakeResult {
+			first, last = args[1].Value, args[2].Value
+			now := time.Now()
+			return fakeRow(userCols, args[0].Value, now, now, "ana@example.com", "hash", true, args[1].Value, args[2].Value, now, nil, "user")
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleUpdateProfile(rr, jsonRequest("PATCH", "/api/account", `{"first_name":"  Ana ","last_name":"Silva-Costa"}`), userID)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if first != "Ana" || last != "Silva-Costa" {
+		t.Fatalf("names should be cleaned up, got %q %q", first, last)
+	}
+	var resp accountResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.FirstName != "Ana" {
+		t.Fatalf("want the updated account, got %+v, %v", resp, err)
+	}
+}
+
+func TestHandleUpdateProfile_InvalidName(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, nil)
+
+	rr := httptest.NewRecorder()
+	cfg.HandleUpdateProfile(rr, jsonRequest("PATCH", "/api/account", `{"first_name":"Ana1","last_name":"Silva"}`), uuid.New())
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+	if fdb.called("UpdateUserProfile") {
+		t.Fatalf("an invalid name must not be saved")
+	}
+}
+
+func TestHandleChangePassword(t *testing.T) {
+	userID, sessionID := uuid.New(), uuid.New()
+	var kept driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID":        userWithPassword(t),
+		"UpdateUserPassword": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"UsePasswordResets":  func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeOtherSessions": func(args []driver.NamedValue) fakeResult {
+			kept = args[1].Value
+			return fakeResult{affected: 2}
+		},
+		"RevokeUserPersonalAccessTokens": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateAuditEvent":               func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+
+	req := jsonRequest("POST", "/api/account/password", `{"current_password":"correct horse","new_password":"battery staple"}`)
+	req = req.WithContext(withSessionID(req.Context(), sessionID))
+	rr := httptest.NewRecorder()
+	cfg.HandleChangePassword(rr, req, userID)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("status: want 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if !fdb.called("UpdateUserPassword") || !fdb.called("UsePasswordResets") {
+		t.Fatalf("password should be changed and reset links used up")
+	}
+	if kept != sessionID.String() {
+		t.Fatalf("the current session should be kept, got %v", kept)
+	}
+	if !fdb.called("RevokeUserPersonalAccessTokens") {
+		t.Fatalf("access tokens should be revoked")
+	}
+}
+
+func TestHandleChangePassword_WrongPassword(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID": userWithPassword(t),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleChangePassword(rr, jsonRequest("POST", "/api/account/password", `{"current_password":"wrong","new_password":"battery staple"}`), uuid.New())
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+	if fdb.called("UpdateUserPassword") || fdb.called("RevokeOtherSessions") || fdb.called("RevokeUserPersonalAccessTokens") {
+		t.Fatalf("a wrong current password must change nothing")
+	}
+}
+
+func TestHandleChangeEmail(t *testing.T) {
+	userID := uuid.New()
+	var tokenHash driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID":      userWithPassword(t),
+		"GetUserByEmail":   userWithEmail(uuid.New(), "taken@example.com"),
+		"UseEmailChanges":  func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateAuditEvent": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateEmailChange": func(args []driver.NamedValue) fakeResult {
+			tokenHash = args[2].Value
+			return fakeResult{affected: 1}
+		},
+	})
+	sent := make(chanMailer, 1)
+	cfg.Mailer = sent
+
+	rr := httptest.NewRecorder()
+	cfg.HandleChangeEmail(rr, jsonRequest("POST", "/api/account/email", `{"email":"new@example.com","password":"correct horse"}`), userID)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status: want 202, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	var msg mailer.Message
+	select {
+	case msg = <-sent:
+	case <-time.After(time.Second):
+		t.Fatalf("no email sent")
+	}
+	if msg.To != "new@example.com" {
+		t.Fatalf("the link should go to the new address, got %s", msg.To)
+	}
+	i := strings.Index(msg.Body, "token=")
+	if i < 0 {
+		t.Fatalf("email has no link:\n%s", msg.Body)
+	}
+	token, _ := url.QueryUnescape(strings.Fields(msg.Body[i+len("token="):])[0])
+	if auth.HashToken(token) != tokenHash {
+		t.Fatalf("only the hash of the emailed token should be stored")
+	}
+
+	// an address in use is refused up front
+	rr = httptest.NewRecorder()
+	cfg.HandleChangeEmail(rr, jsonRequest("POST", "/api/account/email", `{"email":"taken@example.com","password":"correct horse"}`), userID)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("status: want 409, got %d", rr.Code)
+	}
+}
+
+func TestHandleConfirmEmailChange(t *testing.T) {
+	userID := uuid.New()
+	var newEmail driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetEmailChangeForUpdate": emailChange(userID, "tok", "new@example.com", time.Now().Add(time.Hour), nil),
+		"GetUserByID":             activeUser,
+		"UseEmailChanges":         func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateAuditEvent":        func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"UpdateUserEmail": func(args []driver.NamedValue) fakeResult {
+			newEmail = args[1].Value
+			return fakeResult{affected: 1}
+		},
+	})
+	sent := make(chanMailer, 1)
+	cfg.Mailer = sent
+
+	rr := httptest.NewRecorder()
+	cfg.HandleConfirmEmailChange(rr, httptest.NewRequest("GET", "/confirm-email?token=tok", nil))
+
+	if rr.Header().Get("Location") != "/login.html?email=changed" {
+		t.Fatalf("want the changed page, got %d %q (%s)", rr.Code, rr.Header().Get("Location"), rr.Body.String())
+	}
+	if newEmail != "new@example.com" || !fdb.called("UseEmailChanges") {
+		t.Fatalf("the address should change and the link be used up, got %v", newEmail)
+	}
+
+	select {
+	case msg := <-sent:
+		if msg.To != "ana@example.com" {
+			t.Fatalf("the old address should be told, got %s", msg.To)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no email sent")
+	}
+}
+
+func TestHandleConfirmEmailChange_Unusable(t *testing.T) {
+	userID := uuid.New()
+	cases := map[string]fakeHandler{
+		"unknown": emailChange(userID, "other", "new@example.com", time.Now().Add(time.Hour), nil),
+		"used":    emailChange(userID, "tok", "new@example.com", time.Now().Add(time.Hour), time.Now()),
+		"expired": emailChange(userID, "tok", "new@example.com", time.Now().Add(-time.Minute), nil),
+	}
+
+	for name, handler := range cases {
+		cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+			"GetEmailChangeForUpdate": handler,
+		})
+
+		rr := httptest.NewRecorder()
+		cfg.HandleConfirmEmailChange(rr, httptest.NewRequest("GET", "/confirm-email?token=tok", nil))
+
+		if rr.Header().Get("Location") != "/login.html?email=invalid" {
+			t.Fatalf("%s link: want the invalid page, got %q", name, rr.Header().Get("Location"))
+		}
+		if fdb.called("UpdateUserEmail") {
+			t.Fatalf("%s link: the address must not change", name)
+		}
+	}
+}
+
+func TestHandleConfirmEmailChange_Taken(t *testing.T) {
+	userID := uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetEmailChangeForUpdate": emailChange(userID, "tok", "new@example.com", time.Now().Add(time.Hour), nil),
+		"GetUserByID":             activeUser,
+		"UpdateUserEmail": func([]driver.NamedValue) fakeResult {
+			return fakeResult{err: &pq.Error{Code: "23505"}}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleConfirmEmailChange(rr, httptest.NewRequest("GET", "/confirm-email?token=tok", nil))
+
+	if rr.Header().Get("Location") != "/login.html?email=taken" {
+		t.Fatalf("want the taken page, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if fdb.called("UseEmailChanges") {
+		t.Fatalf("nothing should be committed")
+	}
+}
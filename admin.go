@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+)
+
+const (
+	roleUser  = "user"
+	roleAdmin = "admin"
+
+	adminSearchDefault = 50
+	adminSearchMax     = 200
+)
+
+var (
+	errAdminUserNotFound = errors.New("user not found")
+	errAdminSelf         = errors.New("not allowed on your own account")
+)
+
+type adminUserResponse struct {
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	Role          string    `json:"role"`
+	IsActive      bool      `json:"is_active"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func toAdminUserResponse(user database.User) adminUserResponse {
+	return adminUserResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Role:          user.Role,
+		IsActive:      user.IsActive,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+}
+
+// middlewareAdmin lets only admins through. It goes after middlewareAuth;
+// the role is read on every request, so a demotion takes effect right away.
+func (cfg *apiConfig) middlewareAdmin(next authedHandler) authedHandler {
+	return func(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+		role, err := cfg.Db.GetActiveUserRole(req.Context(), userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "failed to check role", err)
+			return
+		}
+		if role != roleAdmin {
+			cfg.audit(req.Context(), req, userID, "admin.denied", req.Method+" "+req.URL.Path, "denied")
+			respondWithError(w, http.StatusForbidden, "admins only", nil)
+			return
+		}
+		next(w, req, userID)
+	}
+}
+
+// HandleAdminSearchUsers finds users whose email or name contains q, newest
+// first. Without q it lists everyone.
+func (cfg *apiConfig) HandleAdminSearchUsers(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	query := req.URL.Query()
+
+	limit, err := queryInt(query.Get("limit"), adminSearchDefault)
+	if err != nil || limit < 1 || limit > adminSearchMax {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", adminSearchMax), nil)
+		return
+	}
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		respondWithError(w, http.StatusBadRequest, "offset must not be negative", nil)
+		return
+	}
+
+	users, err := cfg.Db.SearchUsers(req.Context(), database.SearchUsersParams{
+		Query:    escapeLike(strings.TrimSpace(query.Get("q"))),
+		MaxUsers: int32(limit),
+		Skip:     int32(offset),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to search users", err)
+		return
+	}
+
+	cfg.audit(req.Context(), req, userID, "admin.user.search", cfg.searchTarget(query.Get("q")), "success")
+
+	resp := make([]adminUserResponse, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, toAdminUserResponse(u))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// HandleAdminDeactivateUser blocks an account: it can't sign in anymore, and
+// its sessions and access tokens stop working. Nothing is deleted; a deletion
+// the user asked for is called off, or its restore link would let them undo
+// the block.
+func (cfg *apiConfig) HandleAdminDeactivateUser(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	target, ok := cfg.adminTarget(w, req, userID)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	if err = qtx.SetUserActive(req.Context(), database.SetUserActiveParams{ID: target.ID, IsActive: false}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to deactivate user", err)
+		return
+	}
+
+	if err = qtx.CancelAccountDeletion(req.Context(), target.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to deactivate user", err)
+		return
+	}
+
+	if err = revokeUserCredentials(req.Context(), qtx, target.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to deactivate user", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to deactivate user", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "admin.user.deactivate", target.ID.String(), "success")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminReactivateUser lets a deactivated account sign in again. If its
+// owner had asked for it to be deleted, the deletion is called off.
+func (cfg *apiConfig) HandleAdminReactivateUser(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	target, ok := cfg.adminTarget(w, req, userID)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	if err = qtx.SetUserActive(req.Context(), database.SetUserActiveParams{ID: target.ID, IsActive: true}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reactivate user", err)
+		return
+	}
+
+	if err = qtx.CancelAccountDeletion(req.Context(), target.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reactivate user", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reactivate user", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "admin.user.reactivate", target.ID.String(), "success")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminResetPassword replaces the user's password with one nobody
+// knows, signs them out everywhere and mails them a link to choose a new one.
+// It is meant for accounts that look compromised.
+func (cfg *apiConfig) HandleAdminResetPassword(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	target, ok := cfg.adminTarget(w, req, userID)
+	if !ok {
+		return
+	}
+
+	secret, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+	hashedPwd, err := auth.HashPassword(secret)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	err = qtx.UpdateUserPassword(req.Context(), database.UpdateUserPasswordParams{
+		ID:             target.ID,
+		HashedPassword: hashedPwd,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+
+	// links sent before may have gone to whoever took over the account
+	if err = qtx.UsePasswordResets(req.Context(), target.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+
+	if err = revokeUserCredentials(req.Context(), qtx, target.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+
+	link, err := cfg.createPasswordReset(req.Context(), qtx, target.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "admin.user.password_reset", target.ID.String(), "success")
+
+	cfg.sendMail(mailer.Message{
+		To:      target.Email,
+		Subject: "Your Smart List password was reset",
+		Body: fmt.Sprintf(`Hi %s,
+
+To keep your Smart List account safe, we reset its password and signed you
+out everywhere. To choose a new password, open this link within the next
+hour:
+
+%s
+
+After that, "Forgot your password?" on the sign-in page sends a new link.
+`, target.FirstName, link),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminRevokeSessions signs the user out on every device.
+func (cfg *apiConfig) HandleAdminRevokeSessions(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	target, ok := cfg.adminTarget(w, req, userID)
+	if !ok {
+		return
+	}
+
+	n, err := cfg.Db.RevokeUserSessions(req.Context(), target.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "admin.user.sessions_revoke", target.ID.String(), "success")
+
+	respondWithJSON(w, http.StatusOK, map[string]int64{"revoked": n})
+}
+
+// HandleAdminSetRole makes the user an admin or takes it back.
+func (cfg *apiConfig) HandleAdminSetRole(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode role payload", err)
+		return
+	}
+	if body.Role != roleUser && body.Role != roleAdmin {
+		respondWithError(w, http.StatusBadRequest, "role must be user or admin", nil)
+		return
+	}
+
+	target, ok := cfg.adminTarget(w, req, userID)
+	if !ok {
+		return
+	}
+
+	if _, err := cfg.Db.SetUserRole(req.Context(), database.SetUserRoleParams{
+		ID:   target.ID,
+		Role: body.Role,
+	}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to change role", err)
+		return
+	}
+	action := "admin.user.demote"
+	if body.Role == roleAdmin {
+		action = "admin.user.promote"
+	}
+	cfg.audit(req.Context(), req, userID, action, target.ID.String(), "success")
+
+	target.Role = body.Role
+	respondWithJSON(w, http.StatusOK, toAdminUserResponse(target))
+}
+
+type adminAuditEventResponse struct {
+	ID      int64      `json:"id"`
+	ActorID *uuid.UUID `json:"actor_id"`
+	auditEventResponse
+}
+
+// HandleAdminSearchAuditEvents queries the audit log, newest first. Filters
+// are optional: actor_id, target, action (which also matches the actions
+// under it, e.g. "admin.user"), outcome, since and until (RFC 3339). Pages
+// go on with before_id, the id of the last event seen.
+func (cfg *apiConfig) HandleAdminSearchAuditEvents(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	query := req.URL.Query()
+
+	limit, err := queryInt(query.Get("limit"), adminSearchDefault)
+	if err != nil || limit < 1 || limit > adminSearchMax {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", adminSearchMax), nil)
+		return
+	}
+
+	params := database.SearchAuditEventsParams{
+		Target:    queryString(query.Get("target")),
+		Action:    queryString(query.Get("action")),
+		Outcome:   queryString(query.Get("outcome")),
+		MaxEvents: int32(limit),
+	}
+	if s := query.Get("actor_id"); s != "" {
+		actorID, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "actor_id must be a user id", nil)
+			return
+		}
+		params.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+	}
+	if params.Since, err = queryTime(query.Get("since")); err != nil {
+		respondWithError(w, http.StatusBadRequest, "since must be an RFC 3339 time", nil)
+		return
+	}
+	if params.Until, err = queryTime(query.Get("until")); err != nil {
+		respondWithError(w, http.StatusBadRequest, "until must be an RFC 3339 time", nil)
+		return
+	}
+	if s := query.Get("before_id"); s != "" {
+		beforeID, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "before_id must be an event id", nil)
+			return
+		}
+		params.BeforeID = sql.NullInt64{Int64: beforeID, Valid: true}
+	}
+
+	rows, err := cfg.Db.SearchAuditEvents(req.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to search audit events", err)
+		return
+	}
+
+	filters := req.URL.Query()
+	if t := filters.Get("target"); t != "" {
+		filters.Set("target", cfg.searchTarget(t))
+	}
+	cfg.audit(req.Context(), req, userID, "admin.audit.search", filters.Encode(), "success")
+
+	events := make([]adminAuditEventResponse, 0, len(rows))
+	for _, e := range rows {
+		events = append(events, adminAuditEventResponse{
+			ID:                 e.ID,
+			ActorID:            nullUUIDPtr(e.ActorID),
+			auditEventResponse: toAuditEventResponse(e),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+// searchTarget names what an admin searched for in an audit event, hashing it
+// when it looks like an email address.
+func (cfg *apiConfig) searchTarget(q string) string {
+	if strings.Contains(q, "@") {
+		return cfg.emailTarget(q)
+	}
+	return q
+}
+
+// adminTarget loads the user named in the path. Admins can't act on their
+// own account, so that nobody locks themselves out or demotes the last
+// admin by mistake. It responds itself when the user can't be used.
+func (cfg *apiConfig) adminTarget(w http.ResponseWriter, req *http.Request, adminID uuid.UUID) (database.User, bool) {
+	targetID, err := uuid.Parse(req.PathValue("user_id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errAdminUserNotFound.Error(), nil)
+		return database.User{}, false
+	}
+	if targetID == adminID {
+		respondWithError(w, http.StatusForbidden, errAdminSelf.Error(), nil)
+		return database.User{}, false
+	}
+
+	target, err := cfg.Db.GetUserByID(req.Context(), targetID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, errAdminUserNotFound.Error(), nil)
+			return database.User{}, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to load user", err)
+		return database.User{}, false
+	}
+	return target, true
+}
+
+// revokeUserCredentials ends every session and access token of the user.
+func revokeUserCredentials(ctx context.Context, q *database.Queries, userID uuid.UUID) error {
+	if _, err := q.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	return q.RevokeUserPersonalAccessTokens(ctx, userID)
+}
+
+// queryInt parses an optional integer query parameter.
+func queryInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// queryString turns an optional query parameter into a nullable filter.
+func queryString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// queryTime parses an optional RFC 3339 query parameter.
+func queryTime(s string) (sql.NullTime, error) {
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func roleRow(role string) fakeHandler {
+	return func([]driver.NamedValue) fakeResult {
+		return fakeRow([]string{"role"}, role)
+	}
+}
+
+func adminRequest(method, target, body string, userID uuid.UUID) *http.Request {
+	req := jsonRequest(method, target, body)
+	req.SetPathValue("user_id", userID.String())
+	return req
+}
+
+func TestMiddlewareAdmin(t *testing.T) {
+	cases := map[string]fakeHandler{
+		"user":     roleRow("user"),
+		"inactive": func([]driver.NamedValue) fakeResult { return fakeResult{cols: []string{"role"}} },
+	}
+
+	for name, handler := range cases {
+		var action driver.Value
+		cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+			"GetActiveUserRole": handler,
+			"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+				action = args[1].Value
+				return fakeResult{affected: 1}
+			},
+		})
+
+		called := false
+		h := cfg.middlewareAdmin(func(http.ResponseWriter, *http.Request, uuid.UUID) { called = true })
+		rr := httptest.NewRecorder()
+		h(rr, httptest.NewRequest("GET", "/api/admin/users", nil), uuid.New())
+
+		if rr.Code != http.StatusForbidden || called {
+			t.Fatalf("%s: want 403 without reaching the handler, got %d", name, rr.Code)
+		}
+		if action != "admin.denied" {
+			t.Fatalf("%s: the refusal should be audited, got %v", name, action)
+		}
+	}
+}
+
+func TestMiddlewareAdmin_Admin(t *testing.T) {
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetActiveUserRole": roleRow("admin"),
+	})
+
+	called := false
+	h := cfg.middlewareAdmin(func(http.ResponseWriter, *http.Request, uuid.UUID) { called = true })
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/admin/users", nil), uuid.New())
+
+	if !called {
+		t.Fatalf("admins should get through")
+	}
+}
+
+func TestHandleAdminSearchUsers(t *testing.T) {
+	var query, limit, action, target driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"SearchUsers": func(args []driver.NamedValue) fakeResult {
+			query, limit = args[0].Value, args[1].Value
+			now := time.Now()
+			return fakeRow(userCols, uuid.NewString(), now, now, "ana_1@example.com", "hash", true, "Ana", "Silva", now, nil, "admin")
+		},
+		"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+			action, target = args[1].Value, args[2].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAdminSearchUsers(rr, httptest.NewRequest("GET", "/api/admin/users?q=ana_1%25&limit=10", nil), uuid.New())
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if query != `ana\_1\%` || limit != int64(10) {
+		t.Fatalf("wildcards should be escaped and the limit passed, got %q %v", query, limit)
+	}
+	var resp []adminUserResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 1 || resp[0].Role != "admin" || strings.Contains(rr.Body.String(), "hash") {
+		t.Fatalf("want the user with its role and no password hash, got %s", rr.Body.String())
+	}
+	if action != "admin.user.search" || target != "ana_1%" {
+		t.Fatalf("the search should be audited with its query, got %v %v", action, target)
+	}
+}
+
+func TestHandleAdminSearchUsers_EmailQueryHashed(t *testing.T) {
+	var target driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"SearchUsers": func([]driver.NamedValue) fakeResult { return fakeResult{cols: userCols} },
+		"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+			target = args[2].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAdminSearchUsers(rr, httptest.NewRequest("GET", "/api/admin/users?q=ana@example.com", nil), uuid.New())
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if target != cfg.emailTarget("ana@example.com") {
+		t.Fatalf("an email address should not be recorded, got %v", target)
+	}
+}
+
+func TestHandleAdminSearchUsers_BadLimit(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{})
+
+	for _, q := range []string{"limit=0", "limit=1000", "limit=x", "offset=-1"} {
+		rr := httptest.NewRecorder()
+		cfg.HandleAdminSearchUsers(rr, httptest.NewRequest("GET", "/api/admin/users?"+q, nil), uuid.New())
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: want 400, got %d", q, rr.Code)
+		}
+	}
+	if fdb.called("SearchUsers") {
+		t.Fatalf("bad paging should not reach the database")
+	}
+}
+
+func TestHandleAdminDeactivateUser(t *testing.T) {
+	adminID, targetID := uuid.New(), uuid.New()
+	var active, target driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID": userWithPassword(t),
+		"SetUserActive": func(args []driver.NamedValue) fakeResult {
+			active = args[1].Value
+			return fakeResult{affected: 1}
+		},
+		"CancelAccountDeletion":          func([]driver.NamedValue) fakeResult { return fakeResult{affected: 0} },
+		"RevokeUserSessions":             func([]driver.NamedValue) fakeResult { return fakeResult{affected: 2} },
+		"RevokeUserPersonalAccessTokens": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+			target = args[2].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAdminDeactivateUser(rr, adminRequest("POST", "/api/admin/users/x/deactivate", "", targetID), adminID)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("status: want 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if active != false {
+		t.Fatalf("the account should be deactivated, got %v", active)
+	}
+	if !fdb.called("RevokeUserSessions") || !fdb.called("RevokeUserPersonalAccessTokens") {
+		t.Fatalf("sessions and access tokens should stop working")
+	}
+	if target != targetID.String() {
+		t.Fatalf("the audit event should name the user, got %v", target)
+	}
+}
+
+func TestHandleAdminDeactivateUser_VoidsRestoreLink(t *testing.T) {
+	targetID := uuid.New()
+	scheduled := true
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID":   userWithPassword(t),
+		"SetUserActive": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CancelAccountDeletion": func(args []driver.NamedValue) fakeResult {
+			if args[0].Value == targetID.String() {
+				scheduled = false
+			}
+			return fakeResult{affected: 1}
+		},
+		"RevokeUserSessions":             func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeUserPersonalAccessTokens": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateAuditEvent":               func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		// the user had asked for their account to be deleted before
+		"GetAccountDeletionForUpdate": func(args []driver.NamedValue) fakeResult {
+			if !scheduled {
+				return fakeResult{cols: accountDeletionCols}
+			}
+			return accountDeletion(targetID, "tok", time.Now().Add(time.Hour))(args)
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAdminDeactivateUser(rr, adminRequest("POST", "/api/admin/users/x/deactivate", "", targetID), uuid.New())
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("status: want 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	cfg.HandleRestoreAccount(rr, httptest.NewRequest("GET", "/restore-account?token=tok", nil))
+
+	if rr.Header().Get("Location") != "/login.html?restore=invalid" {
+		t.Fatalf("the restore link must not undo an admin's block, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestHandleAdminDeactivateUser_Self(t *testing.T) {
+	adminID := uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAdminDeactivateUser(rr, adminRequest("POST", "/api/admin/users/x/deactivate", "", adminID), adminID)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("status: want 403, got %d", rr.Code)
+	}
+	if fdb.called("SetUserActive") {
+		t.Fatalf("admins must not lock themselves out")
+	}
+}
+
+func TestHandleAdminResetPassword(t *testing.T) {
+	var newHash driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID": userWithPassword(t),
+		"UpdateUserPassword": func(args []driver.NamedValue) fakeResult {
+			newHash = args[1].Value
+			return fakeResult{affected: 1}
+		},
+		"UsePasswordResets":              func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeUserSessions":             func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeUserPersonalAccessTokens": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreatePasswordReset":            func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateAuditEvent":               func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+	sent := make(chanMailer, 1)
+	cfg.Mailer = sent
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAdminResetPassword(rr, adminRequest("POST", "/api/admin/users/x/password-reset", "", uuid.New()), uuid.New())
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("status: want 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if newHash == nil || !fdb.called("RevokeUserSessions") || !fdb.called("UsePasswordResets") {
+		t.Fatalf("the password should be replaced, sessions revoked and old links voided")
+	}
+
+	select {
+	case msg := <-sent:
+		if msg.To != "ana@example.com" || !strings.Contains(msg.Body, "/reset-password.html?token=") {
+			t.Fatalf("want a reset link mailed to the user, got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no email sent")
+	}
+}
+
+func TestHandleAdminSetRole(t *testing.T) {
+	var role driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID": userWithPassword(t),
+		"SetUserRole": func(args []driver.NamedValue) fakeResult {
+			role = args[1].Value
+			return fakeResult{affected: 1}
+		},
+		"CreateAuditEvent": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAdminSetRole(rr, adminRequest("PATCH", "/api/admin/users/x/role", `{"role":"admin"}`, uuid.New()), uuid.New())
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if role != "admin" {
+		t.Fatalf("want the role stored, got %v", role)
+	}
+
+	rr = httptest.NewRecorder()
+	cfg.HandleAdminSetRole(rr, adminRequest("PATCH", "/api/admin/users/x/role", `{"role":"owner"}`, uuid.New()), uuid.New())
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown roles: want 400, got %d", rr.Code)
+	}
+}
+
+func TestHandleAdminRevokeSessions_UnknownUser(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByID": func([]driver.NamedValue) fakeResult { return fakeResult{cols: userCols} },
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAdminRevokeSessions(rr, adminRequest("DELETE", "/api/admin/users/x/sessions", "", uuid.New()), uuid.New())
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", rr.Code)
+	}
+	if fdb.called("RevokeUserSessions") {
+		t.Fatalf("nothing should be revoked")
+	}
+}
+
+func TestHandleAdminSearchAuditEvents(t *testing.T) {
+	actorID := uuid.New()
+	var args []driver.NamedValue
+	var action, auditTarget driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"SearchAuditEvents": func(a []driver.NamedValue) fakeResult {
+			args = a
+			return fakeRow(auditEventCols, int64(41), time.Now(), actorID.String(), "admin.user.deactivate", uuid.NewString(), "", "", "success")
+		},
+		"CreateAuditEvent": func(a []driver.NamedValue) fakeResult {
+			action, auditTarget = a[1].Value, a[2].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	target := "/api/admin/audit-events?actor_id=" + actorID.String() + "&action=admin.user&since=2026-01-01T00:00:00Z&before_id=42"
+	cfg.HandleAdminSearchAuditEvents(rr, httptest.NewRequest("GET", target, nil), uuid.New())
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if args[0].Value != actorID.String() || args[2].Value != "admin.user" || args[6].Value != int64(42) {
+		t.Fatalf("filters should be passed on, got %v", args)
+	}
+	if args[1].Value != nil || args[3].Value != nil || args[5].Value != nil {
+		t.Fatalf("missing filters should be NULL, got %v", args)
+	}
+	var resp []adminAuditEventResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 1 || resp[0].ID != 41 || resp[0].ActorID == nil || *resp[0].ActorID != actorID {
+		t.Fatalf("want the event with its id and actor, got %s", rr.Body.String())
+	}
+	if action != "admin.audit.search" || !strings.Contains(auditTarget.(string), "actor_id="+actorID.String()) {
+		t.Fatalf("the search should be audited with its filters, got %v %v", action, auditTarget)
+	}
+}
+
+func TestHandleAdminSearchAuditEvents_BadFilter(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{})
+
+	for _, q := range []string{"actor_id=nope", "since=yesterday", "until=1", "before_id=x", "limit=500"} {
+		rr := httptest.NewRecorder()
+		cfg.HandleAdminSearchAuditEvents(rr, httptest.NewRequest("GET", "/api/admin/audit-events?"+q, nil), uuid.New())
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: want 400, got %d", q, rr.Code)
+		}
+	}
+	if fdb.called("SearchAuditEvents") {
+		t.Fatalf("bad filters should not reach the database")
+	}
+}
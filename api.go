@@ -3,11 +3,15 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/events"
+	"github.com/henrique-godinho/smart-list/internal/suggest"
 )
 
 func (cfg *apiConfig) HandleAddToList(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
@@ -27,11 +31,29 @@ func (cfg *apiConfig) HandleAddToList(w http.ResponseWriter, req *http.Request,
 		return
 	}
 
-	listID, err := uuid.Parse(listData.ListID)
+	listID, err := listIDFromPath(req)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "failed to parse list id", err)
+		respondWithListError(w, err)
 		return
 	}
+
+	if listData.ListID != "" && listData.ListID != listID.String() {
+		respondWithError(w, http.StatusBadRequest, "list id does not match request path", nil)
+		return
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permEditItems)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	version, err := checkIfMatch(req, list.Version)
+	if err != nil {
+		cfg.respondWithStaleList(w, req, listID, userID, err)
+		return
+	}
+
 	itemsJson, err := json.Marshal(listData.Items)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "failed to parse items", err)
@@ -46,9 +68,26 @@ func (cfg *apiConfig) HandleAddToList(w http.ResponseWriter, req *http.Request,
 	defer tx.Rollback()
 	qtx := cfg.Db.WithTx(tx)
 
+	version, err = bumpListVersion(req.Context(), qtx, listID, version)
+	if err != nil {
+		if errors.Is(err, errPreconditionFailed) {
+			cfg.respondWithStaleList(w, req, listID, userID, err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
+	before, err := qtx.GetListItems(req.Context(), listID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
 	err = qtx.UpdateUserList(req.Context(), database.UpdateUserListParams{
 		ListID: listID,
 		Items:  itemsJson,
+		UserID: userID,
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
@@ -58,21 +97,36 @@ func (cfg *apiConfig) HandleAddToList(w http.ResponseWriter, req *http.Request,
 	err = qtx.RemoveItemsFromUserList(req.Context(), database.RemoveItemsFromUserListParams{
 		ListID: listID,
 		Items:  itemsJson,
+		UserID: userID,
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "failed to remove items", err)
 		return
 	}
 
-	list, err := qtx.GetUpdatedListById(req.Context(), listID)
+	after, err := qtx.GetListItems(req.Context(), listID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to get updated list", err)
+		return
+	}
+
+	rows, err := qtx.GetUpdatedListById(req.Context(), listID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "failed to get updated list", err)
 		return
 	}
-	tx.Commit()
+
+	oldItems := make([]listItemResponse, 0, len(before))
+	for _, item := range before {
+		oldItems = append(oldItems, newListItemResponse(item))
+	}
+	newItems := make([]listItemResponse, 0, len(after))
+	for _, item := range after {
+		newItems = append(newItems, newListItemResponse(item))
+	}
 
 	updatedList := make([]UserList, 0)
-	for _, item := range list {
+	for _, item := range rows {
 		updatedList = append(updatedList, UserList{
 			ItemID:        item.ItemID,
 			ListID:        item.ListID,
@@ -81,13 +135,50 @@ func (cfg *apiConfig) HandleAddToList(w http.ResponseWriter, req *http.Request,
 			Unit:          item.Unit.String,
 			Price:         int(item.Price.Int16),
 			UpdatedAt:     item.UpdatedAt.Time,
+			Checked:       item.Checked,
 			ListName:      item.ListName,
 			ListFreq:      item.Frequency.String,
 			TargetDate:    item.TargetDate.Time,
 			ListUpdatedAt: item.ListUpdatedAt.Time,
+			ListVersion:   version,
 		})
 	}
 
+	added, updated, removed := itemChanges(oldItems, newItems)
+
+	removedIDs := make(map[int64]bool, len(removed))
+	for _, item := range removed {
+		removedIDs[item.ID] = true
+	}
+	// removing an unchecked item is how a purchase was marked before
+	// checkboxes, so it still counts as one
+	for _, item := range before {
+		if !removedIDs[item.ID] || item.Checked {
+			continue
+		}
+		if err := recordPurchase(req.Context(), qtx, userID, item, purchaseRemoved); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to remove items", err)
+			return
+		}
+	}
+
+	// nothing may be announced that wasn't stored
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
+	for _, item := range added {
+		cfg.publishListEvent(req.Context(), listID, version, events.ItemAdded, item)
+	}
+	for _, item := range updated {
+		cfg.publishListEvent(req.Context(), listID, version, events.ItemUpdated, item)
+	}
+	for _, item := range removed {
+		cfg.publishListEvent(req.Context(), listID, version, events.ItemRemoved, item)
+	}
+
+	w.Header().Set("ETag", listETag(version))
 	respondWithJSON(w, http.StatusOK, updatedList)
 
 }
@@ -95,10 +186,15 @@ func (cfg *apiConfig) HandleAddToList(w http.ResponseWriter, req *http.Request,
 func (cfg *apiConfig) CreateNewList(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
 
 	type NewList struct {
-		ID         uuid.UUID `json:"id"`
-		Name       string    `json:"name"`
-		Freq       string    `json:"frequency"`
-		TargetDate time.Time `json:"target_date"`
+		ID          uuid.UUID            `json:"id"`
+		Name        string               `json:"name"`
+		Freq        string               `json:"frequency"`
+		TargetDate  time.Time            `json:"target_date"`
+		SpawnCopy   bool                 `json:"spawn_copy"`
+		Version     int64                `json:"version"`
+		TemplateID  *uuid.UUID           `json:"template_id,omitempty"`
+		Items       []listItemResponse   `json:"items"`
+		Suggestions []suggest.Suggestion `json:"suggestions"`
 	}
 
 	var newList NewList
@@ -108,35 +204,117 @@ func (cfg *apiConfig) CreateNewList(w http.ResponseWriter, req *http.Request, us
 		return
 	}
 
-	frequency := sql.NullString{
-		String: newList.Freq,
-		Valid:  true,
+	frequency, err := validateFrequency(newList.Freq)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
 	}
 
 	targetDate := sql.NullTime{
 		Time:  newList.TargetDate,
-		Valid: true,
+		Valid: !newList.TargetDate.IsZero(),
+	}
+
+	if frequency.Valid && !targetDate.Valid {
+		targetDate = firstTargetDate(frequency)
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transacttion", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	if newList.TemplateID != nil {
+		template, err := qtx.GetTemplate(req.Context(), database.GetTemplateParams{
+			ID:     *newList.TemplateID,
+			UserID: userID,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusNotFound, errTemplateNotFound.Error(), nil)
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "failed to load template", err)
+			return
+		}
+		if strings.TrimSpace(newList.Name) == "" {
+			newList.Name = template.Name
+		}
+	}
+
+	name, err := validateListName(newList.Name)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
 	}
 
-	newListData, err := cfg.Db.CreateNewList(req.Context(), database.CreateNewListParams{
+	newListData, err := qtx.CreateNewList(req.Context(), database.CreateNewListParams{
 		UserID:     userID,
-		Name:       newList.Name,
+		Name:       name,
 		Frequency:  frequency,
 		TargetDate: targetDate,
+		SpawnCopy:  newList.SpawnCopy,
+	})
+
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create new list", err)
+		return
+	}
+
+	_, err = qtx.AddListMember(req.Context(), database.AddListMemberParams{
+		ListID: newListData.ID,
+		UserID: userID,
+		Role:   "owner",
+		Status: "accepted",
 	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create new list", err)
+		return
+	}
+
+	if newList.TemplateID != nil {
+		err = qtx.CreateListItemsFromTemplate(req.Context(), database.CreateListItemsFromTemplateParams{
+			ListID:     newListData.ID,
+			TemplateID: *newList.TemplateID,
+			UserID:     userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to create new list", err)
+			return
+		}
+	}
 
+	items, err := qtx.GetListItems(req.Context(), newListData.ID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "failed to create new list", err)
 		return
 	}
 
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create new list", err)
+		return
+	}
+
 	newList = NewList{
 		ID:         newListData.ID,
 		Name:       newListData.Name,
 		Freq:       newListData.Frequency.String,
-		TargetDate: newList.TargetDate,
+		TargetDate: newListData.TargetDate.Time,
+		SpawnCopy:  newListData.SpawnCopy,
+		Version:    newListData.Version,
+		TemplateID: newList.TemplateID,
+		Items:      make([]listItemResponse, 0, len(items)),
+		// offer what's due so the new list can start with it
+		Suggestions: cfg.suggestionsOrEmpty(req.Context(), userID),
+	}
+	for _, item := range items {
+		newList.Items = append(newList.Items, newListItemResponse(item))
 	}
 
+	w.Header().Set("ETag", listETag(newListData.Version))
 	respondWithJSON(w, http.StatusOK, newList)
 
 }
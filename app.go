@@ -6,13 +6,15 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/suggest"
 )
 
 func (cfg *apiConfig) HandleAppMain(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
 
 	type ResponseData struct {
-		Catalog  []Catalog
-		UserList []UserList
+		Catalog     []Catalog
+		UserList    []UserList
+		Suggestions []suggest.Suggestion
 	}
 
 	catalog, err := cfg.LoadCatalog(req)
@@ -33,8 +35,9 @@ func (cfg *apiConfig) HandleAppMain(w http.ResponseWriter, req *http.Request, us
 	}
 
 	responseData := ResponseData{
-		Catalog:  catalog,
-		UserList: userLists,
+		Catalog:     catalog,
+		UserList:    userLists,
+		Suggestions: cfg.suggestionsOrEmpty(req.Context(), userID),
 	}
 
 	mainTmpl.Execute(w, responseData)
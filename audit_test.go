@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var auditEventCols = []string{"id", "created_at", "actor_id", "action", "target", "ip", "user_agent", "outcome"}
+
+func TestHandleGetSecurityActivity(t *testing.T) {
+	userID := uuid.New()
+	var asked, limit driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetRecentSecurityActivity": func(args []driver.NamedValue) fakeResult {
+			asked, limit = args[0].Value, args[1].Value
+			return fakeResult{cols: auditEventCols, rows: [][]driver.Value{
+				{int64(8), time.Now(), userID.String(), "login.password", "ana@example.com", "198.51.100.4", "Firefox", "success"},
+				{int64(7), time.Now(), uuid.NewString(), "admin.user.password_reset", userID.String(), "203.0.113.9", "curl", "success"},
+			}}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleGetSecurityActivity(rr, httptest.NewRequest("GET", "/api/account/activity?limit=20", nil), userID)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if asked != userID.String() || limit != int64(20) {
+		t.Fatalf("want the user's own events, got %v limit %v", asked, limit)
+	}
+	var resp []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 2 || resp[1]["action"] != "admin.user.password_reset" {
+		t.Fatalf("want both events, got %s", rr.Body.String())
+	}
+	if resp[0]["ip"] != "198.51.100.4" || resp[0]["user_agent"] != "Firefox" {
+		t.Fatalf("the user's own events keep their address, got %s", rr.Body.String())
+	}
+	if _, ok := resp[1]["actor_id"]; ok || resp[1]["ip"] != "" || resp[1]["user_agent"] != "" {
+		t.Fatalf("users should not learn who else acted or from where, got %s", rr.Body.String())
+	}
+}
+
+func TestHandleGetSecurityActivity_BadLimit(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleGetSecurityActivity(rr, httptest.NewRequest("GET", "/api/account/activity?limit=0", nil), uuid.New())
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+	if fdb.called("GetRecentSecurityActivity") {
+		t.Fatalf("a bad limit should not reach the database")
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"errors"
+	"log"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/henrique-godinho/smart-list/internal/auth"
 	"github.com/henrique-godinho/smart-list/internal/database"
 	"github.com/lib/pq"
@@ -38,6 +40,13 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	attempts := cfg.signupAttempts(req)
+	if wait := attemptWait(req.Context(), attempts); wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+	cfg.failAttempt(req, uuid.Nil, attempts)
+
 	firstName := req.PostForm.Get("firstName")
 	lastName := req.PostForm.Get("lastName")
 	email := req.PostForm.Get("email")
@@ -69,7 +78,7 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, req *http.Request)
 		HashedPassword: hashedPwd,
 	}
 
-	_, err = cfg.Db.CreateUser(req.Context(), params)
+	user, err := cfg.Db.CreateUser(req.Context(), params)
 
 	if err != nil {
 		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
@@ -79,6 +88,11 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, req *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "failed to create user", err)
 		return
 	}
+	cfg.audit(req.Context(), req, user.ID, "signup", user.ID.String(), "success")
+
+	if err = cfg.sendVerificationEmail(req.Context(), user.ID, user.Email, user.FirstName); err != nil {
+		log.Printf("email verification: %v", err)
+	}
 
 	http.Redirect(w, req, "/login.html?created=1", http.StatusSeeOther)
 
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+)
+
+const (
+	verificationLinkTTL = 48 * time.Hour
+
+	// at most one verification email per account in this window, however
+	// often the resend form is submitted
+	verificationResendInterval = 2 * time.Minute
+)
+
+// What an unverified account is kept from, set with EMAIL_VERIFICATION.
+const (
+	verifyNone    = "none"
+	verifySharing = "sharing"
+	verifyLogin   = "login"
+)
+
+var errEmailUnverified = errors.New("please verify your email address first")
+
+// sendVerificationEmail mails userID a link proving they own email, unless
+// one went out moments ago or the address is already verified.
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, userID uuid.UUID, email, firstName string) error {
+	claimed, err := cfg.Db.ClaimVerificationSend(ctx, database.ClaimVerificationSendParams{
+		ID:                 userID,
+		VerificationSentAt: sql.NullTime{Time: time.Now().Add(-verificationResendInterval), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return nil
+	}
+
+	token, err := cfg.Keys.MakeEmailToken(userID, email, verificationLinkTTL)
+	if err != nil {
+		return err
+	}
+
+	link := cfg.Origin + "/verify-email?token=" + url.QueryEscape(token)
+	cfg.sendMail(mailer.Message{
+		To:      email,
+		Subject: "Confirm your Smart List email address",
+		Body: fmt.Sprintf(`Hi %s,
+
+Please confirm that this is your email address by opening this link within
+the next two days:
+
+%s
+
+If you didn't create a Smart List account, you can ignore this email.
+`, firstName, link),
+	})
+	return nil
+}
+
+// HandleVerifyEmail is where the link in a verification email lands.
+func (cfg *apiConfig) HandleVerifyEmail(w http.ResponseWriter, req *http.Request) {
+	userID, email, err := cfg.Keys.ValidateEmailToken(req.URL.Query().Get("token"))
+	if err != nil {
+		http.Redirect(w, req, "/login.html?verified=invalid", http.StatusSeeOther)
+		return
+	}
+
+	// a link for an address the account no longer has matches nothing
+	n, err := cfg.Db.SetEmailVerified(req.Context(), database.SetEmailVerifiedParams{
+		ID:    userID,
+		Email: email,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to verify email address", err)
+		return
+	}
+	if n == 0 {
+		http.Redirect(w, req, "/login.html?verified=invalid", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, req, "/login.html?verified=1", http.StatusSeeOther)
+}
+
+// HandleResendVerification sends a fresh verification link. The response is
+// the same whether or not the address is known, verified or throttled.
+func (cfg *apiConfig) HandleResendVerification(w http.ResponseWriter, req *http.Request) {
+	if !cfg.parseAuthForm(w, req) {
+		return
+	}
+
+	_, _, email, err := auth.ValidateInput("login", map[string]string{
+		"email": req.PostForm.Get("email"),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err := cfg.resendVerification(req.Context(), email); err != nil {
+		log.Printf("email verification: %v", err)
+	}
+
+	http.Redirect(w, req, "/login.html?verification=sent", http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) resendVerification(ctx context.Context, email string) error {
+	user, err := cfg.Db.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive || user.EmailVerifiedAt.Valid {
+		return nil
+	}
+
+	return cfg.sendVerificationEmail(ctx, user.ID, user.Email, user.FirstName)
+}
+
+// requireVerifiedEmail keeps unverified accounts out of list sharing. Under
+// the login policy they can't sign in in the first place, but sessions from
+// before the policy was turned on are still caught here.
+func (cfg *apiConfig) requireVerifiedEmail(ctx context.Context, userID uuid.UUID) error {
+	if cfg.EmailVerification != verifySharing && cfg.EmailVerification != verifyLogin {
+		return nil
+	}
+
+	verifiedAt, err := cfg.Db.GetEmailVerifiedAt(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !verifiedAt.Valid {
+		return errEmailUnverified
+	}
+	return nil
+}
+
+func respondWithVerificationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errEmailUnverified) {
+		respondWithError(w, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "failed to check email verification", err)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+)
+
+func unverifiedUser(userID uuid.UUID, email, hash string) fakeHandler {
+	return func([]driver.NamedValue) fakeResult {
+		now := time.Now()
+		return fakeRow(userByEmailCols, userID.String(), email, "Jane", "Doe", true, now, now, hash, nil)
+	}
+}
+
+func TestHandleCreateUser_SendsVerificationLink(t *testing.T) {
+	userID := uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"CreateUser": func(args []driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow([]string{"id", "created_at", "updated_at", "email", "hashed_password", "is_active", "first_name", "last_name", "email_verified_at", "verification_sent_at", "role"},
+				userID.String(), now, now, args[0].Value, args[1].Value, true, args[2].Value, args[3].Value, nil, nil, "user")
+		},
+		"ClaimVerificationSend": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+	sent := make(chanMailer, 1)
+	cfg.Mailer = sent
+
+	rr := httptest.NewRecorder()
+	cfg.HandleCreateUser(rr, formRequest("/register", url.Values{
+		"firstName": {"Jane"},
+		"lastName":  {"Doe"},
+		"email":     {"jane@example.com"},
+		"password":  {"correct horse"},
+	}))
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	var msg mailer.Message
+	select {
+	case msg = <-sent:
+	case <-time.After(time.Second):
+		t.Fatalf("no email sent")
+	}
+
+	i := strings.Index(msg.Body, "token=")
+	if i < 0 {
+		t.Fatalf("email has no verification link:\n%s", msg.Body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(msg.Body[i+len("token="):])[0])
+	if err != nil {
+		t.Fatalf("unescape: %v", err)
+	}
+	gotID, gotEmail, err := auth.ValidateEmailToken(token, testJWTKey)
+	if err != nil || gotID != userID || gotEmail != "jane@example.com" {
+		t.Fatalf("link should verify jane@example.com for the new user, got %s/%s, %v", gotID, gotEmail, err)
+	}
+}
+
+func TestHandleVerifyEmail(t *testing.T) {
+	userID := uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"SetEmailVerified": func(args []driver.NamedValue) fakeResult {
+			if args[0].Value != userID.String() || args[1].Value != "jane@example.com" {
+				return fakeResult{affected: 0}
+			}
+			return fakeResult{affected: 1}
+		},
+	})
+
+	verify := func(email string) string {
+		token, err := auth.MakeEmailToken(userID, email, testJWTKey, time.Hour)
+		if err != nil {
+			t.Fatalf("MakeEmailToken: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		cfg.HandleVerifyEmail(rr, httptest.NewRequest("GET", "/verify-email?token="+url.QueryEscape(token), nil))
+		return rr.Header().Get("Location")
+	}
+
+	if got := verify("jane@example.com"); got != "/login.html?verified=1" {
+		t.Fatalf("valid link: got %q", got)
+	}
+	if got := verify("old@example.com"); got != "/login.html?verified=invalid" {
+		t.Fatalf("link for an address the account no longer has: got %q", got)
+	}
+
+	rr := httptest.NewRecorder()
+	cfg.HandleVerifyEmail(rr, httptest.NewRequest("GET", "/verify-email?token=garbage", nil))
+	if got := rr.Header().Get("Location"); got != "/login.html?verified=invalid" {
+		t.Fatalf("garbage link: got %q", got)
+	}
+}
+
+func TestHandleResendVerification_Throttled(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByEmail":        unverifiedUser(uuid.New(), "jane@example.com", "hash"),
+		"ClaimVerificationSend": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 0} },
+	})
+	sent := make(chanMailer, 1)
+	cfg.Mailer = sent
+
+	rr := httptest.NewRecorder()
+	cfg.HandleResendVerification(rr, formRequest("/resend-verification", url.Values{"email": {"jane@example.com"}}))
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/login.html?verification=sent" {
+		t.Fatalf("want the usual redirect, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if !fdb.called("ClaimVerificationSend") {
+		t.Fatalf("the resend should go through the throttle")
+	}
+	select {
+	case <-sent:
+		t.Fatalf("no email should be sent while throttled")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHandleLogin_UnverifiedBlockedByPolicy(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByEmail": unverifiedUser(uuid.New(), "jane@example.com", hash),
+	})
+	cfg.EmailVerification = verifyLogin
+
+	rr := httptest.NewRecorder()
+	cfg.HandleLogin(rr, formRequest("/login", url.Values{"email": {"jane@example.com"}, "password": {"correct horse"}}))
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("status: want 403, got %d", rr.Code)
+	}
+	if fdb.called("CreateSession") {
+		t.Fatalf("no session should be started for an unverified account")
+	}
+}
+
+func TestHandleInviteListMember_UnverifiedBlockedByPolicy(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":      listSharedWith(listID, map[uuid.UUID]string{owner: "owner"}),
+		"GetEmailVerifiedAt": func([]driver.NamedValue) fakeResult { return fakeRow([]string{"email_verified_at"}, nil) },
+	})
+	cfg.EmailVerification = verifySharing
+
+	rr := httptest.NewRecorder()
+	cfg.HandleInviteListMember(rr, inviteRequest(listID, `{"email":"friend@example.com","role":"viewer"}`), owner)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("status: want 403, got %d", rr.Code)
+	}
+	if fdb.called("AddListMember") {
+		t.Fatalf("unverified accounts must not share lists")
+	}
+}
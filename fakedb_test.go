@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+// fakeResult is what a fake query handler returns: either rows for queries or
+// an affected-rows count for execs.
+type fakeResult struct {
+	cols     []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+type fakeHandler func(args []driver.NamedValue) fakeResult
+
+// fakeDB is a minimal database/sql driver that dispatches sqlc queries by their
+// "-- name:" header, so handlers can be tested without a Postgres instance.
+type fakeDB struct {
+	mu        sync.Mutex
+	handlers  map[string]fakeHandler
+	calls     []string
+	commitErr error
+}
+
+var queryNameRe = regexp.MustCompile(`-- name: (\w+)`)
+
+func newFakeConfig(t *testing.T, handlers map[string]fakeHandler) (*apiConfig, *fakeDB) {
+	t.Helper()
+	fdb := &fakeDB{handlers: handlers}
+	db := sql.OpenDB(fdb)
+	t.Cleanup(func() { db.Close() })
+
+	return &apiConfig{
+		Sql:    db,
+		Db:     database.New(db),
+		Keys:   auth.LegacyKeyring(testJWTKey),
+		Origin: "http://website.com",
+	}, fdb
+}
+
+func (f *fakeDB) called(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.calls {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) fakeResult {
+	name := query
+	if m := queryNameRe.FindStringSubmatch(query); m != nil {
+		name = m[1]
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, name)
+	h, ok := f.handlers[name]
+	f.mu.Unlock()
+
+	if !ok {
+		return fakeResult{err: fmt.Errorf("fakedb: unexpected query %s", name)}
+	}
+	return h(args)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("fakedb: use sql.OpenDB")
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fakedb: prepared statements not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{db: c.db}, nil }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.db.run(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &fakeRows{cols: res.cols, rows: res.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.db.run(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx fakeTx) Commit() error { return tx.db.commitErr }
+func (fakeTx) Rollback() error  { return nil }
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// fakeRow is a shorthand for a single-row result with the given columns.
+func fakeRow(cols []string, values ...driver.Value) fakeResult {
+	return fakeResult{cols: cols, rows: [][]driver.Value{values}}
+}
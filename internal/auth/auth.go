@@ -2,7 +2,6 @@ package auth
 
 import (
 	"errors"
-	"fmt"
 	"mime"
 	"net/http"
 	"net/mail"
@@ -12,71 +11,25 @@ import (
 	"unicode"
 	"unicode/utf8"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/text/unicode/norm"
 )
 
 var nameRe = regexp.MustCompile(`^\p{L}+(?:[- ]\p{L}+)*$`)
 
-func HashPassword(pwd string) (string, error) {
-	if len(pwd) < 8 {
-		return "", fmt.Errorf("password too short")
-	}
-
-	if len(pwd) > 72 {
-		return "", fmt.Errorf("password too long")
-	}
-
-	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
-	if err != nil {
-		return "", fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	return string(hash), nil
-}
-
-func CheckPasswordHash(hash, pwd string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pwd))
-
-	if err != nil {
-		return fmt.Errorf("invalid password")
-	}
-
-	return nil
-}
-
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Issuer:    "smart-list",
-		Subject:   userID.String(),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(tokenSecret))
+// MakeSessionJWT is Keyring.MakeSessionJWT for a single HMAC secret.
+func MakeSessionJWT(userID, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return LegacyKeyring(tokenSecret).MakeSessionJWT(userID, sessionID, expiresIn)
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	},
-		jwt.WithValidMethods([]string{"HS256"}),
-		jwt.WithIssuer("smart-list"),
-		jwt.WithIssuedAt(),
-	)
-
-	if err != nil {
-		return uuid.Nil, err
-	}
+	userID, _, err := ValidateSessionJWT(tokenString, tokenSecret)
+	return userID, err
+}
 
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
-		id, err := uuid.Parse(claims.Subject)
-		return id, err
-	}
-	return uuid.Nil, err
+// ValidateSessionJWT is Keyring.ValidateSessionJWT for a single HMAC secret.
+func ValidateSessionJWT(tokenString, tokenSecret string) (userID, sessionID uuid.UUID, err error) {
+	return LegacyKeyring(tokenSecret).ValidateSessionJWT(tokenString)
 }
 
 func EnforceMediaType(s string, req *http.Request) error {
@@ -118,28 +71,12 @@ func ValidateInput(s string, data map[string]string) (cleanFirstName, cleanLastN
 
 	case "signup":
 
-		firstName := strings.TrimSpace(data["firstName"])
-		lastName := strings.TrimSpace(data["lastName"])
-		email := strings.TrimSpace(data["email"])
-
-		firstName = norm.NFC.String(firstName)
-		lastName = norm.NFC.String(lastName)
-
-		const maxNameLen = 50
-
-		for _, name := range []string{firstName, lastName} {
-			if utf8.RuneCountInString(name) > maxNameLen {
-				return "", "", "", errors.New("first/last name must be 50 characters maximum")
-			}
-
-			if strings.IndexFunc(name, unicode.IsControl) >= 0 {
-				return "", "", "", errors.New("invalid characters in first/last name")
-			}
+		firstName, lastName, err := validateNames(data["firstName"], data["lastName"])
+		if err != nil {
+			return "", "", "", err
 		}
 
-		if !nameRe.MatchString(firstName) || !nameRe.MatchString(lastName) {
-			return "", "", "", errors.New("first/last names can only contain letters with single spaces or hyphens between parts")
-		}
+		email := strings.TrimSpace(data["email"])
 
 		if strings.IndexFunc(email, unicode.IsControl) >= 0 {
 			return "", "", "", errors.New("invalid characters on email")
@@ -166,11 +103,57 @@ func ValidateInput(s string, data map[string]string) (cleanFirstName, cleanLastN
 
 		return "", "", emailParsed.Address, nil
 
+	case "profile":
+		firstName, lastName, err := validateNames(data["firstName"], data["lastName"])
+		if err != nil {
+			return "", "", "", err
+		}
+
+		return firstName, lastName, "", nil
+
 	}
 
 	return "", "", "", nil
 }
 
+func validateNames(firstName, lastName string) (string, string, error) {
+	firstName = norm.NFC.String(strings.TrimSpace(firstName))
+	lastName = norm.NFC.String(strings.TrimSpace(lastName))
+
+	const maxNameLen = 50
+
+	for _, name := range []string{firstName, lastName} {
+		if utf8.RuneCountInString(name) > maxNameLen {
+			return "", "", errors.New("first/last name must be 50 characters maximum")
+		}
+
+		if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			return "", "", errors.New("invalid characters in first/last name")
+		}
+	}
+
+	if !nameRe.MatchString(firstName) || !nameRe.MatchString(lastName) {
+		return "", "", errors.New("first/last names can only contain letters with single spaces or hyphens between parts")
+	}
+
+	return firstName, lastName, nil
+}
+
+// GetBearerToken returns the token of an "Authorization: Bearer" header.
+func GetBearerToken(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("no authorization header")
+	}
+
+	scheme, token, ok := strings.Cut(authHeader, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", errors.New("malformed authorization header")
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
 func GetJWTCookie(req *http.Request) (string, error) {
 	jwtCookie, err := req.Cookie("sl_auth")
 	if err != nil {
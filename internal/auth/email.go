@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const emailVerificationAudience = "email-verification"
+
+type emailClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// MakeEmailToken is Keyring.MakeEmailToken for a single HMAC secret.
+func MakeEmailToken(userID uuid.UUID, email, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return LegacyKeyring(tokenSecret).MakeEmailToken(userID, email, expiresIn)
+}
+
+// ValidateEmailToken is Keyring.ValidateEmailToken for a single HMAC secret.
+func ValidateEmailToken(tokenString, tokenSecret string) (uuid.UUID, string, error) {
+	return LegacyKeyring(tokenSecret).ValidateEmailToken(tokenString)
+}
+
+// MakeEmailToken signs the claim that userID owns email, for the link in a
+// verification email.
+func (r *Keyring) MakeEmailToken(userID uuid.UUID, email string, expiresIn time.Duration) (string, error) {
+	claims := emailClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "smart-list",
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		},
+	}
+
+	return r.signPurposeToken(claims, emailVerificationAudience)
+}
+
+// ValidateEmailToken returns the user and the address a verification link
+// was made for.
+func (r *Keyring) ValidateEmailToken(tokenString string) (uuid.UUID, string, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &emailClaims{}, r.purposeKeyfunc(emailVerificationAudience),
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithIssuer("smart-list"),
+		jwt.WithAudience(emailVerificationAudience),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	claims, ok := token.Claims.(*emailClaims)
+	if !ok || !token.Valid || claims.Email == "" {
+		return uuid.Nil, "", errors.New("invalid token")
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	return userID, claims.Email, nil
+}
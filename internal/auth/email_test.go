@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEmailToken_RoundTrip(t *testing.T) {
+	uid := uuid.New()
+	tok, err := MakeEmailToken(uid, "jane@example.com", testSecretA, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailToken err: %v", err)
+	}
+
+	gotID, gotEmail, err := ValidateEmailToken(tok, testSecretA)
+	if err != nil {
+		t.Fatalf("ValidateEmailToken err: %v", err)
+	}
+	if gotID != uid || gotEmail != "jane@example.com" {
+		t.Fatalf("want %s/jane@example.com, got %s/%s", uid, gotID, gotEmail)
+	}
+
+	if _, _, err := ValidateEmailToken(tok, testSecretB); err == nil {
+		t.Fatalf("expected error with the wrong key")
+	}
+}
+
+func TestEmailToken_NotAnAccessToken(t *testing.T) {
+	uid := uuid.New()
+
+	link, err := MakeEmailToken(uid, "jane@example.com", testSecretA, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailToken err: %v", err)
+	}
+	if _, err := ValidateJWT(link, testSecretA); err == nil {
+		t.Fatalf("a verification link must not work as an access token")
+	}
+
+	access, err := MakeSessionJWT(uid, uuid.New(), testSecretA, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT err: %v", err)
+	}
+	if _, _, err := ValidateEmailToken(access, testSecretA); err == nil {
+		t.Fatalf("an access token must not verify an email address")
+	}
+}
+
+func TestEmailToken_Expired(t *testing.T) {
+	tok, err := MakeEmailToken(uuid.New(), "jane@example.com", testSecretA, -time.Minute)
+	if err != nil {
+		t.Fatalf("MakeEmailToken err: %v", err)
+	}
+	if _, _, err := ValidateEmailToken(tok, testSecretA); err == nil {
+		t.Fatalf("expected expiry error, got nil")
+	}
+}
@@ -13,9 +13,9 @@ const testSecretB = "abcdef0123456789abcdef0123456789"
 
 func TestJWT_HappyPath(t *testing.T) {
 	uid := uuid.New()
-	tok, err := MakeJWT(uid, testSecretA, 2*time.Minute)
+	tok, err := MakeSessionJWT(uid, uuid.New(), testSecretA, 2*time.Minute)
 	if err != nil {
-		t.Fatalf("MakeJWT err: %v", err)
+		t.Fatalf("MakeSessionJWT err: %v", err)
 	}
 	gotID, err := ValidateJWT(tok, testSecretA)
 	if err != nil {
@@ -28,9 +28,9 @@ func TestJWT_HappyPath(t *testing.T) {
 
 func TestJWT_Expired(t *testing.T) {
 	uid := uuid.New()
-	tok, err := MakeJWT(uid, testSecretA, -1*time.Minute) // already expired
+	tok, err := MakeSessionJWT(uid, uuid.New(), testSecretA, -1*time.Minute) // already expired
 	if err != nil {
-		t.Fatalf("MakeJWT err: %v", err)
+		t.Fatalf("MakeSessionJWT err: %v", err)
 	}
 	if _, err := ValidateJWT(tok, testSecretA); err == nil {
 		t.Fatalf("expected expiry error, got nil")
@@ -39,9 +39,9 @@ func TestJWT_Expired(t *testing.T) {
 
 func TestJWT_WrongKey(t *testing.T) {
 	uid := uuid.New()
-	tok, err := MakeJWT(uid, testSecretA, 2*time.Minute)
+	tok, err := MakeSessionJWT(uid, uuid.New(), testSecretA, 2*time.Minute)
 	if err != nil {
-		t.Fatalf("MakeJWT err: %v", err)
+		t.Fatalf("MakeSessionJWT err: %v", err)
 	}
 	if _, err := ValidateJWT(tok, testSecretB); err == nil {
 		t.Fatalf("expected signature error with wrong key")
@@ -91,9 +91,9 @@ func TestJWT_Malformed(t *testing.T) {
 
 func TestJWT_Tampered(t *testing.T) {
 	uid := uuid.New()
-	tok, err := MakeJWT(uid, testSecretA, 2*time.Minute)
+	tok, err := MakeSessionJWT(uid, uuid.New(), testSecretA, 2*time.Minute)
 	if err != nil {
-		t.Fatalf("MakeJWT err: %v", err)
+		t.Fatalf("MakeSessionJWT err: %v", err)
 	}
 	// Flip one rune (still a string; may become invalid base64 or bad signature—both are fine).
 	tampered := tok[:len(tok)-1] + "A"
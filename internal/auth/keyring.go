@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	minHMACKeyLen = 32
+	minRSAKeyBits = 2048
+)
+
+// SigningKey is one key of a Keyring. HMAC keys and private keys can sign,
+// public keys only verify.
+type SigningKey struct {
+	ID     string
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+	// secret is what the keys of single-purpose tokens are derived from;
+	// public keys have none
+	secret []byte
+}
+
+// Algorithm is the JWS algorithm of the key, e.g. HS256 or EdDSA.
+func (k *SigningKey) Algorithm() string {
+	return k.method.Alg()
+}
+
+func (k *SigningKey) CanSign() bool {
+	return k.sign != nil
+}
+
+// NewHMACKey makes an HS256 key from a shared secret.
+func NewHMACKey(id string, secret []byte) (*SigningKey, error) {
+	if len(secret) < minHMACKeyLen {
+		return nil, fmt.Errorf("key %q: HMAC secret must be at least %d bytes", id, minHMACKeyLen)
+	}
+	return &SigningKey{ID: id, method: jwt.SigningMethodHS256, sign: secret, verify: secret, secret: secret}, nil
+}
+
+// ParsePEMKey reads an Ed25519 or RSA key from PEM: a PKCS #8 private key
+// signs with EdDSA or RS256, a PKIX public key only verifies, e.g. one that
+// was retired after its private half was deleted.
+func ParsePEMKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM data", id)
+	}
+
+	var private crypto.Signer
+	var public crypto.PublicKey
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("key %q: unsupported private key", id)
+		}
+		private, public = signer, signer.Public()
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		private, public = key, key.Public()
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		public = key
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+
+	k := &SigningKey{ID: id, verify: public}
+	if private != nil {
+		k.sign = private
+		k.secret = block.Bytes
+	}
+
+	switch pub := public.(type) {
+	case ed25519.PublicKey:
+		k.method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("key %q: RSA keys must have at least %d bits", id, minRSAKeyBits)
+		}
+		k.method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("key %q: only Ed25519 and RSA keys are supported", id)
+	}
+
+	return k, nil
+}
+
+// Keyring signs access tokens with its active key and accepts tokens signed
+// with any of its keys, found by the kid header. Keys are rotated by adding
+// a new one, making it active, and dropping the old one once the tokens it
+// signed have expired.
+type Keyring struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeyring makes a keyring signing with the key activeID.
+func NewKeyring(activeID string, keys ...*SigningKey) (*Keyring, error) {
+	r := &Keyring{keys: make(map[string]*SigningKey, len(keys))}
+	for _, k := range keys {
+		if _, ok := r.keys[k.ID]; ok {
+			return nil, fmt.Errorf("key %q is listed twice", k.ID)
+		}
+		r.keys[k.ID] = k
+	}
+
+	active, ok := r.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", activeID)
+	}
+	if !active.CanSign() {
+		return nil, fmt.Errorf("active key %q can't sign, it is a public key", activeID)
+	}
+	r.active = active
+
+	return r, nil
+}
+
+// LegacyKey is the HMAC secret tokens were signed with before keyrings
+// existed. It has no key id, and no minimum length: it was never enforced.
+func LegacyKey(tokenSecret string) *SigningKey {
+	return &SigningKey{method: jwt.SigningMethodHS256, sign: []byte(tokenSecret), verify: []byte(tokenSecret), secret: []byte(tokenSecret)}
+}
+
+// LegacyKeyring is the keyring of LegacyKey alone.
+func LegacyKeyring(tokenSecret string) *Keyring {
+	k := LegacyKey(tokenSecret)
+	return &Keyring{active: k, keys: map[string]*SigningKey{"": k}}
+}
+
+func (r *Keyring) signToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.active.method, claims)
+	if r.active.ID != "" {
+		token.Header["kid"] = r.active.ID
+	}
+	return token.SignedString(r.active.sign)
+}
+
+func (r *Keyring) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	// the algorithm comes from the key, never from the token
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+	}
+	return k.verify, nil
+}
+
+// signPurposeToken signs a single-purpose token with a key derived from the
+// active key, so that rotating the keyring rotates these tokens too.
+func (r *Keyring) signPurposeToken(claims jwt.Claims, purpose string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if r.active.ID != "" {
+		token.Header["kid"] = r.active.ID
+	}
+	return token.SignedString(purposeKey(r.active.secret, purpose))
+}
+
+func (r *Keyring) purposeKeyfunc(purpose string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, ok := r.keys[kid]
+		if !ok || k.secret == nil {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		return purposeKey(k.secret, purpose), nil
+	}
+}
+
+func (r *Keyring) methods() []string {
+	seen := map[string]bool{}
+	var methods []string
+	for _, k := range r.keys {
+		if alg := k.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// MakeSessionJWT makes an access token tied to a server-side session; the
+// session id travels in the jti claim.
+func (r *Keyring) MakeSessionJWT(userID, sessionID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    "smart-list",
+		Subject:   userID.String(),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		ID:        sessionID.String(),
+	}
+
+	return r.signToken(claims)
+}
+
+// ValidateSessionJWT returns the user and session of an access token. Tokens
+// without a session are refused, since they could never be revoked.
+func (r *Keyring) ValidateSessionJWT(tokenString string) (userID, sessionID uuid.UUID, err error) {
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, r.keyfunc,
+		jwt.WithValidMethods(r.methods()),
+		jwt.WithIssuer("smart-list"),
+		jwt.WithIssuedAt(),
+	)
+
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, uuid.Nil, errors.New("invalid token")
+	}
+
+	userID, err = uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	sessionID, err = uuid.Parse(claims.ID)
+	if err != nil || sessionID == uuid.Nil {
+		return uuid.Nil, uuid.Nil, errors.New("token has no session")
+	}
+
+	return userID, sessionID, nil
+}
+
+// JWK is a public key as published in a JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS returns the public keys of the keyring, for other services to verify
+// access tokens with. HMAC keys are secret and never part of it.
+func (r *Keyring) JWKS() []JWK {
+	keys := []JWK{}
+	for _, k := range r.keys {
+		switch pub := k.verify.(type) {
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "OKP",
+				Kid: k.ID,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: k.ID,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
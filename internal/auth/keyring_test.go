@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func hmacKey(t *testing.T, id, secret string) *SigningKey {
+	t.Helper()
+	k, err := NewHMACKey(id, []byte(secret))
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+	return k
+}
+
+func pemKey(t *testing.T, id string, key any, public bool) *SigningKey {
+	t.Helper()
+	var block *pem.Block
+	if public {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	k, err := ParsePEMKey(id, pem.EncodeToMemory(block))
+	if err != nil {
+		t.Fatalf("ParsePEMKey: %v", err)
+	}
+	return k
+}
+
+func keyring(t *testing.T, active string, keys ...*SigningKey) *Keyring {
+	t.Helper()
+	r, err := NewKeyring(active, keys...)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return r
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	uid, sid := uuid.New(), uuid.New()
+	old := hmacKey(t, "k1", testSecretA)
+	next := hmacKey(t, "k2", testSecretB)
+
+	before := keyring(t, "k1", old)
+	tok, err := before.MakeSessionJWT(uid, sid, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT: %v", err)
+	}
+
+	after := keyring(t, "k2", old, next)
+	gotUser, gotSession, err := after.ValidateSessionJWT(tok)
+	if err != nil || gotUser != uid || gotSession != sid {
+		t.Fatalf("a token of the previous key should still work: %s/%s, %v", gotUser, gotSession, err)
+	}
+
+	fresh, _ := after.MakeSessionJWT(uid, sid, time.Minute)
+	parsed, _, _ := jwt.NewParser().ParseUnverified(fresh, &jwt.RegisteredClaims{})
+	if parsed.Header["kid"] != "k2" {
+		t.Fatalf("new tokens should be signed by the active key, got kid %v", parsed.Header["kid"])
+	}
+
+	retired := keyring(t, "k2", next)
+	if _, _, err := retired.ValidateSessionJWT(tok); err == nil {
+		t.Fatalf("a token of a dropped key must not work")
+	}
+}
+
+func TestKeyring_LegacyTokens(t *testing.T) {
+	uid := uuid.New()
+	tok, err := MakeSessionJWT(uid, uuid.New(), testSecretA, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT: %v", err)
+	}
+
+	r := keyring(t, "k1", hmacKey(t, "k1", testSecretB), LegacyKey(testSecretA))
+	if got, _, err := r.ValidateSessionJWT(tok); err != nil || got != uid {
+		t.Fatalf("tokens without a kid should verify with the legacy key: %s, %v", got, err)
+	}
+}
+
+func TestKeyring_PurposeTokens(t *testing.T) {
+	uid := uuid.New()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	old := pemKey(t, "ed", priv, false)
+	next := hmacKey(t, "k2", testSecretB)
+
+	before := keyring(t, "ed", old)
+	link, err := before.MakeEmailToken(uid, "a@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailToken: %v", err)
+	}
+	if _, _, err := before.ValidateSessionJWT(link); err == nil {
+		t.Fatalf("an email link must not pass as an access token")
+	}
+
+	during := keyring(t, "k2", old, next)
+	if got, _, err := during.ValidateEmailToken(link); err != nil || got != uid {
+		t.Fatalf("links of a key still listed should work: %s, %v", got, err)
+	}
+
+	after := keyring(t, "k2", next)
+	if _, _, err := after.ValidateEmailToken(link); err == nil {
+		t.Fatalf("links of a dropped key must not work")
+	}
+}
+
+func TestKeyring_EdDSA(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	uid := uuid.New()
+
+	signer := keyring(t, "ed", pemKey(t, "ed", priv, false), hmacKey(t, "hs", testSecretA))
+	tok, err := signer.MakeSessionJWT(uid, uuid.New(), time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT: %v", err)
+	}
+
+	// another service only has the public key
+	verifier := keyring(t, "hs", hmacKey(t, "hs", testSecretA), pemKey(t, "ed", pub, true))
+	if got, _, err := verifier.ValidateSessionJWT(tok); err != nil || got != uid {
+		t.Fatalf("ValidateSessionJWT: %s, %v", got, err)
+	}
+
+	jwks := signer.JWKS()
+	if len(jwks) != 1 || jwks[0].Kid != "ed" || jwks[0].Kty != "OKP" || jwks[0].Alg != "EdDSA" {
+		t.Fatalf("JWKS should hold the Ed25519 key alone, never the HMAC one: %+v", jwks)
+	}
+}
+
+func TestKeyring_RS256(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	uid := uuid.New()
+
+	r := keyring(t, "rs", pemKey(t, "rs", priv, false))
+	tok, err := r.MakeSessionJWT(uid, uuid.New(), time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT: %v", err)
+	}
+	if got, _, err := r.ValidateSessionJWT(tok); err != nil || got != uid {
+		t.Fatalf("ValidateSessionJWT: %s, %v", got, err)
+	}
+
+	jwks := r.JWKS()
+	if len(jwks) != 1 || jwks[0].Kty != "RSA" || jwks[0].Alg != "RS256" || jwks[0].E != "AQAB" {
+		t.Fatalf("unexpected JWKS: %+v", jwks)
+	}
+}
+
+func TestKeyring_AlgorithmConfusion(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	r := keyring(t, "ed", pemKey(t, "ed", priv, false), hmacKey(t, "hs", testSecretA))
+
+	// an HMAC token keyed with the public key, claiming the Ed25519 key id
+	claims := jwt.RegisteredClaims{
+		Issuer:    "smart-list",
+		Subject:   uuid.NewString(),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "ed"
+	forged, err := token.SignedString([]byte(pub))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	if _, _, err := r.ValidateSessionJWT(forged); err == nil {
+		t.Fatalf("a token must not pick its key's algorithm")
+	}
+}
+
+func TestNewKeyring_Invalid(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	if _, err := NewKeyring("missing", hmacKey(t, "k1", testSecretA)); err == nil {
+		t.Fatalf("want an error for an unknown active key")
+	}
+	if _, err := NewKeyring("pub", pemKey(t, "pub", pub, true)); err == nil {
+		t.Fatalf("want an error for a public key as the signer")
+	}
+	if _, err := NewKeyring("k1", hmacKey(t, "k1", testSecretA), hmacKey(t, "k1", testSecretB)); err == nil {
+		t.Fatalf("want an error for a key listed twice")
+	}
+	if _, err := NewHMACKey("short", []byte("too short")); err == nil {
+		t.Fatalf("want an error for a short HMAC secret")
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	mfaPendingAudience = "mfa-pending"
+	mfaCookieName      = "sl_mfa"
+)
+
+// MakeMFAPendingJWT is Keyring.MakeMFAPendingJWT for a single HMAC secret.
+func MakeMFAPendingJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return LegacyKeyring(tokenSecret).MakeMFAPendingJWT(userID, expiresIn)
+}
+
+// ValidateMFAPendingJWT is Keyring.ValidateMFAPendingJWT for a single HMAC
+// secret.
+func ValidateMFAPendingJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return LegacyKeyring(tokenSecret).ValidateMFAPendingJWT(tokenString)
+}
+
+// MakeMFAPendingJWT is handed out when the password was right but a second
+// factor is still owed. It is signed with its own key, so it is no access
+// token and middlewareAuth turns it away.
+func (r *Keyring) MakeMFAPendingJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    "smart-list",
+		Subject:   userID.String(),
+		Audience:  jwt.ClaimStrings{mfaPendingAudience},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+	}
+
+	return r.signPurposeToken(claims, mfaPendingAudience)
+}
+
+func (r *Keyring) ValidateMFAPendingJWT(tokenString string) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, r.purposeKeyfunc(mfaPendingAudience),
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithIssuer("smart-list"),
+		jwt.WithAudience(mfaPendingAudience),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, errors.New("invalid token")
+	}
+
+	return uuid.Parse(claims.Subject)
+}
+
+func GetMFACookie(req *http.Request) (string, error) {
+	mfaCookie, err := req.Cookie(mfaCookieName)
+	if err != nil {
+		return "", err
+	}
+
+	return mfaCookie.Value, nil
+}
+
+func MakeMFACookie(token string, ttl time.Duration, secure bool) *http.Cookie {
+	mfaCookie := http.Cookie{
+		Name:     mfaCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl / time.Second),
+		Expires:  time.Now().Add(ttl),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	return &mfaCookie
+}
+
+func ClearMFACookie(secure bool) *http.Cookie {
+	mfaCookie := http.Cookie{
+		Name:     mfaCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	return &mfaCookie
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLen = 8
+	// long passphrases are welcome, unbounded input to a slow hash is not
+	maxPasswordLen = 1024
+)
+
+var errInvalidPassword = errors.New("invalid password")
+
+// PasswordParams are the Argon2id settings new hashes are made with. Memory
+// is in KiB.
+type PasswordParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultPasswordParams follow the RFC 9106 recommendation for machines with
+// little memory to spare.
+var DefaultPasswordParams = PasswordParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var passwordParams = DefaultPasswordParams
+
+// SetPasswordParams changes the settings for new hashes. Existing hashes keep
+// working, and are rehashed on the next login.
+func SetPasswordParams(p PasswordParams) error {
+	if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 || p.SaltLength < 8 || p.KeyLength < 16 {
+		return errors.New("invalid argon2id parameters")
+	}
+	passwordParams = p
+	return nil
+}
+
+// DefaultPasswordMemoryLimit lets four hashes with the default settings run at
+// once. In KiB.
+const DefaultPasswordMemoryLimit = 4 * 64 * 1024
+
+// hashMemory caps the memory of the Argon2id hashes running at once, so that
+// many sign-ins together queue instead of exhausting the server's memory.
+var hashMemory = newMemorySemaphore(DefaultPasswordMemoryLimit)
+
+// SetPasswordMemoryLimit changes how much memory, in KiB, the Argon2id hashes
+// and checks running at once may use together. A hash that needs more than
+// the limit still runs, on its own.
+func SetPasswordMemoryLimit(kib uint32) error {
+	if kib < 1 {
+		return errors.New("invalid argon2id memory limit")
+	}
+	hashMemory = newMemorySemaphore(kib)
+	return nil
+}
+
+// argon2IDKey is argon2.IDKey, waiting until the memory it needs is free.
+func argon2IDKey(pwd, salt []byte, p PasswordParams) []byte {
+	sem := hashMemory
+	n := sem.acquire(p.Memory)
+	defer sem.release(n)
+	return argon2.IDKey(pwd, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+}
+
+type memorySemaphore struct {
+	mu    sync.Mutex
+	freed *sync.Cond
+	limit uint32
+	used  uint32
+}
+
+func newMemorySemaphore(limit uint32) *memorySemaphore {
+	s := &memorySemaphore{limit: limit}
+	s.freed = sync.NewCond(&s.mu)
+	return s
+}
+
+// acquire blocks until n KiB, or the whole limit if n is more, are free, and
+// returns the amount taken.
+func (s *memorySemaphore) acquire(n uint32) uint32 {
+	n = min(n, s.limit)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.limit-s.used < n {
+		s.freed.Wait()
+	}
+	s.used += n
+	return n
+}
+
+func (s *memorySemaphore) release(n uint32) {
+	s.mu.Lock()
+	s.used -= n
+	s.mu.Unlock()
+	s.freed.Broadcast()
+}
+
+// HashPassword hashes pwd with Argon2id in the PHC string format,
+// e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>, which carries the
+// algorithm, its version and its parameters along with the hash.
+func HashPassword(pwd string) (string, error) {
+	if len(pwd) < minPasswordLen {
+		return "", fmt.Errorf("password too short")
+	}
+
+	if len(pwd) > maxPasswordLen {
+		return "", fmt.Errorf("password too long")
+	}
+
+	p := passwordParams
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	key := argon2IDKey([]byte(pwd), salt, p)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// CheckPasswordHash accepts Argon2id hashes and the bcrypt hashes made before
+// them.
+func CheckPasswordHash(hash, pwd string) error {
+	if len(pwd) > maxPasswordLen {
+		return errInvalidPassword
+	}
+
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return errInvalidPassword
+		}
+		other := argon2IDKey([]byte(pwd), salt, p)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return errInvalidPassword
+		}
+		return nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pwd)); err != nil {
+		return errInvalidPassword
+	}
+
+	return nil
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other
+// settings than new hashes are, so the password should be hashed again the
+// next time it is known.
+func NeedsRehash(hash string) bool {
+	p, _, _, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	return p != passwordParams
+}
+
+func decodeArgon2Hash(hash string) (PasswordParams, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return PasswordParams{}, nil, nil, errors.New("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return PasswordParams{}, nil, nil, errors.New("unsupported argon2 version")
+	}
+
+	var p PasswordParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return PasswordParams{}, nil, nil, errors.New("invalid argon2 parameters")
+	}
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return PasswordParams{}, nil, nil, errors.New("invalid argon2 parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return PasswordParams{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return PasswordParams{}, nil, nil, errors.New("invalid argon2 hash")
+	}
+
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap settings, the defaults take a while on every hash
+var testPasswordParams = PasswordParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func withPasswordParams(t *testing.T, p PasswordParams) {
+	t.Helper()
+	old := passwordParams
+	if err := SetPasswordParams(p); err != nil {
+		t.Fatalf("SetPasswordParams: %v", err)
+	}
+	t.Cleanup(func() { passwordParams = old })
+}
+
+func TestHashPassword_Argon2id(t *testing.T) {
+	withPasswordParams(t, testPasswordParams)
+
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("want a versioned argon2id hash, got %q", hash)
+	}
+
+	if err := CheckPasswordHash(hash, "correct horse"); err != nil {
+		t.Fatalf("CheckPasswordHash: %v", err)
+	}
+	if err := CheckPasswordHash(hash, "correct horsE"); err == nil {
+		t.Fatalf("a wrong password must not match")
+	}
+
+	other, _ := HashPassword("correct horse")
+	if other == hash {
+		t.Fatalf("every hash should get its own salt")
+	}
+}
+
+func TestHashPassword_Length(t *testing.T) {
+	withPasswordParams(t, testPasswordParams)
+
+	if _, err := HashPassword("short"); err == nil {
+		t.Fatalf("want an error for a short password")
+	}
+
+	// bcrypt stopped at 72 bytes
+	passphrase := strings.Repeat("correct horse battery staple ", 10)
+	hash, err := HashPassword(passphrase)
+	if err != nil {
+		t.Fatalf("a long passphrase should be accepted: %v", err)
+	}
+	if err := CheckPasswordHash(hash, passphrase[:72]); err == nil {
+		t.Fatalf("all of a long passphrase must count")
+	}
+
+	if _, err := HashPassword(strings.Repeat("x", maxPasswordLen+1)); err == nil {
+		t.Fatalf("want an error past %d bytes", maxPasswordLen)
+	}
+}
+
+func TestCheckPasswordHash_Bcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+
+	if err := CheckPasswordHash(string(legacy), "correct horse"); err != nil {
+		t.Fatalf("bcrypt hashes should keep working: %v", err)
+	}
+	if err := CheckPasswordHash(string(legacy), "wrong horse"); err == nil {
+		t.Fatalf("a wrong password must not match")
+	}
+}
+
+func TestCheckPasswordHash_Malformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$",
+	} {
+		if err := CheckPasswordHash(hash, "correct horse"); err == nil {
+			t.Fatalf("%q: want an error", hash)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	withPasswordParams(t, testPasswordParams)
+
+	current, _ := HashPassword("correct horse")
+	if NeedsRehash(current) {
+		t.Fatalf("a hash with the current settings is fine")
+	}
+
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if !NeedsRehash(string(legacy)) {
+		t.Fatalf("bcrypt hashes should be upgraded")
+	}
+
+	stronger := testPasswordParams
+	stronger.Iterations = 2
+	withPasswordParams(t, stronger)
+	if !NeedsRehash(current) {
+		t.Fatalf("hashes with older settings should be upgraded")
+	}
+}
+
+func TestSetPasswordParams_Invalid(t *testing.T) {
+	for _, p := range []PasswordParams{
+		{Memory: 1024, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 1024, Iterations: 1, Parallelism: 0, SaltLength: 16, KeyLength: 32},
+		{Memory: 4, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32},
+	} {
+		if err := SetPasswordParams(p); err == nil {
+			t.Fatalf("%+v: want an error", p)
+		}
+	}
+}
+
+func TestMemorySemaphore_WaitsForMemory(t *testing.T) {
+	sem := newMemorySemaphore(2048)
+
+	first := sem.acquire(1024)
+	second := sem.acquire(1024)
+
+	acquired := make(chan uint32)
+	go func() { acquired <- sem.acquire(1024) }()
+
+	select {
+	case <-acquired:
+		t.Fatalf("a third hash should wait while the limit is in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sem.release(first)
+	select {
+	case n := <-acquired:
+		sem.release(n)
+	case <-time.After(time.Second):
+		t.Fatalf("a hash should run once memory is freed")
+	}
+	sem.release(second)
+
+	// more than the limit runs on its own instead of waiting forever
+	if n := sem.acquire(4096); n != 2048 {
+		t.Fatalf("want the whole limit taken, got %d", n)
+	}
+}
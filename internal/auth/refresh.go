@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+)
+
+const refreshCookieName = "sl_refresh"
+
+func GetRefreshCookie(req *http.Request) (string, error) {
+	refreshCookie, err := req.Cookie(refreshCookieName)
+	if err != nil {
+		return "", err
+	}
+
+	return refreshCookie.Value, nil
+}
+
+func MakeRefreshCookie(token string, ttl time.Duration, secure bool) *http.Cookie {
+	refreshCookie := http.Cookie{
+		Name:     refreshCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl / time.Second),
+		Expires:  time.Now().Add(ttl),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	return &refreshCookie
+}
+
+func ClearRefreshCookie(secure bool) *http.Cookie {
+	refreshCookie := http.Cookie{
+		Name:     refreshCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	return &refreshCookie
+}
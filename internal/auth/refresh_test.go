@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestRefreshCookie_RoundTrip(t *testing.T) {
+	c := MakeRefreshCookie("tok", time.Hour, true)
+	if !c.HttpOnly || !c.Secure || c.MaxAge != 3600 {
+		t.Fatalf("unexpected cookie: %+v", c)
+	}
+
+	req := httptest.NewRequest("POST", "/auth/refresh", nil)
+	req.AddCookie(c)
+	got, err := GetRefreshCookie(req)
+	if err != nil || got != "tok" {
+		t.Fatalf("GetRefreshCookie: got %q, %v", got, err)
+	}
+
+	if clear := ClearRefreshCookie(false); clear.Name != c.Name || clear.MaxAge >= 0 {
+		t.Fatalf("clear cookie should expire %s: %+v", c.Name, clear)
+	}
+}
+
+func TestSessionJWT_CarriesSessionID(t *testing.T) {
+	uid, sid := uuid.New(), uuid.New()
+	tok, err := MakeSessionJWT(uid, sid, testSecretA, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT err: %v", err)
+	}
+
+	gotUser, gotSession, err := ValidateSessionJWT(tok, testSecretA)
+	if err != nil {
+		t.Fatalf("ValidateSessionJWT err: %v", err)
+	}
+	if gotUser != uid || gotSession != sid {
+		t.Fatalf("want %s/%s, got %s/%s", uid, sid, gotUser, gotSession)
+	}
+
+	plain, err := LegacyKeyring(testSecretA).signToken(jwt.RegisteredClaims{
+		Issuer:    "smart-list",
+		Subject:   uid.String(),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+	if err != nil {
+		t.Fatalf("signToken err: %v", err)
+	}
+	if _, _, err := ValidateSessionJWT(plain, testSecretA); err == nil {
+		t.Fatalf("tokens without a session can't be revoked and must be refused")
+	}
+}
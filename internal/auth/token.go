@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// MakeToken returns a random opaque token for refresh tokens, emailed links
+// and the like. Only its hash is stored, see HashToken.
+func MakeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// purposeKey derives the key for tokens made for a single purpose, such as
+// verification links, so that they can never pass as an access token or as
+// each other.
+func purposeKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// Pseudonym is a keyed hash of value, for records that have to tell values
+// such as email addresses apart without keeping them. It is derived from the
+// active key, so rotating the keyring changes it.
+func (r *Keyring) Pseudonym(value string) string {
+	mac := hmac.New(sha256.New, purposeKey(r.active.secret, "pseudonym"))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
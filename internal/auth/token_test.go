@@ -0,0 +1,17 @@
+package auth
+
+import "testing"
+
+func TestMakeToken_Unique(t *testing.T) {
+	a, err := MakeToken()
+	if err != nil {
+		t.Fatalf("MakeToken err: %v", err)
+	}
+	b, _ := MakeToken()
+	if a == b || len(a) < 40 {
+		t.Fatalf("tokens should be long and random: %q, %q", a, b)
+	}
+	if HashToken(a) == HashToken(b) || HashToken(a) != HashToken(a) {
+		t.Fatalf("hash must be deterministic and tell tokens apart")
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RFC 6238 appendix B, SHA-1 column, cut to six digits.
+func TestTOTPCode_RFCVectors(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range cases {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode err: %v", err)
+		}
+		if got != want {
+			t.Errorf("at %d: want %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestValidateTOTP_Skew(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret err: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+
+	prev, _ := TOTPCode(secret, TOTPStep(now)-1)
+	step, ok := ValidateTOTP(secret, prev, now)
+	if !ok || step != TOTPStep(now)-1 {
+		t.Fatalf("code of the previous step should match it, got %d %v", step, ok)
+	}
+
+	old, _ := TOTPCode(secret, TOTPStep(now)-3)
+	if _, ok := ValidateTOTP(secret, old, now); ok {
+		t.Fatalf("a code from a minute and a half ago must not match")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Fatalf("short codes must not match")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("ABC", "Smart List", "jane@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Smart%20List:jane@example.com?") || !strings.Contains(uri, "secret=ABC") {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+}
+
+func TestMakeRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("MakeRecoveryCodes err: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 19 || seen[c] {
+			t.Fatalf("bad or repeated code %q", c)
+		}
+		seen[c] = true
+	}
+	if NormalizeRecoveryCode(" ABCD-efgh ijkl-MNOP") != "abcdefghijklmnop" {
+		t.Fatalf("normalizing should drop dashes, spaces and case")
+	}
+}
+
+func TestMFAPendingJWT_NotAnAccessToken(t *testing.T) {
+	uid := uuid.New()
+	pending, err := MakeMFAPendingJWT(uid, testSecretA, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMFAPendingJWT err: %v", err)
+	}
+	if got, err := ValidateMFAPendingJWT(pending, testSecretA); err != nil || got != uid {
+		t.Fatalf("ValidateMFAPendingJWT: got %s, %v", got, err)
+	}
+	if _, err := ValidateJWT(pending, testSecretA); err == nil {
+		t.Fatalf("a pending token must not work as an access token")
+	}
+}
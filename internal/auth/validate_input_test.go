@@ -136,3 +136,24 @@ func TestValidateInput_Login_Empty(t *testing.T) {
 		t.Fatal("expected error for empty email")
 	}
 }
+
+func TestValidateInput_Profile(t *testing.T) {
+	first, last, email, err := ValidateInput("profile", map[string]string{
+		"firstName": "  Ana  ",
+		"lastName":  "Silva-Costa",
+		"email":     "ignored@example.com",
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if first != "Ana" || last != "Silva-Costa" || email != "" {
+		t.Fatalf("got %q %q %q", first, last, email)
+	}
+
+	if _, _, _, err := ValidateInput("profile", map[string]string{
+		"firstName": "Ana1",
+		"lastName":  "Silva",
+	}); err == nil {
+		t.Fatal("expected error for invalid name")
+	}
+}
@@ -13,15 +13,83 @@ import (
 	"github.com/google/uuid"
 )
 
+const bumpListVersion = `-- name: BumpListVersion :one
+UPDATE list
+SET version = version + 1,
+    updated_at = NOW()
+WHERE id = $1 AND version = $2
+RETURNING version
+`
+
+type BumpListVersionParams struct {
+	ID      uuid.UUID
+	Version int64
+}
+
+func (q *Queries) BumpListVersion(ctx context.Context, arg BumpListVersionParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, bumpListVersion, arg.ID, arg.Version)
+	var version int64
+	err := row.Scan(&version)
+	return version, err
+}
+
+const createListItem = `-- name: CreateListItem :one
+INSERT INTO list_items (list_id, name, qty, unit, price)
+SELECT $1::uuid, $2::text, $3::smallint, $4::text, $5::smallint
+WHERE EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = $1::uuid AND m.user_id = $6::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING id, list_id, name, qty, unit, price, created_at, updated_at, checked, checked_at, checked_by
+`
+
+type CreateListItemParams struct {
+	ListID uuid.UUID
+	Name   string
+	Qty    sql.NullInt16
+	Unit   sql.NullString
+	Price  sql.NullInt16
+	UserID uuid.UUID
+}
+
+func (q *Queries) CreateListItem(ctx context.Context, arg CreateListItemParams) (ListItem, error) {
+	row := q.db.QueryRowContext(ctx, createListItem,
+		arg.ListID,
+		arg.Name,
+		arg.Qty,
+		arg.Unit,
+		arg.Price,
+		arg.UserID,
+	)
+	var i ListItem
+	err := row.Scan(
+		&i.ID,
+		&i.ListID,
+		&i.Name,
+		&i.Qty,
+		&i.Unit,
+		&i.Price,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Checked,
+		&i.CheckedAt,
+		&i.CheckedBy,
+	)
+	return i, err
+}
+
 const createNewList = `-- name: CreateNewList :one
-INSERT INtO list (user_id, name,  frequency, target_date)
+INSERT INtO list (user_id, name,  frequency, target_date, spawn_copy, recurrence_start)
 VALUES (
   $1,
   $2,
   $3,
+  $4,
+  $5,
   $4
 )
-RETURNING id, name, frequency, target_date
+RETURNING id, name, frequency, target_date, version, spawn_copy
 `
 
 type CreateNewListParams struct {
@@ -29,6 +97,7 @@ type CreateNewListParams struct {
 	Name       string
 	Frequency  sql.NullString
 	TargetDate sql.NullTime
+	SpawnCopy  bool
 }
 
 type CreateNewListRow struct {
@@ -36,6 +105,8 @@ type CreateNewListRow struct {
 	Name       string
 	Frequency  sql.NullString
 	TargetDate sql.NullTime
+	Version    int64
+	SpawnCopy  bool
 }
 
 func (q *Queries) CreateNewList(ctx context.Context, arg CreateNewListParams) (CreateNewListRow, error) {
@@ -44,6 +115,7 @@ func (q *Queries) CreateNewList(ctx context.Context, arg CreateNewListParams) (C
 		arg.Name,
 		arg.Frequency,
 		arg.TargetDate,
+		arg.SpawnCopy,
 	)
 	var i CreateNewListRow
 	err := row.Scan(
@@ -51,10 +123,235 @@ func (q *Queries) CreateNewList(ctx context.Context, arg CreateNewListParams) (C
 		&i.Name,
 		&i.Frequency,
 		&i.TargetDate,
+		&i.Version,
+		&i.SpawnCopy,
+	)
+	return i, err
+}
+
+const deleteCheckedListItems = `-- name: DeleteCheckedListItems :many
+DELETE FROM list_items li
+WHERE li.list_id = $1 AND li.checked
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = $2::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING id, list_id, name, qty, unit, price, created_at, updated_at, checked, checked_at, checked_by
+`
+
+type DeleteCheckedListItemsParams struct {
+	ListID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteCheckedListItems(ctx context.Context, arg DeleteCheckedListItemsParams) ([]ListItem, error) {
+	rows, err := q.db.QueryContext(ctx, deleteCheckedListItems, arg.ListID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListItem
+	for rows.Next() {
+		var i ListItem
+		if err := rows.Scan(
+			&i.ID,
+			&i.ListID,
+			&i.Name,
+			&i.Qty,
+			&i.Unit,
+			&i.Price,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Checked,
+			&i.CheckedAt,
+			&i.CheckedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteList = `-- name: DeleteList :execrows
+DELETE FROM list
+WHERE id = $1 AND user_id = $2 AND version = $3
+`
+
+type DeleteListParams struct {
+	ID      uuid.UUID
+	UserID  uuid.UUID
+	Version int64
+}
+
+func (q *Queries) DeleteList(ctx context.Context, arg DeleteListParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteList, arg.ID, arg.UserID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteListItem = `-- name: DeleteListItem :one
+DELETE FROM list_items li
+WHERE li.id = $1 AND li.list_id = $2
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = $3::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING id, list_id, name, qty, unit, price, created_at, updated_at, checked, checked_at, checked_by
+`
+
+type DeleteListItemParams struct {
+	ID     int64
+	ListID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteListItem(ctx context.Context, arg DeleteListItemParams) (ListItem, error) {
+	row := q.db.QueryRowContext(ctx, deleteListItem, arg.ID, arg.ListID, arg.UserID)
+	var i ListItem
+	err := row.Scan(
+		&i.ID,
+		&i.ListID,
+		&i.Name,
+		&i.Qty,
+		&i.Unit,
+		&i.Price,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Checked,
+		&i.CheckedAt,
+		&i.CheckedBy,
+	)
+	return i, err
+}
+
+const getListAccess = `-- name: GetListAccess :one
+SELECT l.id, l.user_id, l.name, l.frequency, l.target_date, l.created_at, l.updated_at, l.version, l.spawn_copy,
+  COALESCE((
+    SELECT m.role FROM list_members m
+    WHERE m.list_id = l.id AND m.user_id = $1::uuid AND m.status = 'accepted'
+  ), '')::text AS role
+FROM list l
+WHERE l.id = $2::uuid
+`
+
+type GetListAccessParams struct {
+	UserID uuid.UUID
+	ListID uuid.UUID
+}
+
+type GetListAccessRow struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	Frequency  sql.NullString
+	TargetDate sql.NullTime
+	CreatedAt  sql.NullTime
+	UpdatedAt  sql.NullTime
+	Version    int64
+	SpawnCopy  bool
+	Role       string
+}
+
+func (q *Queries) GetListAccess(ctx context.Context, arg GetListAccessParams) (GetListAccessRow, error) {
+	row := q.db.QueryRowContext(ctx, getListAccess, arg.UserID, arg.ListID)
+	var i GetListAccessRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Frequency,
+		&i.TargetDate,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.SpawnCopy,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getListItemForUpdate = `-- name: GetListItemForUpdate :one
+SELECT id, list_id, name, qty, unit, price, created_at, updated_at, checked, checked_at, checked_by FROM list_items
+WHERE id = $1 AND list_id = $2
+FOR UPDATE
+`
+
+type GetListItemForUpdateParams struct {
+	ID     int64
+	ListID uuid.UUID
+}
+
+func (q *Queries) GetListItemForUpdate(ctx context.Context, arg GetListItemForUpdateParams) (ListItem, error) {
+	row := q.db.QueryRowContext(ctx, getListItemForUpdate, arg.ID, arg.ListID)
+	var i ListItem
+	err := row.Scan(
+		&i.ID,
+		&i.ListID,
+		&i.Name,
+		&i.Qty,
+		&i.Unit,
+		&i.Price,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Checked,
+		&i.CheckedAt,
+		&i.CheckedBy,
 	)
 	return i, err
 }
 
+const getListItems = `-- name: GetListItems :many
+SELECT id, list_id, name, qty, unit, price, created_at, updated_at, checked, checked_at, checked_by FROM list_items
+WHERE list_id = $1
+ORDER BY id
+`
+
+func (q *Queries) GetListItems(ctx context.Context, listID uuid.UUID) ([]ListItem, error) {
+	rows, err := q.db.QueryContext(ctx, getListItems, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListItem
+	for rows.Next() {
+		var i ListItem
+		if err := rows.Scan(
+			&i.ID,
+			&i.ListID,
+			&i.Name,
+			&i.Qty,
+			&i.Unit,
+			&i.Price,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Checked,
+			&i.CheckedAt,
+			&i.CheckedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getListsByUserId = `-- name: GetListsByUserId :many
 SELECT
   l.id          AS list_id,
@@ -62,17 +359,23 @@ SELECT
   l.frequency,
   l.target_date,
   l.updated_at  AS list_updated_at,
+  l.version     AS list_version,
+  m.role        AS list_role,
+  l.spawn_copy,
   li.id         AS item_id,
   li.name       AS item_name,
   li.qty,
   li.unit,
   li.price,
   li.created_at AS item_created_at,
-  li.updated_at AS item_updated_at
+  li.updated_at AS item_updated_at,
+  li.checked,
+  li.checked_at,
+  li.checked_by
 FROM list l
+JOIN list_members m ON m.list_id = l.id AND m.user_id = $1 AND m.status = 'accepted'
 LEFT JOIN list_items li ON li.list_id = l.id
-WHERE l.user_id = $1
-ORDER BY l.updated_at DESC
+ORDER BY l.updated_at DESC, l.id, li.id
 `
 
 type GetListsByUserIdRow struct {
@@ -81,6 +384,9 @@ type GetListsByUserIdRow struct {
 	Frequency     sql.NullString
 	TargetDate    sql.NullTime
 	ListUpdatedAt sql.NullTime
+	ListVersion   int64
+	ListRole      string
+	SpawnCopy     bool
 	ItemID        sql.NullInt64
 	ItemName      sql.NullString
 	Qty           sql.NullInt16
@@ -88,6 +394,9 @@ type GetListsByUserIdRow struct {
 	Price         sql.NullInt16
 	ItemCreatedAt sql.NullTime
 	ItemUpdatedAt sql.NullTime
+	Checked       sql.NullBool
+	CheckedAt     sql.NullTime
+	CheckedBy     uuid.NullUUID
 }
 
 func (q *Queries) GetListsByUserId(ctx context.Context, userID uuid.UUID) ([]GetListsByUserIdRow, error) {
@@ -105,6 +414,9 @@ func (q *Queries) GetListsByUserId(ctx context.Context, userID uuid.UUID) ([]Get
 			&i.Frequency,
 			&i.TargetDate,
 			&i.ListUpdatedAt,
+			&i.ListVersion,
+			&i.ListRole,
+			&i.SpawnCopy,
 			&i.ItemID,
 			&i.ItemName,
 			&i.Qty,
@@ -112,6 +424,9 @@ func (q *Queries) GetListsByUserId(ctx context.Context, userID uuid.UUID) ([]Get
 			&i.Price,
 			&i.ItemCreatedAt,
 			&i.ItemUpdatedAt,
+			&i.Checked,
+			&i.CheckedAt,
+			&i.CheckedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -127,7 +442,7 @@ func (q *Queries) GetListsByUserId(ctx context.Context, userID uuid.UUID) ([]Get
 }
 
 const getUpdatedListById = `-- name: GetUpdatedListById :many
-SELECT li.id as item_id, li.list_id, li.name, li.qty, li.unit, li.price, li.updated_at, l.name as list_name, l.frequency, l.target_date, l.updated_at as list_updated_at
+SELECT li.id as item_id, li.list_id, li.name, li.qty, li.unit, li.price, li.updated_at, li.checked, l.name as list_name, l.frequency, l.target_date, l.updated_at as list_updated_at
 from list_items li
 join list l on l.id = li.list_id
 where list_id = $1
@@ -142,6 +457,7 @@ type GetUpdatedListByIdRow struct {
 	Unit          sql.NullString
 	Price         sql.NullInt16
 	UpdatedAt     sql.NullTime
+	Checked       bool
 	ListName      string
 	Frequency     sql.NullString
 	TargetDate    sql.NullTime
@@ -165,6 +481,7 @@ func (q *Queries) GetUpdatedListById(ctx context.Context, listID uuid.UUID) ([]G
 			&i.Unit,
 			&i.Price,
 			&i.UpdatedAt,
+			&i.Checked,
 			&i.ListName,
 			&i.Frequency,
 			&i.TargetDate,
@@ -191,22 +508,201 @@ SELECT 1
 FROM jsonb_to_recordset($2::jsonb) AS x(name text)
 WHERE lower(trim(x.name)) = lower(trim(li.name))
 )
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = $3::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
 `
 
 type RemoveItemsFromUserListParams struct {
 	ListID uuid.UUID
 	Items  json.RawMessage
+	UserID uuid.UUID
 }
 
 func (q *Queries) RemoveItemsFromUserList(ctx context.Context, arg RemoveItemsFromUserListParams) error {
-	_, err := q.db.ExecContext(ctx, removeItemsFromUserList, arg.ListID, arg.Items)
+	_, err := q.db.ExecContext(ctx, removeItemsFromUserList, arg.ListID, arg.Items, arg.UserID)
 	return err
 }
 
+const setListItemChecked = `-- name: SetListItemChecked :one
+UPDATE list_items li
+SET checked = COALESCE($1::boolean, NOT li.checked),
+    checked_at = CASE
+      WHEN NOT COALESCE($1::boolean, NOT li.checked) THEN NULL
+      WHEN li.checked THEN li.checked_at
+      ELSE NOW()
+    END,
+    checked_by = CASE
+      WHEN NOT COALESCE($1::boolean, NOT li.checked) THEN NULL
+      WHEN li.checked THEN li.checked_by
+      ELSE $2::uuid
+    END,
+    updated_at = NOW()
+WHERE li.id = $3 AND li.list_id = $4
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = $2::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING id, list_id, name, qty, unit, price, created_at, updated_at, checked, checked_at, checked_by
+`
+
+type SetListItemCheckedParams struct {
+	Checked sql.NullBool
+	UserID  uuid.UUID
+	ID      int64
+	ListID  uuid.UUID
+}
+
+func (q *Queries) SetListItemChecked(ctx context.Context, arg SetListItemCheckedParams) (ListItem, error) {
+	row := q.db.QueryRowContext(ctx, setListItemChecked,
+		arg.Checked,
+		arg.UserID,
+		arg.ID,
+		arg.ListID,
+	)
+	var i ListItem
+	err := row.Scan(
+		&i.ID,
+		&i.ListID,
+		&i.Name,
+		&i.Qty,
+		&i.Unit,
+		&i.Price,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Checked,
+		&i.CheckedAt,
+		&i.CheckedBy,
+	)
+	return i, err
+}
+
+const updateListItem = `-- name: UpdateListItem :one
+UPDATE list_items li
+SET name = COALESCE($1::text, li.name),
+    qty = COALESCE($2::smallint, li.qty),
+    unit = CASE WHEN $3::boolean THEN $4::text ELSE li.unit END,
+    price = CASE WHEN $5::boolean THEN $6::smallint ELSE li.price END,
+    updated_at = NOW()
+WHERE li.id = $7 AND li.list_id = $8
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = $9::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING id, list_id, name, qty, unit, price, created_at, updated_at, checked, checked_at, checked_by
+`
+
+type UpdateListItemParams struct {
+	Name     sql.NullString
+	Qty      sql.NullInt16
+	SetUnit  bool
+	Unit     sql.NullString
+	SetPrice bool
+	Price    sql.NullInt16
+	ID       int64
+	ListID   uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) UpdateListItem(ctx context.Context, arg UpdateListItemParams) (ListItem, error) {
+	row := q.db.QueryRowContext(ctx, updateListItem,
+		arg.Name,
+		arg.Qty,
+		arg.SetUnit,
+		arg.Unit,
+		arg.SetPrice,
+		arg.Price,
+		arg.ID,
+		arg.ListID,
+		arg.UserID,
+	)
+	var i ListItem
+	err := row.Scan(
+		&i.ID,
+		&i.ListID,
+		&i.Name,
+		&i.Qty,
+		&i.Unit,
+		&i.Price,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Checked,
+		&i.CheckedAt,
+		&i.CheckedBy,
+	)
+	return i, err
+}
+
+const updateListMetadata = `-- name: UpdateListMetadata :one
+UPDATE list
+SET name = COALESCE($1::text, name),
+    frequency = CASE WHEN $2::boolean THEN $3::text ELSE frequency END,
+    target_date = CASE WHEN $4::boolean THEN $5::date ELSE target_date END,
+    spawn_copy = COALESCE($6::boolean, spawn_copy),
+    recurrence_start = CASE
+      WHEN $2::boolean OR $4::boolean
+      THEN CASE WHEN $4::boolean THEN $5::date ELSE target_date END
+      ELSE recurrence_start
+    END,
+    version = version + 1,
+    updated_at = NOW()
+WHERE id = $7 AND user_id = $8 AND version = $9
+RETURNING id, user_id, name, frequency, target_date, created_at, updated_at, version, spawn_copy, recurrence_start
+`
+
+type UpdateListMetadataParams struct {
+	Name          sql.NullString
+	SetFrequency  bool
+	Frequency     sql.NullString
+	SetTargetDate bool
+	TargetDate    sql.NullTime
+	SpawnCopy     sql.NullBool
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	Version       int64
+}
+
+func (q *Queries) UpdateListMetadata(ctx context.Context, arg UpdateListMetadataParams) (List, error) {
+	row := q.db.QueryRowContext(ctx, updateListMetadata,
+		arg.Name,
+		arg.SetFrequency,
+		arg.Frequency,
+		arg.SetTargetDate,
+		arg.TargetDate,
+		arg.SpawnCopy,
+		arg.ID,
+		arg.UserID,
+		arg.Version,
+	)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Frequency,
+		&i.TargetDate,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.SpawnCopy,
+		&i.RecurrenceStart,
+	)
+	return i, err
+}
+
 const updateUserList = `-- name: UpdateUserList :exec
 INSERT INTO list_items (list_id, name, qty, updated_at)
 SELECT $1::uuid, x.name, x.qty, NOW()
 FROM jsonb_to_recordset($2::jsonb) AS x(name text, qty smallint)
+WHERE EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = $1::uuid AND m.user_id = $3::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
 ON CONFLICT (list_id, name) DO UPDATE
 SET qty = EXCLUDED.qty,
     name = EXCLUDED.name,
@@ -216,9 +712,10 @@ SET qty = EXCLUDED.qty,
 type UpdateUserListParams struct {
 	ListID uuid.UUID
 	Items  json.RawMessage
+	UserID uuid.UUID
 }
 
 func (q *Queries) UpdateUserList(ctx context.Context, arg UpdateUserListParams) error {
-	_, err := q.db.ExecContext(ctx, updateUserList, arg.ListID, arg.Items)
+	_, err := q.db.ExecContext(ctx, updateUserList, arg.ListID, arg.Items, arg.UserID)
 	return err
 }
@@ -11,6 +11,24 @@ import (
 	"github.com/google/uuid"
 )
 
+type AccountDeletion struct {
+	UserID      uuid.UUID
+	TokenHash   string
+	RequestedAt time.Time
+	DeleteAfter time.Time
+}
+
+type AuditEvent struct {
+	ID        int64
+	CreatedAt time.Time
+	ActorID   uuid.NullUUID
+	Action    string
+	Target    string
+	Ip        string
+	UserAgent string
+	Outcome   string
+}
+
 type Catalog struct {
 	ID         int16
 	Name       string
@@ -24,14 +42,27 @@ type Category struct {
 	Icon sql.NullString
 }
 
+type EmailChange struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	NewEmail  string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
 type List struct {
-	ID         uuid.UUID
-	UserID     uuid.UUID
-	Name       string
-	Frequency  sql.NullString
-	TargetDate sql.NullTime
-	CreatedAt  sql.NullTime
-	UpdatedAt  sql.NullTime
+	ID              uuid.UUID
+	UserID          uuid.UUID
+	Name            string
+	Frequency       sql.NullString
+	TargetDate      sql.NullTime
+	CreatedAt       sql.NullTime
+	UpdatedAt       sql.NullTime
+	Version         int64
+	SpawnCopy       bool
+	RecurrenceStart sql.NullTime
 }
 
 type ListItem struct {
@@ -43,15 +74,128 @@ type ListItem struct {
 	Price     sql.NullInt16
 	CreatedAt sql.NullTime
 	UpdatedAt sql.NullTime
+	Checked   bool
+	CheckedAt sql.NullTime
+	CheckedBy uuid.NullUUID
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	Role      string
+	Status    string
+	InvitedBy uuid.NullUUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ListTemplate struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ListTemplateItem struct {
+	ID         int64
+	TemplateID uuid.UUID
+	Name       string
+	Qty        sql.NullInt16
+	Unit       sql.NullString
+}
+
+type PasswordReset struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scope      string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type PurchaseEvent struct {
+	ID          int64
+	UserID      uuid.UUID
+	ListID      uuid.NullUUID
+	ItemID      sql.NullInt64
+	ItemName    string
+	Qty         sql.NullInt16
+	Unit        sql.NullString
+	Kind        string
+	PurchasedAt time.Time
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Session struct {
+	ID                uuid.UUID
+	UserID            uuid.UUID
+	RefreshTokenHash  string
+	PreviousTokenHash sql.NullString
+	CreatedAt         time.Time
+	RotatedAt         sql.NullTime
+	ExpiresAt         time.Time
+	RevokedAt         sql.NullTime
+	UserAgent         string
+	Ip                string
+	LastSeenAt        time.Time
+}
+
+type Throttle struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	BlockedUntil  time.Time
+	ExpiresAt     time.Time
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsActive       bool
-	FirstName      string
-	LastName       string
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Email              string
+	HashedPassword     string
+	IsActive           bool
+	FirstName          string
+	LastName           string
+	EmailVerifiedAt    sql.NullTime
+	VerificationSentAt sql.NullTime
+	Role               string
+}
+
+type UserIdentity struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Provider    string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	Secret       string
+	CreatedAt    time.Time
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
 }
@@ -7,18 +7,40 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimVerificationSend = `-- name: ClaimVerificationSend :execrows
+UPDATE users
+SET verification_sent_at = NOW()
+WHERE id = $1
+  AND email_verified_at IS NULL
+  AND (verification_sent_at IS NULL OR verification_sent_at < $2)
+`
+
+type ClaimVerificationSendParams struct {
+	ID                 uuid.UUID
+	VerificationSentAt sql.NullTime
+}
+
+func (q *Queries) ClaimVerificationSend(ctx context.Context, arg ClaimVerificationSendParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimVerificationSend, arg.ID, arg.VerificationSentAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     email, hashed_password, first_name, last_name
 ) VALUES (
     $1, $2, $3, $4
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_active, first_name, last_name
+RETURNING id, created_at, updated_at, email, hashed_password, is_active, first_name, last_name, email_verified_at, verification_sent_at, role
 `
 
 type CreateUserParams struct {
@@ -45,25 +67,67 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.IsActive,
 		&i.FirstName,
 		&i.LastName,
+		&i.EmailVerifiedAt,
+		&i.VerificationSentAt,
+		&i.Role,
 	)
 	return i, err
 }
 
+const deleteInactiveUser = `-- name: DeleteInactiveUser :execrows
+DELETE FROM users
+WHERE id = $1 AND NOT is_active
+`
+
+func (q *Queries) DeleteInactiveUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteInactiveUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getActiveUserRole = `-- name: GetActiveUserRole :one
+SELECT role FROM users
+WHERE id = $1 AND is_active
+`
+
+func (q *Queries) GetActiveUserRole(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRowContext(ctx, getActiveUserRole, id)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const getEmailVerifiedAt = `-- name: GetEmailVerifiedAt :one
+SELECT email_verified_at
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetEmailVerifiedAt(ctx context.Context, id uuid.UUID) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, getEmailVerifiedAt, id)
+	var email_verified_at sql.NullTime
+	err := row.Scan(&email_verified_at)
+	return email_verified_at, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, first_name, last_name, is_active, created_at, updated_at, hashed_password
+SELECT id, email, first_name, last_name, is_active, created_at, updated_at, hashed_password, email_verified_at
 FROM users
 WHERE email = $1
 `
 
 type GetUserByEmailRow struct {
-	ID             uuid.UUID
-	Email          string
-	FirstName      string
-	LastName       string
-	IsActive       bool
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	HashedPassword string
+	ID              uuid.UUID
+	Email           string
+	FirstName       string
+	LastName        string
+	IsActive        bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	HashedPassword  string
+	EmailVerifiedAt sql.NullTime
 }
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
@@ -78,6 +142,230 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEm
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_active, first_name, last_name, email_verified_at, verification_sent_at, role FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsActive,
+		&i.FirstName,
+		&i.LastName,
+		&i.EmailVerifiedAt,
+		&i.VerificationSentAt,
+		&i.Role,
+	)
+	return i, err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :execrows
+UPDATE users
+SET hashed_password = $1
+WHERE id = $2
+  AND hashed_password = $3
+`
+
+type RehashUserPasswordParams struct {
+	NewHash string
+	ID      uuid.UUID
+	OldHash string
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewHash, arg.ID, arg.OldHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_active, first_name, last_name, email_verified_at, verification_sent_at, role FROM users
+WHERE $1::text = ''
+   OR email ILIKE '%' || $1 || '%'
+   OR first_name ILIKE '%' || $1 || '%'
+   OR last_name ILIKE '%' || $1 || '%'
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type SearchUsersParams struct {
+	Query    string
+	MaxUsers int32
+	Skip     int32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers, arg.Query, arg.MaxUsers, arg.Skip)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsActive,
+			&i.FirstName,
+			&i.LastName,
+			&i.EmailVerifiedAt,
+			&i.VerificationSentAt,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setEmailVerified = `-- name: SetEmailVerified :execrows
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1
+  AND email = $2
+`
+
+type SetEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) SetEmailVerified(ctx context.Context, arg SetEmailVerifiedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setEmailVerified, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserActive = `-- name: SetUserActive :exec
+UPDATE users
+SET is_active = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserActiveParams struct {
+	ID       uuid.UUID
+	IsActive bool
+}
+
+func (q *Queries) SetUserActive(ctx context.Context, arg SetUserActiveParams) error {
+	_, err := q.db.ExecContext(ctx, setUserActive, arg.ID, arg.IsActive)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :execrows
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRole, arg.ID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :exec
+UPDATE users
+SET email = $2,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserEmail, arg.ID, arg.Email)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET first_name = $2,
+    last_name = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_active, first_name, last_name, email_verified_at, verification_sent_at, role
+`
+
+type UpdateUserProfileParams struct {
+	ID        uuid.UUID
+	FirstName string
+	LastName  string
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile, arg.ID, arg.FirstName, arg.LastName)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsActive,
+		&i.FirstName,
+		&i.LastName,
+		&i.EmailVerifiedAt,
+		&i.VerificationSentAt,
+		&i.Role,
 	)
 	return i, err
 }
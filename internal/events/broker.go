@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ItemAdded   = "item.added"
+	ItemUpdated = "item.updated"
+	ItemRemoved = "item.removed"
+	ListUpdated = "list.updated"
+	ListDeleted = "list.deleted"
+)
+
+// subscriberBuffer is how many events a slow subscriber may lag behind before
+// it is dropped. Dropped clients reconnect and reload the list.
+const subscriberBuffer = 32
+
+type Event struct {
+	Type    string          `json:"type"`
+	ListID  uuid.UUID       `json:"list_id"`
+	Version int64           `json:"version"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Transport carries events between app instances. When a broker has one,
+// published events go through it and come back in via Deliver.
+type Transport interface {
+	Send(ctx context.Context, ev Event) error
+}
+
+type Broker struct {
+	mu        sync.Mutex
+	subs      map[uuid.UUID]map[chan Event]struct{}
+	transport Transport
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: make(map[uuid.UUID]map[chan Event]struct{}),
+	}
+}
+
+func (b *Broker) SetTransport(t Transport) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.transport = t
+}
+
+// Subscribe registers interest in a list's events. The returned cancel func
+// must be called once the subscriber goes away; the channel is closed when
+// the subscription ends, including when the broker drops a slow subscriber.
+func (b *Broker) Subscribe(listID uuid.UUID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[listID] == nil {
+		b.subs[listID] = make(map[chan Event]struct{})
+	}
+	b.subs[listID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(listID, ch)
+	}
+}
+
+// remove must be called with b.mu held.
+func (b *Broker) remove(listID uuid.UUID, ch chan Event) {
+	subs, ok := b.subs[listID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(b.subs, listID)
+	}
+}
+
+func (b *Broker) Publish(ctx context.Context, ev Event) error {
+	b.mu.Lock()
+	transport := b.transport
+	b.mu.Unlock()
+
+	if transport != nil {
+		return transport.Send(ctx, ev)
+	}
+	b.Deliver(ev)
+	return nil
+}
+
+// Deliver fans an event out to the subscribers of its list on this instance.
+func (b *Broker) Deliver(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[ev.ListID] {
+		select {
+		case ch <- ev:
+		default:
+			b.remove(ev.ListID, ch)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBroker_DeliversToListSubscribers(t *testing.T) {
+	b := NewBroker()
+	listA, listB := uuid.New(), uuid.New()
+
+	chA, cancelA := b.Subscribe(listA)
+	defer cancelA()
+	chB, cancelB := b.Subscribe(listB)
+	defer cancelB()
+
+	if err := b.Publish(context.Background(), Event{Type: ItemAdded, ListID: listA, Version: 2}); err != nil {
+		t.Fatalf("Publish err: %v", err)
+	}
+
+	select {
+	case ev := <-chA:
+		if ev.Type != ItemAdded || ev.Version != 2 {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	default:
+		t.Fatal("subscriber of the list got no event")
+	}
+
+	select {
+	case ev := <-chB:
+		t.Fatalf("subscriber of another list got %+v", ev)
+	default:
+	}
+}
+
+func TestBroker_CancelClosesChannel(t *testing.T) {
+	b := NewBroker()
+	listID := uuid.New()
+
+	ch, cancel := b.Subscribe(listID)
+	cancel()
+	cancel() // calling it twice must be safe
+
+	if _, ok := <-ch; ok {
+		t.Fatal("channel should be closed after cancel")
+	}
+	if len(b.subs) != 0 {
+		t.Fatalf("subscription should be forgotten, got %d lists", len(b.subs))
+	}
+}
+
+func TestBroker_DropsSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	listID := uuid.New()
+
+	ch, cancel := b.Subscribe(listID)
+	defer cancel()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Deliver(Event{Type: ItemUpdated, ListID: listID, Version: int64(i)})
+	}
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Fatalf("want %d buffered events before the drop, got %d", subscriberBuffer, n)
+	}
+}
+
+type recordingTransport struct {
+	sent []Event
+}
+
+func (r *recordingTransport) Send(ctx context.Context, ev Event) error {
+	r.sent = append(r.sent, ev)
+	return nil
+}
+
+func TestBroker_PublishUsesTransport(t *testing.T) {
+	b := NewBroker()
+	tr := &recordingTransport{}
+	b.SetTransport(tr)
+	listID := uuid.New()
+
+	ch, cancel := b.Subscribe(listID)
+	defer cancel()
+
+	b.Publish(context.Background(), Event{Type: ListUpdated, ListID: listID})
+
+	if len(tr.sent) != 1 {
+		t.Fatalf("want 1 event sent through the transport, got %d", len(tr.sent))
+	}
+	select {
+	case ev := <-ch:
+		t.Fatalf("event must come back through the transport, got %+v", ev)
+	default:
+	}
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/lib/pq"
+)
+
+const pgChannel = "list_events"
+
+// pg_notify payloads are capped at 8000 bytes; bigger events are sent without
+// their data and clients fall back to reloading the list.
+const maxNotifyPayload = 7900
+
+// PGBridge relays events between instances with Postgres LISTEN/NOTIFY.
+type PGBridge struct {
+	db       *database.Queries
+	listener *pq.Listener
+	broker   *Broker
+}
+
+func NewPGBridge(dbURL string, db *database.Queries, broker *Broker) (*PGBridge, error) {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("events: listener: %v", err)
+		}
+	})
+
+	if err := listener.Listen(pgChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	bridge := &PGBridge{
+		db:       db,
+		listener: listener,
+		broker:   broker,
+	}
+	broker.SetTransport(bridge)
+	go bridge.run()
+
+	return bridge, nil
+}
+
+func (p *PGBridge) Send(ctx context.Context, ev Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxNotifyPayload {
+		ev.Data = nil
+		payload, err = json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.db.NotifyListEvent(ctx, string(payload))
+}
+
+func (p *PGBridge) run() {
+	for n := range p.listener.Notify {
+		// a nil notification means the connection was re-established and
+		// events may have been missed in between
+		if n == nil {
+			continue
+		}
+
+		var ev Event
+		if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+			log.Printf("events: invalid notification: %v", err)
+			continue
+		}
+		p.broker.Deliver(ev)
+	}
+}
+
+func (p *PGBridge) Close() error {
+	return p.listener.Close()
+}
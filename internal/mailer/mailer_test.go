@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	data, err := format("app@example.com", Message{
+		To:      "jane@example.com",
+		Subject: "Hello",
+		Body:    "line one\nline two",
+	}, now)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+
+	got := string(data)
+	for _, want := range []string{
+		"From: app@example.com\r\n",
+		"To: jane@example.com\r\n",
+		"Subject: Hello\r\n",
+		"Date: Mon, 30 Jun 2025 12:00:00 +0000\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message should contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestFormat_RejectsHeaderInjection(t *testing.T) {
+	_, err := format("app@example.com", Message{
+		To:      "jane@example.com\r\nBcc: everyone@example.com",
+		Subject: "Hello",
+	}, time.Now())
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("want ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestFile_WritesOneFilePerMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := &File{Dir: dir, From: "app@example.com"}
+
+	for i := 0; i < 2; i++ {
+		if err := m.Send(context.Background(), Message{To: "jane@example.com", Subject: "Hi", Body: "body"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("want 2 .eml files, got %v (%v)", files, err)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "To: jane@example.com") {
+		t.Fatalf("unexpected file content:\n%s", data)
+	}
+}
@@ -0,0 +1,161 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/henrique-godinho/smart-list/internal/oidc/oidctest"
+)
+
+const testRedirect = "http://app.test/auth/oidc/test/callback"
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Server) {
+	t.Helper()
+	srv := oidctest.NewServer()
+	t.Cleanup(srv.Close)
+
+	p := New(Config{
+		ID:           "test",
+		Name:         "Test",
+		Issuer:       srv.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  testRedirect,
+	})
+	return p, srv
+}
+
+// authorize follows the authorization URL and returns the code the provider
+// sends back, checking that state comes back unchanged.
+func authorize(t *testing.T, p *Provider, state, nonce, verifier string) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: want a redirect, got %s %q", resp.Status, resp.Header.Get("Location"))
+	}
+	if back.Query().Get("state") != state {
+		t.Fatalf("state: want %q, got %q", state, back.Query().Get("state"))
+	}
+	return back.Query().Get("code")
+}
+
+func TestProvider_CodeFlow(t *testing.T) {
+	p, srv := newTestProvider(t)
+	srv.SetUser(oidctest.User{Subject: "u-1", Email: "ana@example.com", EmailVerified: true, GivenName: "Ana"})
+
+	verifier, _ := RandomString()
+	code := authorize(t, p, "s-1", "n-1", verifier)
+
+	idToken, err := p.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := p.VerifyIDToken(context.Background(), idToken, "n-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "u-1" || claims.Email != "ana@example.com" || !claims.EmailVerified || claims.GivenName != "Ana" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	if _, err := p.Exchange(context.Background(), code, verifier); err == nil {
+		t.Fatalf("a code must only work once")
+	}
+}
+
+func TestProvider_Exchange_WrongVerifier(t *testing.T) {
+	p, srv := newTestProvider(t)
+	srv.SetUser(oidctest.User{Subject: "u-1"})
+
+	verifier, _ := RandomString()
+	code := authorize(t, p, "s", "n", verifier)
+
+	other, _ := RandomString()
+	if _, err := p.Exchange(context.Background(), code, other); err == nil {
+		t.Fatalf("a code must not be redeemable without its PKCE verifier")
+	}
+}
+
+func TestProvider_VerifyIDToken_Rejects(t *testing.T) {
+	p, srv := newTestProvider(t)
+	user := oidctest.User{Subject: "u-1", Email: "ana@example.com", EmailVerified: true}
+
+	tests := map[string]func(jwt.MapClaims){
+		"wrong nonce":    func(c jwt.MapClaims) { c["nonce"] = "other" },
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no subject":     func(c jwt.MapClaims) { delete(c, "sub") },
+		"other azp":      func(c jwt.MapClaims) { c["aud"] = []string{oidctest.ClientID, "x"}; c["azp"] = "x" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := srv.Claims(user, "n")
+			mutate(claims)
+			_, err := p.VerifyIDToken(context.Background(), srv.SignIDToken(claims), "n")
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("want ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+
+	t.Run("forged signature", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, srv.Claims(user, "n"))
+		token.Header["kid"] = oidctest.KeyID
+		forged, _ := token.SignedString([]byte("guess"))
+		if _, err := p.VerifyIDToken(context.Background(), forged, "n"); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("want ErrInvalidToken, got %v", err)
+		}
+	})
+}
+
+func TestProvider_Discovery_IssuerMismatch(t *testing.T) {
+	_, srv := newTestProvider(t)
+	p := New(Config{Issuer: srv.Issuer() + "/", ClientID: oidctest.ClientID})
+
+	if _, err := p.Metadata(context.Background()); err == nil {
+		t.Fatalf("a discovery document for another issuer must be refused")
+	}
+}
+
+func TestBool_AcceptsStrings(t *testing.T) {
+	var c struct {
+		A Bool `json:"a"`
+		B Bool `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": "true", "b": false}`), &c); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !c.A || c.B {
+		t.Fatalf("got %+v", c)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636, appendix B
+	got := Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,130 @@
+// Package ratelimit is a token bucket rate limiter keyed by caller.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate is Limit requests per Period, with bursts of up to Limit.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRate reads a rate like "60/1m" or "10/s".
+func ParseRate(s string) (Rate, error) {
+	n, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate %q: want <requests>/<period>", s)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil || limit <= 0 {
+		return Rate{}, fmt.Errorf("rate %q: invalid number of requests", s)
+	}
+
+	period = strings.TrimSpace(period)
+	// "10/s" reads better than "10/1s"
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("rate %q: invalid period", s)
+	}
+
+	return Rate{Limit: limit, Period: d}, nil
+}
+
+func (r Rate) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// Result is the outcome of one request, with what the RateLimit headers need.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, when this
+	// one was not.
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// sweepInterval is how often buckets that filled up again are dropped.
+const sweepInterval = time.Minute
+
+// Limiter keeps one bucket per key, in memory.
+type Limiter struct {
+	rate Rate
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func New(rate Rate) (*Limiter, error) {
+	if rate.Limit <= 0 || rate.Period <= 0 {
+		return nil, errors.New("rate limit and period must be positive")
+	}
+	return &Limiter{rate: rate, buckets: make(map[string]*bucket)}, nil
+}
+
+// Allow takes a token from the bucket of key, if there is one.
+func (l *Limiter) Allow(key string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+		l.lastSweep = now
+	}
+
+	burst := float64(l.rate.Limit)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.rate.perSecond())
+	b.last = now
+
+	res := Result{Limit: l.rate.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.duration(burst - b.tokens)
+	return res
+}
+
+// duration is how long it takes to refill the given number of tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate.perSecond() * float64(time.Second))
+}
+
+// sweep drops buckets that are full again; they'd be made anew just the same.
+func (l *Limiter) sweep(now time.Time) {
+	full := l.duration(float64(l.rate.Limit))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
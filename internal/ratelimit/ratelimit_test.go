@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]Rate{
+		"60/1m": {60, time.Minute},
+		"10/s":  {10, time.Second},
+		"5/30s": {5, 30 * time.Second},
+	}
+	for in, want := range cases {
+		got, err := ParseRate(in)
+		if err != nil || got != want {
+			t.Errorf("%q: want %+v, got %+v (%v)", in, want, got, err)
+		}
+	}
+
+	for _, in := range []string{"", "60", "x/1m", "0/1m", "10/0s", "10/forever"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestLimiter_BurstThenRefill(t *testing.T) {
+	l, err := New(Rate{Limit: 3, Period: 3 * time.Second})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Now()
+
+	for i := 3; i > 0; i-- {
+		res := l.Allow("alice", now)
+		if !res.Allowed || res.Remaining != i-1 {
+			t.Fatalf("request %d: %+v", 4-i, res)
+		}
+	}
+
+	res := l.Allow("alice", now)
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Fatalf("an empty bucket should refuse and tell when to retry: %+v", res)
+	}
+
+	if res := l.Allow("bob", now); !res.Allowed {
+		t.Fatalf("keys must have their own buckets")
+	}
+
+	if res := l.Allow("alice", now.Add(time.Second)); !res.Allowed {
+		t.Fatalf("a token should be back after a second: %+v", res)
+	}
+}
+
+func TestLimiter_SweepsFullBuckets(t *testing.T) {
+	l, _ := New(Rate{Limit: 1, Period: time.Second})
+	now := time.Now()
+
+	l.Allow("alice", now)
+	l.Allow("bob", now.Add(2*sweepInterval))
+
+	if _, ok := l.buckets["alice"]; ok {
+		t.Fatalf("a bucket that filled up again should be dropped")
+	}
+}
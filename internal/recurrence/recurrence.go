@@ -0,0 +1,241 @@
+// Package recurrence parses list frequencies and computes when a recurring
+// list is due next.
+//
+// A frequency is either one of the presets offered by the UI (daily, weekly,
+// biweekly, monthly, quarterly, yearly) or a subset of RFC 5545 RRULE:
+// FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, BYDAY (weekly rules only)
+// and BYMONTHDAY (monthly rules only).
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+const maxInterval = 366
+
+var ErrInvalidRule = errors.New("invalid frequency")
+
+type Rule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay int
+}
+
+var presets = map[string]Rule{
+	"daily":     {Freq: Daily, Interval: 1},
+	"weekly":    {Freq: Weekly, Interval: 1},
+	"biweekly":  {Freq: Weekly, Interval: 2},
+	"bi-weekly": {Freq: Weekly, Interval: 2},
+	"monthly":   {Freq: Monthly, Interval: 1},
+	"quarterly": {Freq: Monthly, Interval: 3},
+	"yearly":    {Freq: Yearly, Interval: 1},
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func Parse(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if rule, ok := presets[strings.ToLower(s)]; ok {
+		return rule, nil
+	}
+
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	if s == "" {
+		return Rule{}, ErrInvalidRule
+	}
+
+	rule := Rule{Interval: 1}
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" || seen[key] {
+			return Rule{}, ErrInvalidRule
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = value
+			default:
+				return Rule{}, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalidRule, value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxInterval {
+				return Rule{}, fmt.Errorf("%w: INTERVAL must be between 1 and %d", ErrInvalidRule, maxInterval)
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, ok := weekdays[day]
+				if !ok {
+					return Rule{}, fmt.Errorf("%w: unknown day %s", ErrInvalidRule, day)
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 31 {
+				return Rule{}, fmt.Errorf("%w: BYMONTHDAY must be between 1 and 31", ErrInvalidRule)
+			}
+			rule.ByMonthDay = n
+		default:
+			return Rule{}, fmt.Errorf("%w: unsupported part %s", ErrInvalidRule, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != Weekly {
+		return Rule{}, fmt.Errorf("%w: BYDAY is only supported with FREQ=WEEKLY", ErrInvalidRule)
+	}
+	if rule.ByMonthDay != 0 && rule.Freq != Monthly {
+		return Rule{}, fmt.Errorf("%w: BYMONTHDAY is only supported with FREQ=MONTHLY", ErrInvalidRule)
+	}
+
+	sort.Slice(rule.ByDay, func(i, j int) bool { return rule.ByDay[i] < rule.ByDay[j] })
+	for i := 1; i < len(rule.ByDay); i++ {
+		if rule.ByDay[i] == rule.ByDay[i-1] {
+			return Rule{}, fmt.Errorf("%w: duplicate day in BYDAY", ErrInvalidRule)
+		}
+	}
+
+	return rule, nil
+}
+
+// String returns the canonical form of the rule: the preset name when there
+// is one, otherwise an RRULE.
+func (r Rule) String() string {
+	if len(r.ByDay) == 0 && r.ByMonthDay == 0 {
+		switch {
+		case r.Freq == Daily && r.Interval == 1:
+			return "daily"
+		case r.Freq == Weekly && r.Interval == 1:
+			return "weekly"
+		case r.Freq == Weekly && r.Interval == 2:
+			return "biweekly"
+		case r.Freq == Monthly && r.Interval == 1:
+			return "monthly"
+		case r.Freq == Monthly && r.Interval == 3:
+			return "quarterly"
+		case r.Freq == Yearly && r.Interval == 1:
+			return "yearly"
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("RRULE:FREQ=" + r.Freq)
+	if r.Interval > 1 {
+		b.WriteString(";INTERVAL=" + strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, strings.ToUpper(wd.String()[:2]))
+		}
+		b.WriteString(";BYDAY=" + strings.Join(days, ","))
+	}
+	if r.ByMonthDay != 0 {
+		b.WriteString(";BYMONTHDAY=" + strconv.Itoa(r.ByMonthDay))
+	}
+	return b.String()
+}
+
+// Next returns the first occurrence of the rule strictly after `after`,
+// counting occurrences from `start`. Only the date part of both is used.
+func (r Rule) Next(start, after time.Time) time.Time {
+	start = dateOf(start)
+	after = dateOf(after)
+
+	if r.Freq == Weekly && len(r.ByDay) > 0 {
+		return r.nextWeekday(start, after)
+	}
+
+	next := start
+	for n := 1; !next.After(after); n++ {
+		next = r.occurrence(start, n)
+	}
+	return next
+}
+
+// occurrence returns the n-th occurrence after start. Monthly and yearly rules
+// are computed from start rather than from the previous occurrence so that a
+// list due on the 31st comes back to the 31st after a short month.
+func (r Rule) occurrence(start time.Time, n int) time.Time {
+	step := n * r.Interval
+	switch r.Freq {
+	case Daily:
+		return start.AddDate(0, 0, step)
+	case Weekly:
+		return start.AddDate(0, 0, 7*step)
+	case Monthly:
+		day := start.Day()
+		if r.ByMonthDay != 0 {
+			day = r.ByMonthDay
+		}
+		return addMonths(start, step, day)
+	default:
+		return addMonths(start, 12*step, start.Day())
+	}
+}
+
+func (r Rule) nextWeekday(start, after time.Time) time.Time {
+	weekStart := start.AddDate(0, 0, -int(start.Weekday()))
+
+	day := after
+	if day.Before(start) {
+		day = start.AddDate(0, 0, -1)
+	}
+	for {
+		day = day.AddDate(0, 0, 1)
+		weeks := int(day.Sub(weekStart).Hours()/24) / 7
+		if weeks%r.Interval != 0 {
+			continue
+		}
+		for _, wd := range r.ByDay {
+			if day.Weekday() == wd {
+				return day
+			}
+		}
+	}
+}
+
+// addMonths moves t by the given number of months onto day, clamped to the
+// last day of the resulting month.
+func addMonths(t time.Time, months, day int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,119 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParse_Presets(t *testing.T) {
+	cases := map[string]string{
+		"daily":     "daily",
+		"Weekly":    "weekly",
+		"bi-weekly": "biweekly",
+		"biweekly":  "biweekly",
+		" monthly ": "monthly",
+		"quarterly": "quarterly",
+		"yearly":    "yearly",
+	}
+	for in, want := range cases {
+		rule, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q) err: %v", in, err)
+		}
+		if rule.String() != want {
+			t.Fatalf("Parse(%q): want %q, got %q", in, want, rule.String())
+		}
+	}
+}
+
+func TestParse_RRule(t *testing.T) {
+	cases := map[string]string{
+		"RRULE:FREQ=WEEKLY;INTERVAL=2":          "biweekly",
+		"FREQ=MONTHLY;INTERVAL=3":               "quarterly",
+		"freq=weekly;byday=fr,mo":               "RRULE:FREQ=WEEKLY;BYDAY=MO,FR",
+		"FREQ=MONTHLY;BYMONTHDAY=15":            "RRULE:FREQ=MONTHLY;BYMONTHDAY=15",
+		"FREQ=DAILY;INTERVAL=10":                "RRULE:FREQ=DAILY;INTERVAL=10",
+		"RRULE:FREQ=WEEKLY;INTERVAL=3;BYDAY=SA": "RRULE:FREQ=WEEKLY;INTERVAL=3;BYDAY=SA",
+	}
+	for in, want := range cases {
+		rule, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q) err: %v", in, err)
+		}
+		if rule.String() != want {
+			t.Fatalf("Parse(%q): want %q, got %q", in, want, rule.String())
+		}
+		again, err := Parse(rule.String())
+		if err != nil || again.String() != want {
+			t.Fatalf("canonical form %q does not parse back: %v", want, err)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"every other tuesday",
+		"FREQ=HOURLY",
+		"INTERVAL=2",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=MO,MO",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYMONTHDAY=3",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=WEEKLY;FREQ=DAILY",
+		"FREQ=WEEKLY;COUNT=3",
+	} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalidRule) {
+			t.Fatalf("Parse(%q): want ErrInvalidRule, got %v", in, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	mustParse := func(s string) Rule {
+		rule, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q) err: %v", s, err)
+		}
+		return rule
+	}
+
+	cases := []struct {
+		rule  string
+		start time.Time
+		after time.Time
+		want  time.Time
+	}{
+		{"daily", date(2025, 3, 1), date(2025, 3, 1), date(2025, 3, 2)},
+		{"weekly", date(2025, 3, 3), date(2025, 3, 20), date(2025, 3, 24)},
+		{"biweekly", date(2025, 3, 3), date(2025, 3, 10), date(2025, 3, 17)},
+		{"monthly", date(2025, 1, 31), date(2025, 1, 31), date(2025, 2, 28)},
+		{"monthly", date(2025, 1, 31), date(2025, 2, 28), date(2025, 3, 31)},
+		{"quarterly", date(2025, 11, 15), date(2025, 11, 15), date(2026, 2, 15)},
+		{"yearly", date(2024, 2, 29), date(2024, 2, 29), date(2025, 2, 28)},
+		{"FREQ=MONTHLY;BYMONTHDAY=31", date(2025, 4, 10), date(2025, 4, 10), date(2025, 5, 31)},
+		// Mondays and Thursdays
+		{"FREQ=WEEKLY;BYDAY=MO,TH", date(2025, 3, 3), date(2025, 3, 3), date(2025, 3, 6)},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", date(2025, 3, 3), date(2025, 3, 6), date(2025, 3, 10)},
+		// Saturdays every other week, counted from the week of start
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=SA", date(2025, 3, 3), date(2025, 3, 8), date(2025, 3, 22)},
+		// start is still in the future
+		{"weekly", date(2025, 6, 1), date(2025, 3, 1), date(2025, 6, 1)},
+	}
+
+	for _, c := range cases {
+		got := mustParse(c.rule).Next(c.start, c.after)
+		if !got.Equal(c.want) {
+			t.Errorf("%s from %s after %s: want %s, got %s", c.rule,
+				c.start.Format(time.DateOnly), c.after.Format(time.DateOnly),
+				c.want.Format(time.DateOnly), got.Format(time.DateOnly))
+		}
+	}
+}
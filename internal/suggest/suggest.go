@@ -0,0 +1,128 @@
+// Package suggest works out which items a user is likely to need again from
+// when they bought them before.
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// MinPurchases is how many purchases, on different days, an item needs before
+// its repurchase interval is trusted.
+const MinPurchases = 3
+
+type Purchase struct {
+	Name string
+	At   time.Time
+}
+
+type Suggestion struct {
+	Name          string        `json:"name"`
+	LastPurchased time.Time     `json:"last_purchased"`
+	Interval      time.Duration `json:"-"`
+	IntervalDays  int           `json:"interval_days"`
+	DueAt         time.Time     `json:"due_at"`
+	Purchases     int           `json:"purchases"`
+
+	overdue float64
+}
+
+type history struct {
+	name string
+	days []time.Time
+}
+
+// Due returns the items whose typical repurchase interval has (nearly) passed
+// since they were last bought, most overdue first. Items whose lowercased name
+// is in skip, e.g. because they are already on a list, are left out.
+func Due(purchases []Purchase, now time.Time, skip map[string]bool, limit int) []Suggestion {
+	byName := make(map[string]*history)
+
+	for _, p := range purchases {
+		key := strings.ToLower(strings.TrimSpace(p.Name))
+		if key == "" || skip[key] {
+			continue
+		}
+		h, ok := byName[key]
+		if !ok {
+			h = &history{}
+			byName[key] = h
+		}
+		// keep the spelling used most recently
+		h.name = strings.TrimSpace(p.Name)
+		h.days = append(h.days, p.At.UTC().Truncate(day))
+	}
+
+	suggestions := make([]Suggestion, 0)
+
+	for _, h := range byName {
+		days := uniqueDays(h.days)
+		if len(days) < MinPurchases {
+			continue
+		}
+
+		interval := medianGap(days)
+		if interval < day {
+			continue
+		}
+
+		last := days[len(days)-1]
+		dueAt := last.Add(interval)
+		// suggest items a little before they run out
+		if now.Before(dueAt.Add(-interval / 10)) {
+			continue
+		}
+
+		suggestions = append(suggestions, Suggestion{
+			Name:          h.name,
+			LastPurchased: last,
+			Interval:      interval,
+			IntervalDays:  int(interval / day),
+			DueAt:         dueAt,
+			Purchases:     len(days),
+			overdue:       float64(now.Sub(last)) / float64(interval),
+		})
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if a, b := suggestions[i].overdue, suggestions[j].overdue; a != b {
+			return a > b
+		}
+		return suggestions[i].Name < suggestions[j].Name
+	})
+
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+func uniqueDays(days []time.Time) []time.Time {
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	unique := days[:0:0]
+	for _, d := range days {
+		if len(unique) == 0 || !unique[len(unique)-1].Equal(d) {
+			unique = append(unique, d)
+		}
+	}
+	return unique
+}
+
+// medianGap is the median time between consecutive purchases; unlike the mean
+// it isn't thrown off by the odd extra trip or a holiday.
+func medianGap(days []time.Time) time.Duration {
+	gaps := make([]time.Duration, 0, len(days)-1)
+	for i := 1; i < len(days); i++ {
+		gaps = append(gaps, days[i].Sub(days[i-1]))
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+
+	mid := len(gaps) / 2
+	if len(gaps)%2 == 1 {
+		return gaps[mid]
+	}
+	return (gaps[mid-1] + gaps[mid]) / 2
+}
@@ -0,0 +1,73 @@
+package suggest
+
+import (
+	"testing"
+	"time"
+)
+
+func daysAgo(now time.Time, n int) time.Time {
+	return now.Add(-time.Duration(n) * day)
+}
+
+func TestDue(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	purchases := []Purchase{
+		// weekly, last bought 8 days ago: due
+		{"Milk", daysAgo(now, 29)},
+		{"Milk", daysAgo(now, 22)},
+		{"milk", daysAgo(now, 15)},
+		{"milk ", daysAgo(now, 8)},
+		// monthly, last bought 10 days ago: not due
+		{"Coffee", daysAgo(now, 70)},
+		{"Coffee", daysAgo(now, 40)},
+		{"Coffee", daysAgo(now, 10)},
+		// only twice: no interval to go by
+		{"Saffron", daysAgo(now, 90)},
+		{"Saffron", daysAgo(now, 60)},
+		// every two weeks, last bought 40 days ago: most overdue
+		{"Rice", daysAgo(now, 68)},
+		{"Rice", daysAgo(now, 54)},
+		{"Rice", daysAgo(now, 40)},
+	}
+
+	got := Due(purchases, now, nil, 0)
+
+	if len(got) != 2 {
+		t.Fatalf("want 2 suggestions, got %+v", got)
+	}
+	if got[0].Name != "Rice" || got[1].Name != "milk" {
+		t.Fatalf("want Rice then milk, got %s then %s", got[0].Name, got[1].Name)
+	}
+	if got[1].IntervalDays != 7 || got[1].Purchases != 4 {
+		t.Fatalf("unexpected milk suggestion: %+v", got[1])
+	}
+}
+
+func TestDue_SameDayPurchasesCountOnce(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	purchases := []Purchase{
+		{"Bread", daysAgo(now, 3)},
+		{"Bread", daysAgo(now, 3).Add(time.Hour)},
+		{"Bread", daysAgo(now, 3).Add(2 * time.Hour)},
+	}
+
+	if got := Due(purchases, now, nil, 0); len(got) != 0 {
+		t.Fatalf("purchases on one day give no interval, got %+v", got)
+	}
+}
+
+func TestDue_SkipAndLimit(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	var purchases []Purchase
+	for _, name := range []string{"Apples", "Bananas", "Cherries"} {
+		for _, n := range []int{21, 14, 7} {
+			purchases = append(purchases, Purchase{name, daysAgo(now, n)})
+		}
+	}
+
+	got := Due(purchases, now, map[string]bool{"bananas": true}, 1)
+
+	if len(got) != 1 || got[0].Name != "Apples" {
+		t.Fatalf("want only Apples, got %+v", got)
+	}
+}
@@ -0,0 +1,61 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Store for a single instance.
+type Memory struct {
+	mu    sync.Mutex
+	state map[string]State
+}
+
+func NewMemory() *Memory {
+	return &Memory{state: make(map[string]State)}
+}
+
+func (m *Memory) Get(_ context.Context, key string, now time.Time) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.state[key]
+	if !ok || now.After(s.Expires) {
+		return State{}, nil
+	}
+	return s, nil
+}
+
+func (m *Memory) Update(_ context.Context, key string, now time.Time, fn func(State) State) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.state[key]
+	if !ok || now.After(s.Expires) {
+		s = State{}
+	}
+	s = fn(s)
+	m.state[key] = s
+	return s, nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.state, key)
+	return nil
+}
+
+func (m *Memory) Sweep(_ context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, s := range m.state {
+		if now.After(s.Expires) {
+			delete(m.state, key)
+		}
+	}
+	return nil
+}
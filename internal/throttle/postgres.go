@@ -0,0 +1,91 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+// PGStore keeps attempt state in Postgres, so that limits hold across every
+// app instance.
+type PGStore struct {
+	sql *sql.DB
+	db  *database.Queries
+}
+
+func NewPGStore(db *sql.DB, queries *database.Queries) *PGStore {
+	return &PGStore{sql: db, db: queries}
+}
+
+func fromRow(t database.Throttle) State {
+	return State{
+		Failures:     int(t.Failures),
+		LastFailure:  t.LastFailureAt,
+		BlockedUntil: t.BlockedUntil,
+		Expires:      t.ExpiresAt,
+	}
+}
+
+func (p *PGStore) Get(ctx context.Context, key string, now time.Time) (State, error) {
+	t, err := p.db.GetThrottle(ctx, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return State{}, nil
+		}
+		return State{}, err
+	}
+
+	s := fromRow(t)
+	if now.After(s.Expires) {
+		return State{}, nil
+	}
+	return s, nil
+}
+
+func (p *PGStore) Update(ctx context.Context, key string, now time.Time, fn func(State) State) (State, error) {
+	tx, err := p.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return State{}, err
+	}
+	defer tx.Rollback()
+	qtx := p.db.WithTx(tx)
+
+	// make sure there is a row to lock, so concurrent attempts queue up
+	if err := qtx.EnsureThrottle(ctx, key); err != nil {
+		return State{}, err
+	}
+	t, err := qtx.GetThrottleForUpdate(ctx, key)
+	if err != nil {
+		return State{}, err
+	}
+
+	s := fromRow(t)
+	if now.After(s.Expires) {
+		s = State{}
+	}
+	s = fn(s)
+
+	err = qtx.SaveThrottle(ctx, database.SaveThrottleParams{
+		Key:           key,
+		Failures:      int32(s.Failures),
+		LastFailureAt: s.LastFailure,
+		BlockedUntil:  s.BlockedUntil,
+		ExpiresAt:     s.Expires,
+	})
+	if err != nil {
+		return State{}, err
+	}
+
+	return s, tx.Commit()
+}
+
+func (p *PGStore) Delete(ctx context.Context, key string) error {
+	return p.db.DeleteThrottle(ctx, key)
+}
+
+func (p *PGStore) Sweep(ctx context.Context, now time.Time) error {
+	return p.db.DeleteExpiredThrottles(ctx, now)
+}
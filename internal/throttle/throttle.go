@@ -0,0 +1,123 @@
+// Package throttle slows down repeated failed attempts, such as password
+// guesses, with exponential backoff and a temporary lockout.
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// State is what a Store keeps per key.
+type State struct {
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time
+
+	// Expires is when the state can be forgotten.
+	Expires time.Time
+}
+
+// Store keeps attempt state. A store shared between instances, like PGStore,
+// makes limits hold across all of them.
+type Store interface {
+	// Get returns the state of key, or the zero State when there is none.
+	Get(ctx context.Context, key string, now time.Time) (State, error)
+	// Update replaces the state of key with fn of the old one, atomically.
+	Update(ctx context.Context, key string, now time.Time, fn func(State) State) (State, error)
+	Delete(ctx context.Context, key string) error
+	// Sweep drops expired state.
+	Sweep(ctx context.Context, now time.Time) error
+}
+
+type Policy struct {
+	// Free is how many failures in a row go without any delay.
+	Free int
+	// BaseDelay is the wait after the first failure beyond Free, doubling
+	// with each further one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// LockoutAfter failures in a row block the key for Lockout. Zero turns
+	// lockouts off.
+	LockoutAfter int
+	Lockout      time.Duration
+
+	// Window is how long after the last failure they are forgotten.
+	Window time.Duration
+}
+
+// delay is the wait after the given number of failures in a row.
+func (p Policy) delay(failures int) time.Duration {
+	if failures <= p.Free {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := p.Free + 1; i < failures; i++ {
+		d *= 2
+		if d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(d, p.MaxDelay)
+}
+
+type Limiter struct {
+	store  Store
+	prefix string
+	policy Policy
+}
+
+// New makes a limiter whose keys live in store under prefix, so that several
+// limiters can share one store.
+func New(store Store, prefix string, policy Policy) *Limiter {
+	return &Limiter{store: store, prefix: prefix, policy: policy}
+}
+
+// Wait is how long key has to wait before its next attempt; zero when it may
+// go ahead now.
+func (l *Limiter) Wait(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	state, err := l.store.Get(ctx, l.prefix+":"+key, now)
+	if err != nil {
+		return 0, err
+	}
+	if now.Before(state.BlockedUntil) {
+		return state.BlockedUntil.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt and returns the wait it earned. locked is
+// true when this failure started a lockout.
+func (l *Limiter) Fail(ctx context.Context, key string, now time.Time) (wait time.Duration, locked bool, err error) {
+	state, err := l.store.Update(ctx, l.prefix+":"+key, now, func(s State) State {
+		if now.Sub(s.LastFailure) > l.policy.Window {
+			s = State{}
+		}
+		s.Failures++
+		s.LastFailure = now
+
+		if l.policy.LockoutAfter > 0 && s.Failures >= l.policy.LockoutAfter {
+			s.BlockedUntil = now.Add(l.policy.Lockout)
+		} else {
+			s.BlockedUntil = now.Add(l.policy.delay(s.Failures))
+		}
+
+		s.Expires = now.Add(l.policy.Window)
+		if s.BlockedUntil.After(s.Expires) {
+			s.Expires = s.BlockedUntil
+		}
+		return s
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	locked = l.policy.LockoutAfter > 0 && state.Failures >= l.policy.LockoutAfter
+	return state.BlockedUntil.Sub(now), locked, nil
+}
+
+// Reset forgets the failures of key, e.g. after a successful attempt.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, l.prefix+":"+key)
+}
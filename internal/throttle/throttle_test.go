@@ -0,0 +1,108 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	Free:         2,
+	BaseDelay:    time.Second,
+	MaxDelay:     8 * time.Second,
+	LockoutAfter: 7,
+	Lockout:      time.Hour,
+	Window:       24 * time.Hour,
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	for failures, d := range want {
+		if got := testPolicy.delay(failures); got != d {
+			t.Errorf("%d failures: want %s, got %s", failures, d, got)
+		}
+	}
+}
+
+func TestLimiter_BackoffThenLockout(t *testing.T) {
+	ctx := context.Background()
+	l := New(NewMemory(), "login", testPolicy)
+	now := time.Now()
+
+	for i := 1; i <= 6; i++ {
+		wait, locked, err := l.Fail(ctx, "jane", now)
+		if err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+		if locked {
+			t.Fatalf("failure %d must not lock yet", i)
+		}
+		if got, _ := l.Wait(ctx, "jane", now); got != wait {
+			t.Fatalf("failure %d: Wait %s, Fail said %s", i, got, wait)
+		}
+		now = now.Add(wait)
+	}
+
+	wait, locked, err := l.Fail(ctx, "jane", now)
+	if err != nil || !locked || wait != time.Hour {
+		t.Fatalf("seventh failure should lock for an hour: %s %v %v", wait, locked, err)
+	}
+
+	if got, _ := l.Wait(ctx, "someone-else", now); got != 0 {
+		t.Fatalf("other keys must not be affected, got %s", got)
+	}
+}
+
+func TestLimiter_ResetAndWindow(t *testing.T) {
+	ctx := context.Background()
+	l := New(NewMemory(), "login", testPolicy)
+	now := time.Now()
+
+	for range 4 {
+		l.Fail(ctx, "jane", now)
+	}
+	if err := l.Reset(ctx, "jane"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if wait, _, _ := l.Fail(ctx, "jane", now); wait != 0 {
+		t.Fatalf("failures should start over after a reset, got %s", wait)
+	}
+
+	for range 3 {
+		l.Fail(ctx, "jane", now)
+	}
+	later := now.Add(25 * time.Hour)
+	if wait, _, _ := l.Fail(ctx, "jane", later); wait != 0 {
+		t.Fatalf("failures older than the window should be forgotten, got %s", wait)
+	}
+}
+
+func TestLimiter_PrefixesDoNotCollide(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory()
+	a := New(store, "a", Policy{LockoutAfter: 1, Lockout: time.Hour, Window: time.Hour})
+	b := New(store, "b", testPolicy)
+	now := time.Now()
+
+	a.Fail(ctx, "key", now)
+	if got, _ := b.Wait(ctx, "key", now); got != 0 {
+		t.Fatalf("limiters sharing a store must keep their own keys, got %s", got)
+	}
+}
+
+func TestMemory_Sweep(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Now()
+
+	m.Update(ctx, "old", now, func(State) State { return State{Failures: 1, Expires: now.Add(-time.Second)} })
+	m.Update(ctx, "new", now, func(State) State { return State{Failures: 1, Expires: now.Add(time.Hour)} })
+	m.Sweep(ctx, now)
+
+	if _, ok := m.state["old"]; ok {
+		t.Fatalf("expired state should be swept")
+	}
+	if _, ok := m.state["new"]; !ok {
+		t.Fatalf("live state must be kept")
+	}
+}
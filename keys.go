@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/henrique-godinho/smart-list/internal/auth"
+)
+
+var keyID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// loadKeyring reads the keys that sign access tokens and the single-purpose
+// tokens of email links, two-factor and OpenID Connect sign-in. JWT_KEYS
+// lists key ids, e.g. "2025-01,2025-07", each given by JWT_KEY_<ID> (an HMAC
+// secret) or JWT_KEY_<ID>_FILE (a PEM file with an Ed25519 or RSA key).
+// JWT_ACTIVE_KEY names the one that signs, by default the last listed.
+// Without JWT_KEYS, jwtKey alone signs as it always has. With them, jwtKey
+// only verifies tokens that carry no key id, and only while
+// JWT_ACCEPT_LEGACY is set, so that it can be retired once they expired.
+func loadKeyring(jwtKey string) (*auth.Keyring, error) {
+	var keys []*auth.SigningKey
+	var ids []string
+
+	for _, id := range strings.Split(os.Getenv("JWT_KEYS"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if !keyID.MatchString(id) {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+
+		env := "JWT_KEY_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(id))
+		var key *auth.SigningKey
+		var err error
+		if secret := os.Getenv(env); secret != "" {
+			key, err = auth.NewHMACKey(id, []byte(secret))
+		} else if file := os.Getenv(env + "_FILE"); file != "" {
+			var data []byte
+			data, err = os.ReadFile(file)
+			if err == nil {
+				key, err = auth.ParsePEMKey(id, data)
+			}
+		} else {
+			err = fmt.Errorf("key %q needs %s or %s_FILE", id, env, env)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+		ids = append(ids, id)
+	}
+
+	if len(keys) == 0 {
+		if jwtKey == "" {
+			return nil, errors.New("JWTKEY or JWT_KEYS is required")
+		}
+		return auth.LegacyKeyring(jwtKey), nil
+	}
+
+	if v := os.Getenv("JWT_ACCEPT_LEGACY"); v != "" {
+		legacy, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_ACCEPT_LEGACY %q", v)
+		}
+		if legacy {
+			if jwtKey == "" {
+				return nil, errors.New("JWT_ACCEPT_LEGACY needs JWTKEY")
+			}
+			keys = append(keys, auth.LegacyKey(jwtKey))
+		}
+	}
+
+	active := os.Getenv("JWT_ACTIVE_KEY")
+	if active == "" {
+		active = ids[len(ids)-1]
+	}
+
+	return auth.NewKeyring(active, keys...)
+}
+
+// HandleJWKS publishes the public keys access tokens are signed with, so
+// that other services can verify them.
+func (cfg *apiConfig) HandleJWKS(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, map[string][]auth.JWK{"keys": cfg.Keys.JWKS()})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+)
+
+const testRotationKey = "a-second-key-that-is-long-enough!"
+
+func TestLoadKeyring_KeepsOldTokens(t *testing.T) {
+	uid := uuid.New()
+	old, err := auth.MakeSessionJWT(uid, uuid.New(), testJWTKey, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT: %v", err)
+	}
+
+	t.Setenv("JWT_KEYS", "k1")
+	t.Setenv("JWT_KEY_K1", testRotationKey)
+	t.Setenv("JWT_ACCEPT_LEGACY", "1")
+	keys, err := loadKeyring(testJWTKey)
+	if err != nil {
+		t.Fatalf("loadKeyring: %v", err)
+	}
+
+	if got, _, err := keys.ValidateSessionJWT(old); err != nil || got != uid {
+		t.Fatalf("tokens from before the keyring should still work: %s, %v", got, err)
+	}
+
+	tok, _ := keys.MakeSessionJWT(uid, uuid.New(), time.Minute)
+	if _, _, err := auth.ValidateSessionJWT(tok, testJWTKey); err == nil {
+		t.Fatalf("new tokens should be signed with the keyring, not JWTKEY")
+	}
+}
+
+func TestLoadKeyring_RetiresJWTKey(t *testing.T) {
+	uid := uuid.New()
+	old, _ := auth.MakeSessionJWT(uid, uuid.New(), testJWTKey, time.Minute)
+	link, _ := auth.MakeEmailToken(uid, "a@example.com", testJWTKey, time.Hour)
+
+	t.Setenv("JWT_KEYS", "k1")
+	t.Setenv("JWT_KEY_K1", testRotationKey)
+	keys, err := loadKeyring("")
+	if err != nil {
+		t.Fatalf("JWTKEY should not be needed with JWT_KEYS: %v", err)
+	}
+
+	if _, _, err := keys.ValidateSessionJWT(old); err == nil {
+		t.Fatalf("tokens signed with JWTKEY must not work without JWT_ACCEPT_LEGACY")
+	}
+	if _, _, err := keys.ValidateEmailToken(link); err == nil {
+		t.Fatalf("links signed with JWTKEY must not work without JWT_ACCEPT_LEGACY")
+	}
+
+	// single-purpose tokens follow the keyring
+	link, err = keys.MakeEmailToken(uid, "a@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailToken: %v", err)
+	}
+	if got, _, err := keys.ValidateEmailToken(link); err != nil || got != uid {
+		t.Fatalf("ValidateEmailToken: %s, %v", got, err)
+	}
+}
+
+func TestLoadKeyring_Invalid(t *testing.T) {
+	for name, env := range map[string]map[string]string{
+		"bad id":         {"JWT_KEYS": "k 1"},
+		"missing key":    {"JWT_KEYS": "k1"},
+		"short secret":   {"JWT_KEYS": "k1", "JWT_KEY_K1": "short"},
+		"unknown active": {"JWT_KEYS": "k1", "JWT_KEY_K1": testRotationKey, "JWT_ACTIVE_KEY": "k2"},
+		"bad legacy":     {"JWT_KEYS": "k1", "JWT_KEY_K1": testRotationKey, "JWT_ACCEPT_LEGACY": "sometimes"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			if _, err := loadKeyring(testJWTKey); err == nil {
+				t.Fatalf("want an error")
+			}
+		})
+	}
+}
+
+func TestLoadKeyring_RequiresAKey(t *testing.T) {
+	// tokens signed with an empty secret could be forged
+	if _, err := loadKeyring(""); err == nil {
+		t.Fatalf("want an error without JWTKEY or JWT_KEYS")
+	}
+
+	t.Setenv("JWT_KEYS", "k1")
+	t.Setenv("JWT_KEY_K1", testRotationKey)
+	t.Setenv("JWT_ACCEPT_LEGACY", "1")
+	if _, err := loadKeyring(""); err == nil {
+		t.Fatalf("want an error for JWT_ACCEPT_LEGACY without JWTKEY")
+	}
+}
+
+func TestHandleJWKS(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	file := filepath.Join(t.TempDir(), "ed.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	t.Setenv("JWT_KEYS", "hs,ed-2025.1")
+	t.Setenv("JWT_KEY_HS", testRotationKey)
+	t.Setenv("JWT_KEY_ED_2025_1_FILE", file)
+	keys, err := loadKeyring(testJWTKey)
+	if err != nil {
+		t.Fatalf("loadKeyring: %v", err)
+	}
+	cfg := &apiConfig{Keys: keys}
+
+	rr := httptest.NewRecorder()
+	cfg.HandleJWKS(rr, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d", rr.Code)
+	}
+	var body struct {
+		Keys []auth.JWK `json:"keys"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Keys) != 1 || body.Keys[0].Kid != "ed-2025.1" || body.Keys[0].Alg != "EdDSA" {
+		t.Fatalf("want the Ed25519 key alone, got %+v", body.Keys)
+	}
+}
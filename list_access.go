@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+type listPermission int
+
+const (
+	permViewList listPermission = iota
+	permEditItems
+	permManageList
+)
+
+var (
+	errListNotFound   = errors.New("list not found")
+	errListForbidden  = errors.New("not allowed to modify this list")
+	errItemNotFound   = errors.New("item not found")
+	errMemberNotFound = errors.New("member not found")
+)
+
+// rolePermissions maps the caller's role on a list to what it may do with it.
+// An empty role means the caller has no accepted membership on the list.
+var rolePermissions = map[string][]listPermission{
+	"owner":  {permViewList, permEditItems, permManageList},
+	"editor": {permViewList, permEditItems},
+	"viewer": {permViewList},
+}
+
+func roleAllows(role string, perm listPermission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// loadListForUser fetches a list and checks that userID may perform perm on it.
+// Lists the caller has no access to are reported as not found so their
+// existence isn't leaked.
+func (cfg *apiConfig) loadListForUser(ctx context.Context, listID, userID uuid.UUID, perm listPermission) (database.GetListAccessRow, error) {
+	list, err := cfg.Db.GetListAccess(ctx, database.GetListAccessParams{
+		UserID: userID,
+		ListID: listID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.GetListAccessRow{}, errListNotFound
+		}
+		return database.GetListAccessRow{}, err
+	}
+
+	if list.Role == "" {
+		return database.GetListAccessRow{}, errListNotFound
+	}
+
+	if !roleAllows(list.Role, perm) {
+		return database.GetListAccessRow{}, errListForbidden
+	}
+
+	return list, nil
+}
+
+func listIDFromPath(req *http.Request) (uuid.UUID, error) {
+	listID, err := uuid.Parse(req.PathValue("list_id"))
+	if err != nil {
+		return uuid.Nil, errListNotFound
+	}
+	return listID, nil
+}
+
+func itemIDFromPath(req *http.Request) (int64, error) {
+	itemID, err := strconv.ParseInt(req.PathValue("item_id"), 10, 64)
+	if err != nil {
+		return 0, errItemNotFound
+	}
+	return itemID, nil
+}
+
+func respondWithListError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errListNotFound):
+		respondWithError(w, http.StatusNotFound, errListNotFound.Error(), nil)
+	case errors.Is(err, errItemNotFound):
+		respondWithError(w, http.StatusNotFound, errItemNotFound.Error(), nil)
+	case errors.Is(err, errMemberNotFound):
+		respondWithError(w, http.StatusNotFound, errMemberNotFound.Error(), nil)
+	case errors.Is(err, errListForbidden):
+		respondWithError(w, http.StatusForbidden, errListForbidden.Error(), nil)
+	default:
+		respondWithError(w, http.StatusInternalServerError, "failed to load list", err)
+	}
+}
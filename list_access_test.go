@@ -0,0 +1,220 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/events"
+)
+
+var listAccessCols = []string{"id", "user_id", "name", "frequency", "target_date", "created_at", "updated_at", "version", "spawn_copy", "role"}
+
+// listOwnedBy simulates GetListAccess for a single list owned by ownerID and
+// currently at version 1.
+func listOwnedBy(listID, ownerID uuid.UUID) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		if args[1].Value != listID.String() {
+			return fakeResult{cols: listAccessCols}
+		}
+		role := ""
+		if args[0].Value == ownerID.String() {
+			role = "owner"
+		}
+		now := time.Now()
+		return fakeRow(listAccessCols, listID.String(), ownerID.String(), "groceries", nil, nil, now, now, int64(1), false, role)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	cases := []struct {
+		role string
+		perm listPermission
+		want bool
+	}{
+		{"owner", permViewList, true},
+		{"owner", permEditItems, true},
+		{"owner", permManageList, true},
+		{"editor", permViewList, true},
+		{"editor", permEditItems, true},
+		{"editor", permManageList, false},
+		{"viewer", permViewList, true},
+		{"viewer", permEditItems, false},
+		{"", permViewList, false},
+		{"stranger", permEditItems, false},
+	}
+	for _, c := range cases {
+		if got := roleAllows(c.role, c.perm); got != c.want {
+			t.Errorf("roleAllows(%q, %d): want %v, got %v", c.role, c.perm, c.want, got)
+		}
+	}
+}
+
+func addToListRequest(listID uuid.UUID) *http.Request {
+	body := `{"list_id":"` + listID.String() + `","items":[]}`
+	req := httptest.NewRequest("POST", "/api/lists/"+listID.String(), strings.NewReader(body))
+	req.SetPathValue("list_id", listID.String())
+	req.Header.Set("If-Match", `"1"`)
+	return req
+}
+
+func TestHandleAddToList_OtherUsersList_NotFound(t *testing.T) {
+	listID, owner, intruder := uuid.New(), uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAddToList(rr, addToListRequest(listID), intruder)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", rr.Code)
+	}
+	if fdb.called("UpdateUserList") || fdb.called("RemoveItemsFromUserList") {
+		t.Fatalf("items of another user's list must not be touched")
+	}
+}
+
+func TestHandleAddToList_UnknownList_NotFound(t *testing.T) {
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(uuid.New(), uuid.New()),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAddToList(rr, addToListRequest(uuid.New()), uuid.New())
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", rr.Code)
+	}
+}
+
+func TestHandleAddToList_MismatchedBodyID_BadRequest(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+	})
+
+	req := addToListRequest(uuid.New())
+	req.SetPathValue("list_id", listID.String())
+	rr := httptest.NewRecorder()
+	cfg.HandleAddToList(rr, req, owner)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+	if fdb.called("UpdateUserList") {
+		t.Fatalf("list must not be updated")
+	}
+}
+
+// addedItemConfig simulates HandleAddToList adding one item to an empty list
+// owned by owner, with list events going to a real broker.
+func addedItemConfig(t *testing.T, listID, owner uuid.UUID) (*apiConfig, *fakeDB) {
+	t.Helper()
+	lookups := 0
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":   listOwnedBy(listID, owner),
+		"BumpListVersion": bumpFrom(1),
+		"GetListItems": func([]driver.NamedValue) fakeResult {
+			lookups++
+			if lookups == 1 {
+				return fakeResult{cols: listItemCols}
+			}
+			now := time.Now()
+			return fakeRow(listItemCols, int64(1), listID.String(), "milk", int64(1), "l", int64(0), now, now, true, now, owner.String())
+		},
+		"UpdateUserList":          func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RemoveItemsFromUserList": func([]driver.NamedValue) fakeResult { return fakeResult{} },
+		"GetUpdatedListById": func([]driver.NamedValue) fakeResult {
+			return fakeResult{cols: []string{"item_id", "list_id", "name", "qty", "unit", "price", "updated_at", "list_name", "frequency", "target_date", "list_updated_at"}}
+		},
+	})
+	cfg.Events = events.NewBroker()
+	return cfg, fdb
+}
+
+func TestHandleAddToList_PublishesFullItems(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, _ := addedItemConfig(t, listID, owner)
+	ch, unsubscribe := cfg.Events.Subscribe(listID)
+	defer unsubscribe()
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAddToList(rr, addToListRequest(listID), owner)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	select {
+	case ev := <-ch:
+		var item listItemResponse
+		if err := json.Unmarshal(ev.Data, &item); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if ev.Type != events.ItemAdded || item.CreatedAt.IsZero() || item.CheckedAt == nil || item.CheckedBy == nil || *item.CheckedBy != owner {
+			t.Fatalf("want the whole item added, got %s %s", ev.Type, ev.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no event published")
+	}
+}
+
+func TestHandleAddToList_FailedCommit_PublishesNothing(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := addedItemConfig(t, listID, owner)
+	fdb.commitErr = errors.New("connection lost")
+	ch, unsubscribe := cfg.Events.Subscribe(listID)
+	defer unsubscribe()
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAddToList(rr, addToListRequest(listID), owner)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("status: want 500, got %d", rr.Code)
+	}
+	if rr.Header().Get("ETag") != "" {
+		t.Fatalf("a version that wasn't stored must not be handed out")
+	}
+	select {
+	case ev := <-ch:
+		t.Fatalf("nothing was stored, yet %s was published", ev.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHandleAddToList_Owner_Updates(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	var scopedTo driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":   listOwnedBy(listID, owner),
+		"BumpListVersion": bumpFrom(1),
+		"GetListItems":    func([]driver.NamedValue) fakeResult { return fakeResult{cols: listItemCols} },
+		"UpdateUserList": func(args []driver.NamedValue) fakeResult {
+			scopedTo = args[2].Value
+			return fakeResult{affected: 1}
+		},
+		"RemoveItemsFromUserList": func([]driver.NamedValue) fakeResult { return fakeResult{} },
+		"GetUpdatedListById": func([]driver.NamedValue) fakeResult {
+			return fakeResult{cols: []string{"item_id", "list_id", "name", "qty", "unit", "price", "updated_at", "list_name", "frequency", "target_date", "list_updated_at"}}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleAddToList(rr, addToListRequest(listID), owner)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if scopedTo != owner.String() {
+		t.Fatalf("update must be scoped to the caller: got %v", scopedTo)
+	}
+	if rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("ETag: want \"2\", got %q", rr.Header().Get("ETag"))
+	}
+}
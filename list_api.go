@@ -0,0 +1,383 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/events"
+	"github.com/henrique-godinho/smart-list/internal/recurrence"
+)
+
+type listItemResponse struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Qty       int        `json:"qty"`
+	Unit      string     `json:"unit"`
+	Price     int        `json:"price"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Checked   bool       `json:"checked"`
+	CheckedAt *time.Time `json:"checked_at"`
+	CheckedBy *uuid.UUID `json:"checked_by"`
+}
+
+type listResponse struct {
+	ID         uuid.UUID          `json:"id"`
+	Name       string             `json:"name"`
+	Frequency  string             `json:"frequency"`
+	TargetDate *time.Time         `json:"target_date"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	Version    int64              `json:"version"`
+	SpawnCopy  bool               `json:"spawn_copy"`
+	Role       string             `json:"role,omitempty"`
+	Items      []listItemResponse `json:"items"`
+}
+
+// optionalField tells apart a JSON key that was omitted from one explicitly
+// set to null, so PATCH requests can clear nullable columns.
+type optionalField[T any] struct {
+	Set   bool
+	Valid bool
+	Value T
+}
+
+func (o *optionalField[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		return nil
+	}
+	o.Valid = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+const maxListNameLen = 100
+
+func validateListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("list name is required")
+	}
+	if utf8.RuneCountInString(name) > maxListNameLen {
+		return "", errors.New("list name must be 100 characters maximum")
+	}
+	return name, nil
+}
+
+// validateFrequency turns a frequency into its canonical recurrence rule.
+// An empty frequency means the list does not recur.
+func validateFrequency(frequency string) (sql.NullString, error) {
+	frequency = strings.TrimSpace(frequency)
+	if frequency == "" {
+		return sql.NullString{}, nil
+	}
+	rule, err := recurrence.Parse(frequency)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: rule.String(), Valid: true}, nil
+}
+
+// firstTargetDate is the target date of a recurring list given none: its
+// next occurrence from today. A recurring list needs a date to recur from.
+func firstTargetDate(frequency sql.NullString) sql.NullTime {
+	rule, _ := recurrence.Parse(frequency.String)
+	now := time.Now()
+	return sql.NullTime{Time: rule.Next(now, now), Valid: true}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func newListItemResponse(item database.ListItem) listItemResponse {
+	return listItemResponse{
+		ID:        item.ID,
+		Name:      item.Name,
+		Qty:       int(item.Qty.Int16),
+		Unit:      item.Unit.String,
+		Price:     int(item.Price.Int16),
+		CreatedAt: item.CreatedAt.Time,
+		UpdatedAt: item.UpdatedAt.Time,
+		Checked:   item.Checked,
+		CheckedAt: nullTimePtr(item.CheckedAt),
+		CheckedBy: nullUUIDPtr(item.CheckedBy),
+	}
+}
+
+func newListResponse(list database.List, items []database.ListItem) listResponse {
+	resp := listResponse{
+		ID:         list.ID,
+		Name:       list.Name,
+		Frequency:  list.Frequency.String,
+		TargetDate: nullTimePtr(list.TargetDate),
+		CreatedAt:  list.CreatedAt.Time,
+		UpdatedAt:  list.UpdatedAt.Time,
+		Version:    list.Version,
+		SpawnCopy:  list.SpawnCopy,
+		Items:      make([]listItemResponse, 0, len(items)),
+	}
+	for _, item := range items {
+		resp.Items = append(resp.Items, newListItemResponse(item))
+	}
+	return resp
+}
+
+// groupListRows folds the flattened list/item rows returned by
+// GetListsByUserId into one listResponse per list, keeping the query order.
+func groupListRows(rows []database.GetListsByUserIdRow) []listResponse {
+	lists := make([]listResponse, 0)
+	index := make(map[uuid.UUID]int)
+
+	for _, row := range rows {
+		i, ok := index[row.ListID]
+		if !ok {
+			lists = append(lists, listResponse{
+				ID:         row.ListID,
+				Name:       row.ListName,
+				Frequency:  row.Frequency.String,
+				TargetDate: nullTimePtr(row.TargetDate),
+				UpdatedAt:  row.ListUpdatedAt.Time,
+				Version:    row.ListVersion,
+				SpawnCopy:  row.SpawnCopy,
+				Role:       row.ListRole,
+				Items:      make([]listItemResponse, 0),
+			})
+			i = len(lists) - 1
+			index[row.ListID] = i
+		}
+
+		if !row.ItemID.Valid {
+			continue
+		}
+		lists[i].Items = append(lists[i].Items, listItemResponse{
+			ID:        row.ItemID.Int64,
+			Name:      row.ItemName.String,
+			Qty:       int(row.Qty.Int16),
+			Unit:      row.Unit.String,
+			Price:     int(row.Price.Int16),
+			CreatedAt: row.ItemCreatedAt.Time,
+			UpdatedAt: row.ItemUpdatedAt.Time,
+			Checked:   row.Checked.Bool,
+			CheckedAt: nullTimePtr(row.CheckedAt),
+			CheckedBy: nullUUIDPtr(row.CheckedBy),
+		})
+	}
+
+	return lists
+}
+
+func listFromAccessRow(row database.GetListAccessRow) database.List {
+	return database.List{
+		ID:         row.ID,
+		UserID:     row.UserID,
+		Name:       row.Name,
+		Frequency:  row.Frequency,
+		TargetDate: row.TargetDate,
+		CreatedAt:  row.CreatedAt,
+		UpdatedAt:  row.UpdatedAt,
+		Version:    row.Version,
+		SpawnCopy:  row.SpawnCopy,
+	}
+}
+
+func (cfg *apiConfig) HandleGetLists(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	rows, err := cfg.Db.GetListsByUserId(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load lists", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, groupListRows(rows))
+}
+
+func (cfg *apiConfig) HandleGetList(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permViewList)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	items, err := cfg.Db.GetListItems(req.Context(), listID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load list items", err)
+		return
+	}
+
+	resp := newListResponse(listFromAccessRow(list), items)
+	resp.Role = list.Role
+
+	w.Header().Set("ETag", listETag(list.Version))
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) HandleUpdateList(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+
+	type ListPatch struct {
+		Name       optionalField[string]    `json:"name"`
+		Frequency  optionalField[string]    `json:"frequency"`
+		TargetDate optionalField[time.Time] `json:"target_date"`
+		SpawnCopy  optionalField[bool]      `json:"spawn_copy"`
+	}
+
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	var patch ListPatch
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode list payload", err)
+		return
+	}
+
+	params := database.UpdateListMetadataParams{
+		ID:            listID,
+		UserID:        userID,
+		SetFrequency:  patch.Frequency.Set,
+		SetTargetDate: patch.TargetDate.Set,
+	}
+
+	if patch.Name.Set {
+		name, err := validateListName(patch.Name.Value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		params.Name = sql.NullString{String: name, Valid: true}
+	}
+
+	if patch.Frequency.Valid {
+		params.Frequency, err = validateFrequency(patch.Frequency.Value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+
+	if patch.TargetDate.Valid {
+		params.TargetDate = sql.NullTime{Time: patch.TargetDate.Value, Valid: true}
+	}
+
+	if patch.SpawnCopy.Valid {
+		params.SpawnCopy = sql.NullBool{Bool: patch.SpawnCopy.Value, Valid: true}
+	}
+
+	current, err := cfg.loadListForUser(req.Context(), listID, userID, permManageList)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	params.Version, err = checkIfMatch(req, current.Version)
+	if err != nil {
+		cfg.respondWithStaleList(w, req, listID, userID, err)
+		return
+	}
+
+	// a recurring list needs a date to recur from
+	frequency, targetDate := current.Frequency, current.TargetDate
+	if patch.Frequency.Set {
+		frequency = params.Frequency
+	}
+	if patch.TargetDate.Set {
+		targetDate = params.TargetDate
+	}
+	if frequency.Valid && !targetDate.Valid {
+		if patch.TargetDate.Set {
+			respondWithError(w, http.StatusBadRequest, "a recurring list needs a target date", nil)
+			return
+		}
+		params.SetTargetDate = true
+		params.TargetDate = firstTargetDate(frequency)
+	}
+
+	list, err := cfg.Db.UpdateListMetadata(req.Context(), params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.respondWithStaleList(w, req, listID, userID, errPreconditionFailed)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
+	items, err := cfg.Db.GetListItems(req.Context(), listID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load list items", err)
+		return
+	}
+
+	resp := newListResponse(list, items)
+	resp.Role = current.Role
+
+	cfg.publishListEvent(req.Context(), listID, list.Version, events.ListUpdated, resp)
+
+	w.Header().Set("ETag", listETag(list.Version))
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) HandleDeleteList(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permManageList)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	version, err := checkIfMatch(req, list.Version)
+	if err != nil {
+		cfg.respondWithStaleList(w, req, listID, userID, err)
+		return
+	}
+
+	deleted, err := cfg.Db.DeleteList(req.Context(), database.DeleteListParams{
+		ID:      listID,
+		UserID:  userID,
+		Version: version,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete list", err)
+		return
+	}
+	if deleted == 0 {
+		cfg.respondWithStaleList(w, req, listID, userID, errPreconditionFailed)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "list.delete", listID.String(), "success")
+
+	cfg.publishListEvent(req.Context(), listID, version, events.ListDeleted, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
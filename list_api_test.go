@@ -0,0 +1,192 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+func TestGroupListRows(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	rows := []database.GetListsByUserIdRow{
+		{ListID: a, ListName: "weekly", ItemID: sql.NullInt64{Int64: 1, Valid: true}, ItemName: sql.NullString{String: "milk", Valid: true}},
+		{ListID: a, ListName: "weekly", ItemID: sql.NullInt64{Int64: 2, Valid: true}, ItemName: sql.NullString{String: "eggs", Valid: true}},
+		{ListID: b, ListName: "party"},
+	}
+
+	lists := groupListRows(rows)
+
+	if len(lists) != 2 {
+		t.Fatalf("want 2 lists, got %d", len(lists))
+	}
+	if lists[0].ID != a || len(lists[0].Items) != 2 || lists[0].Items[1].Name != "eggs" {
+		t.Fatalf("unexpected first list: %+v", lists[0])
+	}
+	if lists[1].ID != b || lists[1].Items == nil || len(lists[1].Items) != 0 {
+		t.Fatalf("empty list should have an empty item array: %+v", lists[1])
+	}
+}
+
+func TestOptionalField(t *testing.T) {
+	var body struct {
+		Name      optionalField[string] `json:"name"`
+		Frequency optionalField[string] `json:"frequency"`
+	}
+	if err := json.Unmarshal([]byte(`{"frequency":null}`), &body); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if body.Name.Set {
+		t.Fatalf("omitted field must not be set")
+	}
+	if !body.Frequency.Set || body.Frequency.Valid {
+		t.Fatalf("null field must be set but invalid: %+v", body.Frequency)
+	}
+}
+
+func TestHandleDeleteList_OtherUsersList_NotFound(t *testing.T) {
+	listID, owner, intruder := uuid.New(), uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/lists/"+listID.String(), nil)
+	req.SetPathValue("list_id", listID.String())
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleDeleteList(rr, req, intruder)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", rr.Code)
+	}
+	if fdb.called("DeleteList") {
+		t.Fatalf("another user's list must not be deleted")
+	}
+}
+
+func TestHandleUpdateList_ClearsFrequency(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	var setFreq, freq driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+		"UpdateListMetadata": func(args []driver.NamedValue) fakeResult {
+			setFreq, freq = args[1].Value, args[2].Value
+			now := time.Now()
+			return fakeRow(listCols, listID.String(), owner.String(), "renamed", nil, nil, now, now, int64(2), false, nil)
+		},
+		"GetListItems": func([]driver.NamedValue) fakeResult {
+			return fakeResult{cols: []string{"id", "list_id", "name", "qty", "unit", "price", "created_at", "updated_at"}}
+		},
+	})
+
+	req := httptest.NewRequest("PATCH", "/api/lists/"+listID.String(), strings.NewReader(`{"name":"renamed","frequency":null}`))
+	req.SetPathValue("list_id", listID.String())
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleUpdateList(rr, req, owner)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if setFreq != true || freq != nil {
+		t.Fatalf("frequency should be cleared: set=%v value=%v", setFreq, freq)
+	}
+
+	var got listResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Name != "renamed" || got.Items == nil {
+		t.Fatalf("unexpected response: %+v", got)
+	}
+}
+
+func TestHandleUpdateList_Frequency_DefaultsTargetDate(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	var setDate, date driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+		"UpdateListMetadata": func(args []driver.NamedValue) fakeResult {
+			setDate, date = args[3].Value, args[4].Value
+			now := time.Now()
+			return fakeRow(listCols, listID.String(), owner.String(), "groceries", "weekly", date, now, now, int64(2), false, date)
+		},
+		"GetListItems": func([]driver.NamedValue) fakeResult { return fakeResult{cols: listItemCols} },
+	})
+
+	req := httptest.NewRequest("PATCH", "/api/lists/"+listID.String(), strings.NewReader(`{"frequency":"weekly"}`))
+	req.SetPathValue("list_id", listID.String())
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleUpdateList(rr, req, owner)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if d, ok := date.(time.Time); setDate != true || !ok || !d.After(time.Now()) {
+		t.Fatalf("a list made recurring without a date should get its next occurrence: set=%v value=%v", setDate, date)
+	}
+}
+
+func TestHandleUpdateList_ClearTargetDate_Recurring_BadRequest(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(listAccessCols, listID.String(), owner.String(), "groceries", "weekly", now, now, now, int64(1), false, "owner")
+		},
+	})
+
+	req := httptest.NewRequest("PATCH", "/api/lists/"+listID.String(), strings.NewReader(`{"target_date":null}`))
+	req.SetPathValue("list_id", listID.String())
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleUpdateList(rr, req, owner)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+	if fdb.called("UpdateListMetadata") {
+		t.Fatalf("a recurring list must keep its target date")
+	}
+}
+
+func TestValidateFrequency(t *testing.T) {
+	got, err := validateFrequency(" bi-weekly ")
+	if err != nil || !got.Valid || got.String != "biweekly" {
+		t.Fatalf("bi-weekly: got %+v, %v", got, err)
+	}
+	if got, err := validateFrequency(""); err != nil || got.Valid {
+		t.Fatalf("empty frequency should mean no recurrence: %+v, %v", got, err)
+	}
+	if _, err := validateFrequency("whenever"); err == nil {
+		t.Fatalf("free text frequency should be rejected")
+	}
+}
+
+func TestHandleUpdateList_InvalidFrequency_BadRequest(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+	})
+
+	req := httptest.NewRequest("PATCH", "/api/lists/"+listID.String(), strings.NewReader(`{"frequency":"FREQ=HOURLY"}`))
+	req.SetPathValue("list_id", listID.String())
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleUpdateList(rr, req, owner)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+	if fdb.called("UpdateListMetadata") {
+		t.Fatalf("list must not be updated")
+	}
+}
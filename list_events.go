@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/events"
+)
+
+var sseHeartbeat = 25 * time.Second
+
+// publishListEvent notifies subscribers of a list about a committed write.
+// Failures are only logged: the write itself already succeeded.
+func (cfg *apiConfig) publishListEvent(ctx context.Context, listID uuid.UUID, version int64, eventType string, data any) {
+	if cfg.Events == nil {
+		return
+	}
+
+	ev := events.Event{
+		Type:    eventType,
+		ListID:  listID,
+		Version: version,
+	}
+
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("events: failed to encode %s: %v", eventType, err)
+			return
+		}
+		ev.Data = raw
+	}
+
+	if err := cfg.Events.Publish(ctx, ev); err != nil {
+		log.Printf("events: failed to publish %s: %v", eventType, err)
+	}
+}
+
+// itemChanges compares a list's items before and after a bulk save.
+func itemChanges(before, after []listItemResponse) (added, updated, removed []listItemResponse) {
+	old := make(map[int64]listItemResponse, len(before))
+	for _, item := range before {
+		old[item.ID] = item
+	}
+
+	for _, item := range after {
+		prev, ok := old[item.ID]
+		if !ok {
+			added = append(added, item)
+			continue
+		}
+		delete(old, item.ID)
+		if prev.Name != item.Name || prev.Qty != item.Qty || prev.Unit != item.Unit || prev.Price != item.Price || prev.Checked != item.Checked {
+			updated = append(updated, item)
+		}
+	}
+
+	for _, item := range before {
+		if _, ok := old[item.ID]; ok {
+			removed = append(removed, item)
+		}
+	}
+
+	return added, updated, removed
+}
+
+func writeSSE(w http.ResponseWriter, ev events.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Version, ev.Type, data)
+	return err
+}
+
+// recheckAuth makes sure the session or personal access token a long-lived
+// request was authenticated with is still good.
+func (cfg *apiConfig) recheckAuth(req *http.Request, userID uuid.UUID) error {
+	if tokenScopeFromContext(req.Context()) == "" {
+		return cfg.checkSession(req.Context(), userID, sessionIDFromContext(req.Context()))
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return err
+	}
+	pat, err := cfg.Db.UsePersonalAccessToken(req.Context(), auth.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if pat.UserID != userID {
+		return errSessionInvalid
+	}
+	return nil
+}
+
+// HandleListEvents streams the changes made to a list as Server-Sent Events.
+func (cfg *apiConfig) HandleListEvents(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permViewList)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok || cfg.Events == nil {
+		respondWithError(w, http.StatusNotImplemented, "streaming not supported", nil)
+		return
+	}
+
+	ch, cancel := cfg.Events.Subscribe(listID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// clients compare this version with theirs to detect missed events
+	fmt.Fprintf(w, "retry: 5000\n\n")
+	writeSSE(w, events.Event{Type: "ready", ListID: listID, Version: list.Version})
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+			if ev.Type == events.ListDeleted {
+				return
+			}
+
+		case <-heartbeat.C:
+			// streams outlive the access token that opened them: signed out
+			// sessions, revoked tokens and members removed from the list stop
+			// receiving its changes
+			if err := cfg.recheckAuth(req, userID); err != nil {
+				return
+			}
+			if _, err := cfg.loadListForUser(req.Context(), listID, userID, permViewList); err != nil {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/events"
+)
+
+func TestItemChanges(t *testing.T) {
+	before := []listItemResponse{
+		{ID: 1, Name: "milk", Qty: 1},
+		{ID: 2, Name: "eggs", Qty: 12},
+		{ID: 3, Name: "bread", Qty: 1},
+	}
+	after := []listItemResponse{
+		{ID: 1, Name: "milk", Qty: 1},
+		{ID: 2, Name: "eggs", Qty: 6},
+		{ID: 4, Name: "butter", Qty: 1},
+	}
+
+	added, updated, removed := itemChanges(before, after)
+
+	if len(added) != 1 || added[0].ID != 4 {
+		t.Fatalf("added: %+v", added)
+	}
+	if len(updated) != 1 || updated[0].ID != 2 {
+		t.Fatalf("updated: %+v", updated)
+	}
+	if len(removed) != 1 || removed[0].ID != 3 {
+		t.Fatalf("removed: %+v", removed)
+	}
+}
+
+// streamEnds serves a list event stream and fails the test unless the
+// handler hangs up on its own.
+func streamEnds(t *testing.T, cfg *apiConfig, req *http.Request, userID uuid.UUID) {
+	t.Helper()
+	heartbeat := sseHeartbeat
+	sseHeartbeat = 10 * time.Millisecond
+	t.Cleanup(func() { sseHeartbeat = heartbeat })
+	cfg.Events = events.NewBroker()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cfg.HandleListEvents(httptest.NewRecorder(), req, userID)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("stream should end once its credentials are revoked")
+	}
+}
+
+func TestHandleListEvents_RevokedSession_EndsStream(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+		// signed out everywhere after the stream was opened
+		"TouchSession": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 0} },
+	})
+
+	req := httptest.NewRequest("GET", "/api/lists/"+listID.String()+"/events", nil)
+	req.SetPathValue("list_id", listID.String())
+	req = req.WithContext(withSessionID(req.Context(), uuid.New()))
+	streamEnds(t, cfg, req, owner)
+
+	if !fdb.called("TouchSession") {
+		t.Fatalf("the session should be checked on heartbeat")
+	}
+}
+
+func TestHandleListEvents_RevokedAccessToken_EndsStream(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":          listOwnedBy(listID, owner),
+		"UsePersonalAccessToken": accessTokenFor(owner, "slpat_other", tokenScopeRead),
+	})
+
+	req := bearerRequest("GET", "/api/lists/"+listID.String()+"/events", "slpat_revoked")
+	req.SetPathValue("list_id", listID.String())
+	req = req.WithContext(withTokenScope(req.Context(), tokenScopeRead))
+	streamEnds(t, cfg, req, owner)
+
+	if !fdb.called("UsePersonalAccessToken") {
+		t.Fatalf("the access token should be checked on heartbeat")
+	}
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/events"
+	"github.com/lib/pq"
+)
+
+const maxItemNameLen = 100
+
+func validateItemName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("item name is required")
+	}
+	if utf8.RuneCountInString(name) > maxItemNameLen {
+		return "", errors.New("item name must be 100 characters maximum")
+	}
+	return name, nil
+}
+
+func validateQty(qty int) (sql.NullInt16, error) {
+	if qty < 1 || qty > math.MaxInt16 {
+		return sql.NullInt16{}, errors.New("quantity must be a positive number")
+	}
+	return sql.NullInt16{Int16: int16(qty), Valid: true}, nil
+}
+
+func validatePrice(price int) (sql.NullInt16, error) {
+	if price < 0 || price > math.MaxInt16 {
+		return sql.NullInt16{}, errors.New("price must be zero or a positive number")
+	}
+	return sql.NullInt16{Int16: int16(price), Valid: true}, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pq.Error
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func (cfg *apiConfig) HandleCreateListItem(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+
+	type NewItem struct {
+		Name  string `json:"name"`
+		Qty   *int   `json:"qty"`
+		Unit  string `json:"unit"`
+		Price *int   `json:"price"`
+	}
+
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	var newItem NewItem
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&newItem); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode item payload", err)
+		return
+	}
+
+	params := database.CreateListItemParams{
+		ListID: listID,
+		UserID: userID,
+	}
+
+	params.Name, err = validateItemName(newItem.Name)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	qty := 1
+	if newItem.Qty != nil {
+		qty = *newItem.Qty
+	}
+	params.Qty, err = validateQty(qty)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if newItem.Price != nil {
+		params.Price, err = validatePrice(*newItem.Price)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+
+	if unit := strings.TrimSpace(newItem.Unit); unit != "" {
+		params.Unit = sql.NullString{String: unit, Valid: true}
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permEditItems)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	version, err := checkIfMatch(req, list.Version)
+	if err != nil {
+		cfg.respondWithStaleList(w, req, listID, userID, err)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	version, err = bumpListVersion(req.Context(), qtx, listID, version)
+	if err != nil {
+		if errors.Is(err, errPreconditionFailed) {
+			cfg.respondWithStaleList(w, req, listID, userID, err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
+	item, err := qtx.CreateListItem(req.Context(), params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithListError(w, errListNotFound)
+			return
+		}
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "item already exists in this list", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to add item", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to add item", err)
+		return
+	}
+
+	resp := newListItemResponse(item)
+	cfg.publishListEvent(req.Context(), listID, version, events.ItemAdded, resp)
+
+	w.Header().Set("ETag", listETag(version))
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) HandleUpdateListItem(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+
+	type ItemPatch struct {
+		Name  optionalField[string] `json:"name"`
+		Qty   optionalField[int]    `json:"qty"`
+		Unit  optionalField[string] `json:"unit"`
+		Price optionalField[int]    `json:"price"`
+	}
+
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	itemID, err := itemIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	var patch ItemPatch
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode item payload", err)
+		return
+	}
+
+	params := database.UpdateListItemParams{
+		ID:       itemID,
+		ListID:   listID,
+		UserID:   userID,
+		SetUnit:  patch.Unit.Set,
+		SetPrice: patch.Price.Set,
+	}
+
+	if patch.Name.Set {
+		name, err := validateItemName(patch.Name.Value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		params.Name = sql.NullString{String: name, Valid: true}
+	}
+
+	if patch.Qty.Set {
+		params.Qty, err = validateQty(patch.Qty.Value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+
+	if patch.Unit.Valid && strings.TrimSpace(patch.Unit.Value) != "" {
+		params.Unit = sql.NullString{String: strings.TrimSpace(patch.Unit.Value), Valid: true}
+	}
+
+	if patch.Price.Valid {
+		params.Price, err = validatePrice(patch.Price.Value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permEditItems)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	version, err := checkIfMatch(req, list.Version)
+	if err != nil {
+		cfg.respondWithStaleList(w, req, listID, userID, err)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	version, err = bumpListVersion(req.Context(), qtx, listID, version)
+	if err != nil {
+		if errors.Is(err, errPreconditionFailed) {
+			cfg.respondWithStaleList(w, req, listID, userID, err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
+	item, err := qtx.UpdateListItem(req.Context(), params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithListError(w, errItemNotFound)
+			return
+		}
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "item already exists in this list", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update item", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update item", err)
+		return
+	}
+
+	resp := newListItemResponse(item)
+	cfg.publishListEvent(req.Context(), listID, version, events.ItemUpdated, resp)
+
+	w.Header().Set("ETag", listETag(version))
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) HandleDeleteListItem(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	itemID, err := itemIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permEditItems)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	version, err := checkIfMatch(req, list.Version)
+	if err != nil {
+		cfg.respondWithStaleList(w, req, listID, userID, err)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	version, err = bumpListVersion(req.Context(), qtx, listID, version)
+	if err != nil {
+		if errors.Is(err, errPreconditionFailed) {
+			cfg.respondWithStaleList(w, req, listID, userID, err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
+	item, err := qtx.DeleteListItem(req.Context(), database.DeleteListItemParams{
+		ID:     itemID,
+		ListID: listID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithListError(w, errItemNotFound)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to remove item", err)
+		return
+	}
+
+	// checked items were already recorded when they were checked off
+	if !item.Checked {
+		if err = recordPurchase(req.Context(), qtx, userID, item, purchaseRemoved); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to remove item", err)
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to remove item", err)
+		return
+	}
+
+	cfg.publishListEvent(req.Context(), listID, version, events.ItemRemoved, newListItemResponse(item))
+
+	w.Header().Set("ETag", listETag(version))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleToggleListItem checks an item off or back on. The body may set
+// "checked" explicitly; without it the current state is flipped.
+func (cfg *apiConfig) HandleToggleListItem(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+
+	type Toggle struct {
+		Checked *bool `json:"checked"`
+	}
+
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	itemID, err := itemIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	var toggle Toggle
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&toggle); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "failed to decode item payload", err)
+		return
+	}
+
+	params := database.SetListItemCheckedParams{
+		ID:     itemID,
+		ListID: listID,
+		UserID: userID,
+	}
+	if toggle.Checked != nil {
+		params.Checked = sql.NullBool{Bool: *toggle.Checked, Valid: true}
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permEditItems)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	version, err := checkIfMatch(req, list.Version)
+	if err != nil {
+		cfg.respondWithStaleList(w, req, listID, userID, err)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	version, err = bumpListVersion(req.Context(), qtx, listID, version)
+	if err != nil {
+		if errors.Is(err, errPreconditionFailed) {
+			cfg.respondWithStaleList(w, req, listID, userID, err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
+	prev, err := qtx.GetListItemForUpdate(req.Context(), database.GetListItemForUpdateParams{
+		ID:     itemID,
+		ListID: listID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithListError(w, errItemNotFound)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update item", err)
+		return
+	}
+
+	item, err := qtx.SetListItemChecked(req.Context(), params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithListError(w, errItemNotFound)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update item", err)
+		return
+	}
+
+	switch {
+	case item.Checked && !prev.Checked:
+		err = recordPurchase(req.Context(), qtx, userID, item, purchaseChecked)
+	case !item.Checked && prev.Checked:
+		// unchecking undoes an accidental tick, so forget that purchase
+		err = qtx.DiscardLatestPurchase(req.Context(), sql.NullInt64{Int64: item.ID, Valid: true})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update item", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update item", err)
+		return
+	}
+
+	resp := newListItemResponse(item)
+	cfg.publishListEvent(req.Context(), listID, version, events.ItemUpdated, resp)
+
+	w.Header().Set("ETag", listETag(version))
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// HandleClearCheckedItems removes every checked item from a list and returns
+// the removed items.
+func (cfg *apiConfig) HandleClearCheckedItems(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permEditItems)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	version, err := checkIfMatch(req, list.Version)
+	if err != nil {
+		cfg.respondWithStaleList(w, req, listID, userID, err)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	version, err = bumpListVersion(req.Context(), qtx, listID, version)
+	if err != nil {
+		if errors.Is(err, errPreconditionFailed) {
+			cfg.respondWithStaleList(w, req, listID, userID, err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update list", err)
+		return
+	}
+
+	items, err := qtx.DeleteCheckedListItems(req.Context(), database.DeleteCheckedListItemsParams{
+		ListID: listID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to remove checked items", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to remove checked items", err)
+		return
+	}
+
+	removed := make([]listItemResponse, 0, len(items))
+	for _, item := range items {
+		resp := newListItemResponse(item)
+		removed = append(removed, resp)
+		cfg.publishListEvent(req.Context(), listID, version, events.ItemRemoved, resp)
+	}
+
+	w.Header().Set("ETag", listETag(version))
+	respondWithJSON(w, http.StatusOK, removed)
+}
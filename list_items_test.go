@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var listItemCols = []string{"id", "list_id", "name", "qty", "unit", "price", "created_at", "updated_at", "checked", "checked_at", "checked_by"}
+
+func TestValidateQty(t *testing.T) {
+	for _, qty := range []int{0, -1, 40000} {
+		if _, err := validateQty(qty); err == nil {
+			t.Errorf("qty %d should be rejected", qty)
+		}
+	}
+	if got, err := validateQty(3); err != nil || got.Int16 != 3 || !got.Valid {
+		t.Errorf("qty 3: got %+v, %v", got, err)
+	}
+}
+
+func TestHandleCreateListItem_Owner(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+		"CreateListItem": func(args []driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(listItemCols, int64(7), listID.String(), args[1].Value, int64(2), nil, nil, now, now, false, nil, nil)
+		},
+		"BumpListVersion": bumpFrom(1),
+	})
+
+	req := httptest.NewRequest("POST", "/api/lists/"+listID.String()+"/items", strings.NewReader(`{"name":"  milk ","qty":2}`))
+	req.SetPathValue("list_id", listID.String())
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleCreateListItem(rr, req, owner)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status: want 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"name":"milk"`) {
+		t.Fatalf("item name should be trimmed: %s", rr.Body.String())
+	}
+	if fdb.called("RemoveItemsFromUserList") {
+		t.Fatalf("adding an item must not touch other items")
+	}
+}
+
+func TestHandleDeleteListItem_OtherUsersList_NotFound(t *testing.T) {
+	listID, owner, intruder := uuid.New(), uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/lists/"+listID.String()+"/items/1", nil)
+	req.SetPathValue("list_id", listID.String())
+	req.SetPathValue("item_id", "1")
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleDeleteListItem(rr, req, intruder)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", rr.Code)
+	}
+	if fdb.called("DeleteListItem") {
+		t.Fatalf("another user's item must not be deleted")
+	}
+}
+
+func TestHandleDeleteListItem_UnknownItem_NotFound(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":   listOwnedBy(listID, owner),
+		"BumpListVersion": bumpFrom(1),
+		"DeleteListItem":  func([]driver.NamedValue) fakeResult { return fakeResult{cols: listItemCols} },
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/lists/"+listID.String()+"/items/42", nil)
+	req.SetPathValue("list_id", listID.String())
+	req.SetPathValue("item_id", "42")
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleDeleteListItem(rr, req, owner)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", rr.Code)
+	}
+}
+
+func toggleRequest(listID uuid.UUID, body string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/lists/"+listID.String()+"/items/7/toggle", strings.NewReader(body))
+	req.SetPathValue("list_id", listID.String())
+	req.SetPathValue("item_id", "7")
+	req.Header.Set("If-Match", `"1"`)
+	return req
+}
+
+func TestHandleToggleListItem(t *testing.T) {
+	cases := []struct {
+		body string
+		want driver.Value
+	}{
+		{"", nil},
+		{`{"checked":true}`, true},
+		{`{"checked":false}`, false},
+	}
+
+	for _, c := range cases {
+		listID, owner := uuid.New(), uuid.New()
+		var checked driver.Value = "unset"
+		cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+			"GetListAccess":        listOwnedBy(listID, owner),
+			"BumpListVersion":      bumpFrom(1),
+			"GetListItemForUpdate": itemInList(listID, false),
+			"RecordPurchase":       func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+			"SetListItemChecked": func(args []driver.NamedValue) fakeResult {
+				checked = args[0].Value
+				now := time.Now()
+				return fakeRow(listItemCols, int64(7), listID.String(), "milk", int64(1), nil, nil, now, now, true, now, owner.String())
+			},
+		})
+
+		rr := httptest.NewRecorder()
+		cfg.HandleToggleListItem(rr, toggleRequest(listID, c.body), owner)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("body %q: want 200, got %d (%s)", c.body, rr.Code, rr.Body.String())
+		}
+		if checked != c.want {
+			t.Fatalf("body %q: checked arg want %v, got %v", c.body, c.want, checked)
+		}
+		if !strings.Contains(rr.Body.String(), `"checked_by":"`+owner.String()+`"`) {
+			t.Fatalf("response should tell who checked the item: %s", rr.Body.String())
+		}
+		if !fdb.called("RecordPurchase") {
+			t.Fatalf("body %q: checking an item off should record a purchase", c.body)
+		}
+	}
+}
+
+// itemInList simulates GetListItemForUpdate for item 7 of listID.
+func itemInList(listID uuid.UUID, checked bool) fakeHandler {
+	return func([]driver.NamedValue) fakeResult {
+		now := time.Now()
+		var checkedAt driver.Value
+		if checked {
+			checkedAt = now
+		}
+		return fakeRow(listItemCols, int64(7), listID.String(), "milk", int64(1), nil, nil, now, now, checked, checkedAt, nil)
+	}
+}
+
+func TestHandleToggleListItem_Uncheck_DiscardsPurchase(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	var discarded driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":        listOwnedBy(listID, owner),
+		"BumpListVersion":      bumpFrom(1),
+		"GetListItemForUpdate": itemInList(listID, true),
+		"SetListItemChecked": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(listItemCols, int64(7), listID.String(), "milk", int64(1), nil, nil, now, now, false, nil, nil)
+		},
+		"DiscardLatestPurchase": func(args []driver.NamedValue) fakeResult {
+			discarded = args[0].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleToggleListItem(rr, toggleRequest(listID, `{"checked":false}`), owner)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if discarded != int64(7) {
+		t.Fatalf("purchase of item 7 should be discarded, got %v", discarded)
+	}
+	if fdb.called("RecordPurchase") {
+		t.Fatalf("unchecking must not record a purchase")
+	}
+}
+
+func TestHandleToggleListItem_Viewer_Forbidden(t *testing.T) {
+	listID, viewer := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listSharedWith(listID, map[uuid.UUID]string{viewer: "viewer"}),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleToggleListItem(rr, toggleRequest(listID, ""), viewer)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("status: want 403, got %d", rr.Code)
+	}
+	if fdb.called("SetListItemChecked") {
+		t.Fatalf("viewers must not check items off")
+	}
+}
+
+func TestHandleClearCheckedItems(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":   listOwnedBy(listID, owner),
+		"BumpListVersion": bumpFrom(1),
+		"DeleteCheckedListItems": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeResult{
+				cols: listItemCols,
+				rows: [][]driver.Value{
+					{int64(3), listID.String(), "milk", int64(1), nil, nil, now, now, true, now, owner.String()},
+					{int64(5), listID.String(), "eggs", int64(6), nil, nil, now, now, true, now, owner.String()},
+				},
+			}
+		},
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/lists/"+listID.String()+"/items/checked", nil)
+	req.SetPathValue("list_id", listID.String())
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleClearCheckedItems(rr, req, owner)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var removed []listItemResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &removed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(removed) != 2 || removed[1].Name != "eggs" {
+		t.Fatalf("unexpected removed items: %+v", removed)
+	}
+	if rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("ETag: want \"2\", got %q", rr.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+var errInvitationNotFound = errors.New("invitation not found")
+
+type listMemberResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type invitationResponse struct {
+	ListID    uuid.UUID `json:"list_id"`
+	ListName  string    `json:"list_name"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// validateMemberRole only accepts roles that can be granted to other users;
+// ownership can't be shared or transferred.
+func validateMemberRole(role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role != "editor" && role != "viewer" {
+		return "", errors.New("role must be editor or viewer")
+	}
+	return role, nil
+}
+
+func memberIDFromPath(req *http.Request) (uuid.UUID, error) {
+	memberID, err := uuid.Parse(req.PathValue("member_id"))
+	if err != nil {
+		return uuid.Nil, errMemberNotFound
+	}
+	return memberID, nil
+}
+
+// memberTarget names a membership in the audit log.
+func memberTarget(listID, memberID uuid.UUID) string {
+	return listID.String() + ":" + memberID.String()
+}
+
+func (cfg *apiConfig) HandleGetListMembers(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	_, err = cfg.loadListForUser(req.Context(), listID, userID, permViewList)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	rows, err := cfg.Db.GetListMembers(req.Context(), listID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load members", err)
+		return
+	}
+
+	members := make([]listMemberResponse, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, listMemberResponse{
+			UserID:    row.UserID,
+			Email:     row.Email,
+			FirstName: row.FirstName,
+			LastName:  row.LastName,
+			Role:      row.Role,
+			Status:    row.Status,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, members)
+}
+
+func (cfg *apiConfig) HandleInviteListMember(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+
+	type Invite struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	var invite Invite
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&invite); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode invitation payload", err)
+		return
+	}
+
+	role, err := validateMemberRole(invite.Role)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	_, _, email, err := auth.ValidateInput("login", map[string]string{"email": invite.Email})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	_, err = cfg.loadListForUser(req.Context(), listID, userID, permManageList)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	if err := cfg.requireVerifiedEmail(req.Context(), userID); err != nil {
+		respondWithVerificationError(w, err)
+		return
+	}
+
+	// the answer is the same whether or not the address has an account, so
+	// that inviting can't be used to find out who is signed up
+	accepted := map[string]string{"email": email, "role": role, "status": "pending"}
+
+	invitee, err := cfg.Db.GetUserByEmail(req.Context(), email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithJSON(w, http.StatusAccepted, accepted)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "error finding user", err)
+		return
+	}
+
+	if !invitee.IsActive {
+		respondWithJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	_, err = cfg.Db.AddListMember(req.Context(), database.AddListMemberParams{
+		ListID:    listID,
+		UserID:    invitee.ID,
+		Role:      role,
+		Status:    "pending",
+		InvitedBy: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithJSON(w, http.StatusAccepted, accepted)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to invite member", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "list.member.invite", memberTarget(listID, invitee.ID), "success")
+
+	respondWithJSON(w, http.StatusAccepted, accepted)
+}
+
+func (cfg *apiConfig) HandleUpdateListMember(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+
+	type RoleChange struct {
+		Role string `json:"role"`
+	}
+
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	memberID, err := memberIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	var change RoleChange
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&change); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode member payload", err)
+		return
+	}
+
+	role, err := validateMemberRole(change.Role)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	_, err = cfg.loadListForUser(req.Context(), listID, userID, permManageList)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	member, err := cfg.Db.UpdateListMemberRole(req.Context(), database.UpdateListMemberRoleParams{
+		Role:   role,
+		ListID: listID,
+		UserID: memberID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithListError(w, errMemberNotFound)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update member", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "list.member.role", memberTarget(listID, memberID), "success")
+
+	respondWithJSON(w, http.StatusOK, listMemberResponse{
+		UserID:    member.UserID,
+		Role:      member.Role,
+		Status:    member.Status,
+		CreatedAt: member.CreatedAt,
+	})
+}
+
+// HandleRemoveListMember lets the owner remove anyone but themselves, and any
+// other member leave the list on their own.
+func (cfg *apiConfig) HandleRemoveListMember(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	memberID, err := memberIDFromPath(req)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	perm := permManageList
+	if memberID == userID {
+		perm = permViewList
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, perm)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	if memberID == userID && list.Role == "owner" {
+		respondWithError(w, http.StatusBadRequest, "the owner can't leave their own list", nil)
+		return
+	}
+
+	removed, err := cfg.Db.RemoveListMember(req.Context(), database.RemoveListMemberParams{
+		ListID: listID,
+		UserID: memberID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to remove member", err)
+		return
+	}
+	if removed == 0 {
+		respondWithListError(w, errMemberNotFound)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "list.member.remove", memberTarget(listID, memberID), "success")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) HandleGetInvitations(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	rows, err := cfg.Db.GetPendingInvitations(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load invitations", err)
+		return
+	}
+
+	invitations := make([]invitationResponse, 0, len(rows))
+	for _, row := range rows {
+		invitations = append(invitations, invitationResponse{
+			ListID:    row.ListID,
+			ListName:  row.ListName,
+			Role:      row.Role,
+			InvitedBy: row.InvitedByEmail.String,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, invitations)
+}
+
+func (cfg *apiConfig) HandleAcceptInvitation(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errInvitationNotFound.Error(), nil)
+		return
+	}
+
+	if err := cfg.requireVerifiedEmail(req.Context(), userID); err != nil {
+		respondWithVerificationError(w, err)
+		return
+	}
+
+	accepted, err := cfg.Db.AcceptListInvitation(req.Context(), database.AcceptListInvitationParams{
+		ListID: listID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to accept invitation", err)
+		return
+	}
+	if accepted == 0 {
+		respondWithError(w, http.StatusNotFound, errInvitationNotFound.Error(), nil)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "list.invitation.accept", listID.String(), "success")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) HandleDeclineInvitation(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	listID, err := listIDFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errInvitationNotFound.Error(), nil)
+		return
+	}
+
+	declined, err := cfg.Db.DeclineListInvitation(req.Context(), database.DeclineListInvitationParams{
+		ListID: listID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to decline invitation", err)
+		return
+	}
+	if declined == 0 {
+		respondWithError(w, http.StatusNotFound, errInvitationNotFound.Error(), nil)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "list.invitation.decline", listID.String(), "success")
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// listSharedWith simulates GetListAccess for a list whose accepted members
+// have the given roles.
+func listSharedWith(listID uuid.UUID, roles map[uuid.UUID]string) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		if args[1].Value != listID.String() {
+			return fakeResult{cols: listAccessCols}
+		}
+		role := ""
+		for id, r := range roles {
+			if args[0].Value == id.String() {
+				role = r
+			}
+		}
+		now := time.Now()
+		return fakeRow(listAccessCols, listID.String(), uuid.NewString(), "shared", nil, nil, now, now, int64(1), false, role)
+	}
+}
+
+var userByEmailCols = []string{"id", "email", "first_name", "last_name", "is_active", "created_at", "updated_at", "hashed_password", "email_verified_at"}
+
+func inviteRequest(listID uuid.UUID, body string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/lists/"+listID.String()+"/members", strings.NewReader(body))
+	req.SetPathValue("list_id", listID.String())
+	return req
+}
+
+func TestHandleInviteListMember_EditorForbidden(t *testing.T) {
+	listID, owner, editor := uuid.New(), uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listSharedWith(listID, map[uuid.UUID]string{owner: "owner", editor: "editor"}),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleInviteListMember(rr, inviteRequest(listID, `{"email":"friend@example.com","role":"viewer"}`), editor)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("status: want 403, got %d", rr.Code)
+	}
+	if fdb.called("AddListMember") {
+		t.Fatalf("editors must not invite members")
+	}
+}
+
+func TestHandleInviteListMember_OwnerInvites(t *testing.T) {
+	listID, owner, friend := uuid.New(), uuid.New(), uuid.New()
+	var invitedRole, invitedStatus, action, target driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listSharedWith(listID, map[uuid.UUID]string{owner: "owner"}),
+		"GetUserByEmail": func(args []driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(userByEmailCols, friend.String(), "friend@example.com", "Fri", "End", true, now, now, "hash", now)
+		},
+		"AddListMember": func(args []driver.NamedValue) fakeResult {
+			invitedRole, invitedStatus = args[2].Value, args[3].Value
+			now := time.Now()
+			return fakeRow([]string{"list_id", "user_id", "role", "status", "invited_by", "created_at", "updated_at"},
+				listID.String(), friend.String(), args[2].Value, args[3].Value, owner.String(), now, now)
+		},
+		"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+			action, target = args[1].Value, args[2].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleInviteListMember(rr, inviteRequest(listID, `{"email":"friend@example.com","role":"Editor"}`), owner)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("status: want 202, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if invitedRole != "editor" || invitedStatus != "pending" {
+		t.Fatalf("invitation should be a pending editor: role=%v status=%v", invitedRole, invitedStatus)
+	}
+	if action != "list.member.invite" || target != listID.String()+":"+friend.String() {
+		t.Fatalf("the invitation should be audited, got %v %v", action, target)
+	}
+}
+
+func TestHandleInviteListMember_SameAnswerForUnknownEmail(t *testing.T) {
+	listID, owner, friend := uuid.New(), uuid.New(), uuid.New()
+	invite := func(user fakeResult) *httptest.ResponseRecorder {
+		cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+			"GetListAccess":  listSharedWith(listID, map[uuid.UUID]string{owner: "owner"}),
+			"GetUserByEmail": func([]driver.NamedValue) fakeResult { return user },
+			"AddListMember": func(args []driver.NamedValue) fakeResult {
+				now := time.Now()
+				return fakeRow([]string{"list_id", "user_id", "role", "status", "invited_by", "created_at", "updated_at"},
+					listID.String(), friend.String(), args[2].Value, args[3].Value, owner.String(), now, now)
+			},
+			"CreateAuditEvent": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		})
+		rr := httptest.NewRecorder()
+		cfg.HandleInviteListMember(rr, inviteRequest(listID, `{"email":"friend@example.com","role":"viewer"}`), owner)
+		return rr
+	}
+
+	now := time.Now()
+	known := invite(fakeRow(userByEmailCols, friend.String(), "friend@example.com", "Fri", "End", true, now, now, "hash", now))
+	unknown := invite(fakeResult{cols: userByEmailCols})
+	inactive := invite(fakeRow(userByEmailCols, friend.String(), "friend@example.com", "Fri", "End", false, now, now, "hash", now))
+
+	for name, rr := range map[string]*httptest.ResponseRecorder{"unknown": unknown, "inactive": inactive} {
+		if rr.Code != known.Code || rr.Body.String() != known.Body.String() {
+			t.Fatalf("%s address: want the same answer as for an account (%d %s), got %d %s",
+				name, known.Code, known.Body.String(), rr.Code, rr.Body.String())
+		}
+	}
+	if strings.Contains(known.Body.String(), "Fri") {
+		t.Fatalf("the answer should not reveal the invitee, got %s", known.Body.String())
+	}
+}
+
+func TestHandleInviteListMember_OwnerRoleRejected(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listSharedWith(listID, map[uuid.UUID]string{owner: "owner"}),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleInviteListMember(rr, inviteRequest(listID, `{"email":"friend@example.com","role":"owner"}`), owner)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+}
+
+func TestSharedList_ViewerCannotEditItems(t *testing.T) {
+	listID, owner, viewer := uuid.New(), uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listSharedWith(listID, map[uuid.UUID]string{owner: "owner", viewer: "viewer"}),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleCreateListItem(rr, itemRequest(listID, `"1"`), viewer)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("status: want 403, got %d", rr.Code)
+	}
+	if fdb.called("CreateListItem") {
+		t.Fatalf("viewers must not add items")
+	}
+}
+
+func TestHandleRemoveListMember_MemberLeaves(t *testing.T) {
+	listID, owner, editor := uuid.New(), uuid.New(), uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":    listSharedWith(listID, map[uuid.UUID]string{owner: "owner", editor: "editor"}),
+		"RemoveListMember": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/lists/"+listID.String()+"/members/"+editor.String(), nil)
+	req.SetPathValue("list_id", listID.String())
+	req.SetPathValue("member_id", editor.String())
+	rr := httptest.NewRecorder()
+	cfg.HandleRemoveListMember(rr, req, editor)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("status: want 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
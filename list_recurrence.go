@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/events"
+	"github.com/henrique-godinho/smart-list/internal/recurrence"
+)
+
+const recurrenceBatch = 100
+
+// runRecurrence rolls recurring lists forward once their target date has
+// passed, checking every interval until ctx is done.
+func (cfg *apiConfig) runRecurrence(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if rolled, err := cfg.rollDueLists(ctx, time.Now()); err != nil {
+			log.Printf("recurrence: %v", err)
+		} else if rolled > 0 {
+			log.Printf("recurrence: rolled %d lists forward", rolled)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) rollDueLists(ctx context.Context, now time.Time) (int, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	ids, err := cfg.Db.GetDueRecurringLists(ctx, database.GetDueRecurringListsParams{
+		Today:    today,
+		MaxLists: recurrenceBatch,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	rolled := 0
+	for _, id := range ids {
+		ok, err := cfg.rollList(ctx, id, today)
+		if err != nil {
+			log.Printf("recurrence: list %s: %v", id, err)
+			continue
+		}
+		if ok {
+			rolled++
+		}
+	}
+
+	return rolled, nil
+}
+
+// rollList moves a due list to its next occurrence. Lists that spawn copies
+// are left as they are, minus their frequency, and a fresh copy with every
+// item unchecked carries the recurrence on; other lists get their items
+// unchecked in place.
+func (cfg *apiConfig) rollList(ctx context.Context, listID uuid.UUID, today time.Time) (bool, error) {
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	// another instance may hold the lock or have rolled the list already
+	list, err := qtx.LockDueList(ctx, database.LockDueListParams{
+		ID:    listID,
+		Today: today,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	rule, err := recurrence.Parse(list.Frequency.String)
+	if err != nil {
+		// frequencies stored before they were validated; stop picking the
+		// list up on every run
+		log.Printf("recurrence: list %s has invalid frequency %q, no longer recurring", listID, list.Frequency.String)
+		version, err := qtx.EndListRecurrence(ctx, listID)
+		if err != nil {
+			return false, err
+		}
+		if err = tx.Commit(); err != nil {
+			return false, err
+		}
+		cfg.publishListEvent(ctx, listID, version, events.ListUpdated, nil)
+		return false, nil
+	}
+
+	// count from where the recurrence started, not from the last target
+	// date, which may have been clamped to the end of a short month
+	start := list.TargetDate.Time
+	if list.RecurrenceStart.Valid {
+		start = list.RecurrenceStart.Time
+	}
+	next := sql.NullTime{Time: rule.Next(start, today), Valid: true}
+
+	if !list.SpawnCopy {
+		if err = qtx.UncheckListItems(ctx, listID); err != nil {
+			return false, err
+		}
+		version, err := qtx.RollListForward(ctx, database.RollListForwardParams{
+			TargetDate: next,
+			ID:         listID,
+		})
+		if err != nil {
+			return false, err
+		}
+		if err = tx.Commit(); err != nil {
+			return false, err
+		}
+		cfg.publishListEvent(ctx, listID, version, events.ListUpdated, nil)
+		return true, nil
+	}
+
+	copied, err := qtx.CopyRecurringList(ctx, database.CopyRecurringListParams{
+		TargetDate: next,
+		ID:         listID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = qtx.CopyListItems(ctx, database.CopyListItemsParams{
+		NewListID: copied.ID,
+		ListID:    listID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = qtx.CopyListMembers(ctx, database.CopyListMembersParams{
+		NewListID: copied.ID,
+		ListID:    listID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	version, err := qtx.EndListRecurrence(ctx, listID)
+	if err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	cfg.publishListEvent(ctx, listID, version, events.ListUpdated, nil)
+
+	return true, nil
+}
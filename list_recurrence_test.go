@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var listCols = []string{"id", "user_id", "name", "frequency", "target_date", "created_at", "updated_at", "version", "spawn_copy", "recurrence_start"}
+
+func dueList(listID uuid.UUID, frequency string, target time.Time, spawnCopy bool) map[string]fakeHandler {
+	return map[string]fakeHandler{
+		"GetDueRecurringLists": func([]driver.NamedValue) fakeResult {
+			return fakeRow([]string{"id"}, listID.String())
+		},
+		"LockDueList": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(listCols, listID.String(), uuid.NewString(), "weekly shop", frequency, target, now, now, int64(4), spawnCopy, target)
+		},
+	}
+}
+
+func TestRollDueLists_RollsForwardInPlace(t *testing.T) {
+	listID := uuid.New()
+	handlers := dueList(listID, "weekly", time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), false)
+	var target driver.Value
+	handlers["RollListForward"] = func(args []driver.NamedValue) fakeResult {
+		target = args[0].Value
+		return fakeRow([]string{"version"}, int64(5))
+	}
+	handlers["UncheckListItems"] = func([]driver.NamedValue) fakeResult { return fakeResult{} }
+	cfg, fdb := newFakeConfig(t, handlers)
+
+	rolled, err := cfg.rollDueLists(context.Background(), time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC))
+	if err != nil || rolled != 1 {
+		t.Fatalf("want 1 list rolled, got %d (%v)", rolled, err)
+	}
+	if want := time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC); target != want {
+		t.Fatalf("target date: want %v, got %v", want, target)
+	}
+	if !fdb.called("UncheckListItems") {
+		t.Fatalf("items should be unchecked for the next round")
+	}
+	if fdb.called("CopyRecurringList") {
+		t.Fatalf("list without spawn_copy must not be copied")
+	}
+}
+
+func TestRollDueLists_SpawnsCopy(t *testing.T) {
+	listID, copyID := uuid.New(), uuid.New()
+	handlers := dueList(listID, "monthly", time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), true)
+	var target driver.Value
+	handlers["CopyRecurringList"] = func(args []driver.NamedValue) fakeResult {
+		target = args[0].Value
+		now := time.Now()
+		return fakeRow(listCols, copyID.String(), uuid.NewString(), "weekly shop", "monthly", args[0].Value, now, now, int64(1), true, args[0].Value)
+	}
+	var copiedTo driver.Value
+	handlers["CopyListItems"] = func(args []driver.NamedValue) fakeResult {
+		copiedTo = args[0].Value
+		return fakeResult{}
+	}
+	handlers["CopyListMembers"] = func([]driver.NamedValue) fakeResult { return fakeResult{} }
+	handlers["EndListRecurrence"] = func([]driver.NamedValue) fakeResult {
+		return fakeRow([]string{"version"}, int64(5))
+	}
+	cfg, fdb := newFakeConfig(t, handlers)
+
+	rolled, err := cfg.rollDueLists(context.Background(), time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil || rolled != 1 {
+		t.Fatalf("want 1 list rolled, got %d (%v)", rolled, err)
+	}
+	if want := time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC); target != want {
+		t.Fatalf("copy target date: want %v, got %v", want, target)
+	}
+	if copiedTo != copyID.String() {
+		t.Fatalf("items should be copied to the new list, got %v", copiedTo)
+	}
+	if !fdb.called("CopyListMembers") || !fdb.called("EndListRecurrence") {
+		t.Fatalf("members should be copied and the old list should stop recurring")
+	}
+	if fdb.called("RollListForward") || fdb.called("UncheckListItems") {
+		t.Fatalf("the old list must be kept as it was")
+	}
+}
+
+func TestRollDueLists_InvalidFrequency_StopsRecurring(t *testing.T) {
+	listID := uuid.New()
+	handlers := dueList(listID, "every now and then", time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), false)
+	handlers["EndListRecurrence"] = func([]driver.NamedValue) fakeResult {
+		return fakeRow([]string{"version"}, int64(5))
+	}
+	cfg, fdb := newFakeConfig(t, handlers)
+
+	rolled, err := cfg.rollDueLists(context.Background(), time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC))
+	if err != nil || rolled != 0 {
+		t.Fatalf("want no list rolled, got %d (%v)", rolled, err)
+	}
+	if !fdb.called("EndListRecurrence") || fdb.called("RollListForward") {
+		t.Fatalf("invalid frequency should be cleared instead of rolled")
+	}
+}
+
+func TestRollDueLists_KeepsDayAcrossShortMonths(t *testing.T) {
+	listID := uuid.New()
+	start := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	target := start
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetDueRecurringLists": func([]driver.NamedValue) fakeResult {
+			return fakeRow([]string{"id"}, listID.String())
+		},
+		"LockDueList": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(listCols, listID.String(), uuid.NewString(), "rent", "monthly", target, now, now, int64(4), false, start)
+		},
+		"UncheckListItems": func([]driver.NamedValue) fakeResult { return fakeResult{} },
+		"RollListForward": func(args []driver.NamedValue) fakeResult {
+			target = args[0].Value.(time.Time)
+			return fakeRow([]string{"version"}, int64(5))
+		},
+	})
+
+	want := []time.Time{
+		time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 5, 31, 0, 0, 0, 0, time.UTC),
+	}
+	for _, w := range want {
+		// the job runs the day after the list was due
+		if _, err := cfg.rollDueLists(context.Background(), target.AddDate(0, 0, 1)); err != nil {
+			t.Fatalf("roll: %v", err)
+		}
+		if !target.Equal(w) {
+			t.Fatalf("target date: want %s, got %s", w.Format(time.DateOnly), target.Format(time.DateOnly))
+		}
+	}
+}
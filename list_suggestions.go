@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/suggest"
+)
+
+const (
+	purchaseChecked = "checked"
+	purchaseRemoved = "removed"
+)
+
+const (
+	suggestionLookback = 365 * 24 * time.Hour
+	maxSuggestions     = 10
+)
+
+// recordPurchase logs an item that left a list as bought, either by being
+// checked off or by being removed while still unchecked.
+func recordPurchase(ctx context.Context, qtx *database.Queries, userID uuid.UUID, item database.ListItem, kind string) error {
+	params := database.RecordPurchaseParams{
+		UserID:   userID,
+		ListID:   uuid.NullUUID{UUID: item.ListID, Valid: true},
+		ItemName: item.Name,
+		Qty:      item.Qty,
+		Unit:     item.Unit,
+		Kind:     kind,
+	}
+	if kind == purchaseChecked {
+		params.ItemID.Int64, params.ItemID.Valid = item.ID, true
+		params.PurchasedAt = item.CheckedAt
+	}
+	return qtx.RecordPurchase(ctx, params)
+}
+
+// loadSuggestions works out which items the user usually buys again by now,
+// leaving out anything already waiting on one of their lists.
+func (cfg *apiConfig) loadSuggestions(ctx context.Context, userID uuid.UUID) ([]suggest.Suggestion, error) {
+	now := time.Now()
+
+	history, err := cfg.Db.GetPurchaseHistory(ctx, database.GetPurchaseHistoryParams{
+		UserID:      userID,
+		PurchasedAt: now.Add(-suggestionLookback),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := cfg.Db.GetOpenItemNames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool, len(open))
+	for _, name := range open {
+		skip[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	purchases := make([]suggest.Purchase, 0, len(history))
+	for _, row := range history {
+		purchases = append(purchases, suggest.Purchase{Name: row.ItemName, At: row.PurchasedAt})
+	}
+
+	return suggest.Due(purchases, now, skip, maxSuggestions), nil
+}
+
+// suggestionsOrEmpty is used where suggestions are a nice extra on another
+// response: failing to work them out must not fail the request.
+func (cfg *apiConfig) suggestionsOrEmpty(ctx context.Context, userID uuid.UUID) []suggest.Suggestion {
+	suggestions, err := cfg.loadSuggestions(ctx, userID)
+	if err != nil {
+		log.Printf("suggestions: failed to load for user %s: %v", userID, err)
+		return []suggest.Suggestion{}
+	}
+	return suggestions
+}
+
+func (cfg *apiConfig) HandleGetSuggestions(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	suggestions, err := cfg.loadSuggestions(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load suggestions", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, suggestions)
+}
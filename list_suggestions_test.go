@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/suggest"
+)
+
+func TestHandleDeleteListItem_RecordsPurchase(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	var kind driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess":   listOwnedBy(listID, owner),
+		"BumpListVersion": bumpFrom(1),
+		"DeleteListItem": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(listItemCols, int64(7), listID.String(), "milk", int64(1), nil, nil, now, now, false, nil, nil)
+		},
+		"RecordPurchase": func(args []driver.NamedValue) fakeResult {
+			kind = args[6].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/lists/"+listID.String()+"/items/7", nil)
+	req.SetPathValue("list_id", listID.String())
+	req.SetPathValue("item_id", "7")
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	cfg.HandleDeleteListItem(rr, req, owner)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("status: want 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if kind != purchaseRemoved {
+		t.Fatalf("removing an unchecked item should record a purchase, got kind %v", kind)
+	}
+}
+
+func TestHandleGetSuggestions(t *testing.T) {
+	user := uuid.New()
+	var scopedTo driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetPurchaseHistory": func(args []driver.NamedValue) fakeResult {
+			scopedTo = args[0].Value
+			now := time.Now()
+			var rows [][]driver.Value
+			for _, name := range []string{"Milk", "Eggs"} {
+				for _, days := range []int{22, 15, 8} {
+					rows = append(rows, []driver.Value{name, now.AddDate(0, 0, -days)})
+				}
+			}
+			return fakeResult{cols: []string{"item_name", "purchased_at"}, rows: rows}
+		},
+		"GetOpenItemNames": func([]driver.NamedValue) fakeResult {
+			return fakeRow([]string{"name"}, "eggs")
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/api/suggestions", nil)
+	rr := httptest.NewRecorder()
+	cfg.HandleGetSuggestions(rr, req, user)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if scopedTo != user.String() {
+		t.Fatalf("history must be scoped to the caller: got %v", scopedTo)
+	}
+	var got []suggest.Suggestion
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "Milk" || got[0].IntervalDays != 7 {
+		t.Fatalf("want only Milk, eggs are already on a list: %+v", got)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+const maxTemplateItems = 200
+
+var errTemplateNotFound = errors.New("template not found")
+
+type templateItemResponse struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Qty  int    `json:"qty"`
+	Unit string `json:"unit"`
+}
+
+type templateResponse struct {
+	ID        uuid.UUID              `json:"id"`
+	Name      string                 `json:"name"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	Items     []templateItemResponse `json:"items"`
+}
+
+// templateItem is both what clients send for a template's items and what is
+// handed to AddTemplateItems as jsonb.
+type templateItem struct {
+	Name string  `json:"name"`
+	Qty  *int    `json:"qty"`
+	Unit *string `json:"unit"`
+}
+
+func validateTemplateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("template name is required")
+	}
+	if utf8.RuneCountInString(name) > maxListNameLen {
+		return "", errors.New("template name must be 100 characters maximum")
+	}
+	return name, nil
+}
+
+func templateIDFromPath(req *http.Request) (uuid.UUID, error) {
+	templateID, err := uuid.Parse(req.PathValue("template_id"))
+	if err != nil {
+		return uuid.Nil, errTemplateNotFound
+	}
+	return templateID, nil
+}
+
+// validateTemplateItems cleans up the items of a template and encodes them
+// for AddTemplateItems.
+func validateTemplateItems(items []templateItem) (json.RawMessage, error) {
+	if len(items) > maxTemplateItems {
+		return nil, fmt.Errorf("a template can have %d items maximum", maxTemplateItems)
+	}
+
+	seen := make(map[string]bool, len(items))
+	cleaned := make([]templateItem, 0, len(items))
+
+	for _, item := range items {
+		name, err := validateItemName(item.Name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("item %q is listed twice", name)
+		}
+		seen[strings.ToLower(name)] = true
+
+		qty := 1
+		if item.Qty != nil {
+			qty = *item.Qty
+		}
+		if _, err := validateQty(qty); err != nil {
+			return nil, err
+		}
+
+		var unit *string
+		if item.Unit != nil && strings.TrimSpace(*item.Unit) != "" {
+			u := strings.TrimSpace(*item.Unit)
+			unit = &u
+		}
+
+		cleaned = append(cleaned, templateItem{Name: name, Qty: &qty, Unit: unit})
+	}
+
+	return json.Marshal(cleaned)
+}
+
+func newTemplateResponse(template database.ListTemplate, items []database.ListTemplateItem) templateResponse {
+	resp := templateResponse{
+		ID:        template.ID,
+		Name:      template.Name,
+		CreatedAt: template.CreatedAt,
+		UpdatedAt: template.UpdatedAt,
+		Items:     make([]templateItemResponse, 0, len(items)),
+	}
+	for _, item := range items {
+		resp.Items = append(resp.Items, templateItemResponse{
+			ID:   item.ID,
+			Name: item.Name,
+			Qty:  int(item.Qty.Int16),
+			Unit: item.Unit.String,
+		})
+	}
+	return resp
+}
+
+// groupTemplateRows folds the rows of GetTemplatesByUserId into one
+// templateResponse per template, like groupListRows does for lists.
+func groupTemplateRows(rows []database.GetTemplatesByUserIdRow) []templateResponse {
+	templates := make([]templateResponse, 0)
+	index := make(map[uuid.UUID]int)
+
+	for _, row := range rows {
+		i, ok := index[row.TemplateID]
+		if !ok {
+			templates = append(templates, templateResponse{
+				ID:        row.TemplateID,
+				Name:      row.TemplateName,
+				CreatedAt: row.TemplateCreatedAt,
+				UpdatedAt: row.TemplateUpdatedAt,
+				Items:     make([]templateItemResponse, 0),
+			})
+			i = len(templates) - 1
+			index[row.TemplateID] = i
+		}
+
+		if !row.ItemID.Valid {
+			continue
+		}
+		templates[i].Items = append(templates[i].Items, templateItemResponse{
+			ID:   row.ItemID.Int64,
+			Name: row.ItemName.String,
+			Qty:  int(row.Qty.Int16),
+			Unit: row.Unit.String,
+		})
+	}
+
+	return templates
+}
+
+func (cfg *apiConfig) HandleGetTemplates(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	rows, err := cfg.Db.GetTemplatesByUserId(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load templates", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, groupTemplateRows(rows))
+}
+
+func (cfg *apiConfig) HandleGetTemplate(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	templateID, err := templateIDFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	template, err := cfg.Db.GetTemplate(req.Context(), database.GetTemplateParams{
+		ID:     templateID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, errTemplateNotFound.Error(), nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to load template", err)
+		return
+	}
+
+	items, err := cfg.Db.GetTemplateItems(req.Context(), templateID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load template", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newTemplateResponse(template, items))
+}
+
+// HandleCreateTemplate saves a template either from the items of an existing
+// list the caller can see ("list_id") or from the given "items".
+func (cfg *apiConfig) HandleCreateTemplate(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+
+	type NewTemplate struct {
+		Name   string         `json:"name"`
+		ListID *uuid.UUID     `json:"list_id"`
+		Items  []templateItem `json:"items"`
+	}
+
+	var newTemplate NewTemplate
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&newTemplate); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode template payload", err)
+		return
+	}
+
+	if newTemplate.ListID != nil && newTemplate.Items != nil {
+		respondWithError(w, http.StatusBadRequest, "send either list_id or items, not both", nil)
+		return
+	}
+
+	var items json.RawMessage
+	if newTemplate.ListID != nil {
+		list, err := cfg.loadListForUser(req.Context(), *newTemplate.ListID, userID, permViewList)
+		if err != nil {
+			respondWithListError(w, err)
+			return
+		}
+		if strings.TrimSpace(newTemplate.Name) == "" {
+			newTemplate.Name = list.Name
+		}
+	} else {
+		var err error
+		items, err = validateTemplateItems(newTemplate.Items)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+
+	name, err := validateTemplateName(newTemplate.Name)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	template, err := qtx.CreateTemplate(req.Context(), database.CreateTemplateParams{
+		UserID: userID,
+		Name:   name,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "a template with this name already exists", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to create template", err)
+		return
+	}
+
+	if newTemplate.ListID != nil {
+		err = qtx.CopyListItemsToTemplate(req.Context(), database.CopyListItemsToTemplateParams{
+			TemplateID: template.ID,
+			ListID:     *newTemplate.ListID,
+		})
+	} else {
+		err = qtx.AddTemplateItems(req.Context(), database.AddTemplateItemsParams{
+			TemplateID: template.ID,
+			Items:      items,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create template", err)
+		return
+	}
+
+	templateItems, err := qtx.GetTemplateItems(req.Context(), template.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create template", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create template", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newTemplateResponse(template, templateItems))
+}
+
+// HandleUpdateTemplate renames a template and/or replaces all of its items.
+func (cfg *apiConfig) HandleUpdateTemplate(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+
+	type TemplatePatch struct {
+		Name  optionalField[string] `json:"name"`
+		Items *[]templateItem       `json:"items"`
+	}
+
+	templateID, err := templateIDFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	var patch TemplatePatch
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode template payload", err)
+		return
+	}
+
+	params := database.RenameTemplateParams{
+		ID:     templateID,
+		UserID: userID,
+	}
+
+	if patch.Name.Set {
+		name, err := validateTemplateName(patch.Name.Value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		params.Name = sql.NullString{String: name, Valid: true}
+	}
+
+	var items json.RawMessage
+	if patch.Items != nil {
+		items, err = validateTemplateItems(*patch.Items)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	template, err := qtx.RenameTemplate(req.Context(), params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, errTemplateNotFound.Error(), nil)
+			return
+		}
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "a template with this name already exists", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to update template", err)
+		return
+	}
+
+	if patch.Items != nil {
+		if err = qtx.DeleteTemplateItems(req.Context(), templateID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to update template", err)
+			return
+		}
+		err = qtx.AddTemplateItems(req.Context(), database.AddTemplateItemsParams{
+			TemplateID: templateID,
+			Items:      items,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to update template", err)
+			return
+		}
+	}
+
+	templateItems, err := qtx.GetTemplateItems(req.Context(), templateID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update template", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update template", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newTemplateResponse(template, templateItems))
+}
+
+func (cfg *apiConfig) HandleDeleteTemplate(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	templateID, err := templateIDFromPath(req)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+
+	deleted, err := cfg.Db.DeleteTemplate(req.Context(), database.DeleteTemplateParams{
+		ID:     templateID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete template", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, errTemplateNotFound.Error(), nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
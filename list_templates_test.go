@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var templateCols = []string{"id", "user_id", "name", "created_at", "updated_at"}
+
+func TestValidateTemplateItems(t *testing.T) {
+	unit := " kg "
+	raw, err := validateTemplateItems([]templateItem{{Name: " flour ", Unit: &unit}, {Name: "eggs"}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	var items []templateItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if items[0].Name != "flour" || *items[0].Qty != 1 || *items[0].Unit != "kg" || items[1].Unit != nil {
+		t.Fatalf("unexpected items: %s", raw)
+	}
+
+	if _, err := validateTemplateItems([]templateItem{{Name: "Milk"}, {Name: "milk"}}); err == nil {
+		t.Fatalf("duplicate items should be rejected")
+	}
+	zero := 0
+	if _, err := validateTemplateItems([]templateItem{{Name: "milk", Qty: &zero}}); err == nil {
+		t.Fatalf("zero quantity should be rejected")
+	}
+}
+
+func TestHandleCreateTemplate_OtherUsersList_NotFound(t *testing.T) {
+	listID, owner, intruder := uuid.New(), uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+	})
+
+	body := `{"name":"copy","list_id":"` + listID.String() + `"}`
+	req := httptest.NewRequest("POST", "/api/templates", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	cfg.HandleCreateTemplate(rr, req, intruder)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", rr.Code)
+	}
+	if fdb.called("CreateTemplate") {
+		t.Fatalf("template must not be created from another user's list")
+	}
+}
+
+func newListFromTemplateHandlers(templateID, owner uuid.UUID, createdName *driver.Value) map[string]fakeHandler {
+	return map[string]fakeHandler{
+		"GetTemplate": func(args []driver.NamedValue) fakeResult {
+			if args[0].Value != templateID.String() || args[1].Value != owner.String() {
+				return fakeResult{cols: templateCols}
+			}
+			now := time.Now()
+			return fakeRow(templateCols, templateID.String(), owner.String(), "weekly basics", now, now)
+		},
+		"CreateNewList": func(args []driver.NamedValue) fakeResult {
+			*createdName = args[1].Value
+			return fakeRow([]string{"id", "name", "frequency", "target_date", "version", "spawn_copy"},
+				uuid.NewString(), args[1].Value, nil, nil, int64(1), false)
+		},
+		"AddListMember": func(args []driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow([]string{"list_id", "user_id", "role", "status", "invited_by", "created_at", "updated_at"},
+				args[0].Value, args[1].Value, args[2].Value, args[3].Value, nil, now, now)
+		},
+		"CreateListItemsFromTemplate": func([]driver.NamedValue) fakeResult { return fakeResult{} },
+		"GetListItems": func(args []driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(listItemCols, int64(1), args[0].Value, "milk", int64(2), "l", nil, now, now, false, nil, nil)
+		},
+	}
+}
+
+func TestCreateNewList_FromTemplate(t *testing.T) {
+	templateID, owner := uuid.New(), uuid.New()
+	var createdName driver.Value
+	cfg, fdb := newFakeConfig(t, newListFromTemplateHandlers(templateID, owner, &createdName))
+
+	body := `{"template_id":"` + templateID.String() + `"}`
+	req := httptest.NewRequest("POST", "/api/lists/", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	cfg.CreateNewList(rr, req, owner)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if createdName != "weekly basics" {
+		t.Fatalf("list should be named after the template, got %v", createdName)
+	}
+	if !fdb.called("CreateListItemsFromTemplate") {
+		t.Fatalf("items should be copied from the template")
+	}
+	if !strings.Contains(rr.Body.String(), `"name":"milk"`) {
+		t.Fatalf("response should include the new items: %s", rr.Body.String())
+	}
+}
+
+func TestCreateNewList_OtherUsersTemplate_NotFound(t *testing.T) {
+	templateID, owner := uuid.New(), uuid.New()
+	var createdName driver.Value
+	cfg, fdb := newFakeConfig(t, newListFromTemplateHandlers(templateID, owner, &createdName))
+
+	body := `{"name":"mine","template_id":"` + templateID.String() + `"}`
+	req := httptest.NewRequest("POST", "/api/lists/", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	cfg.CreateNewList(rr, req, uuid.New())
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status: want 404, got %d", rr.Code)
+	}
+	if fdb.called("CreateNewList") {
+		t.Fatalf("list must not be created from another user's template")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+var (
+	errPreconditionRequired = errors.New("If-Match header with the list ETag is required")
+	errPreconditionFailed   = errors.New("list was modified by someone else")
+)
+
+func listETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// matchesIfMatch reports whether an If-Match header accepts the given list
+// version. Weak validators are compared by value and "*" matches any version.
+func matchesIfMatch(header string, version int64) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		tag = strings.TrimPrefix(tag, "W/")
+		if tag == listETag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch validates the request's If-Match header against the version
+// the caller last loaded and returns the version the write must apply to.
+func checkIfMatch(req *http.Request, current int64) (int64, error) {
+	header := req.Header.Get("If-Match")
+	if strings.TrimSpace(header) == "" {
+		return 0, errPreconditionRequired
+	}
+	if !matchesIfMatch(header, current) {
+		return 0, errPreconditionFailed
+	}
+	return current, nil
+}
+
+// respondWithStaleList answers a write with a stale or missing If-Match. For
+// 412 the body carries the list as currently stored so the client can merge.
+func (cfg *apiConfig) respondWithStaleList(w http.ResponseWriter, req *http.Request, listID, userID uuid.UUID, err error) {
+	if errors.Is(err, errPreconditionRequired) {
+		respondWithError(w, http.StatusPreconditionRequired, errPreconditionRequired.Error(), nil)
+		return
+	}
+
+	type staleResponse struct {
+		Error   string       `json:"error"`
+		Current listResponse `json:"current"`
+	}
+
+	list, err := cfg.loadListForUser(req.Context(), listID, userID, permViewList)
+	if err != nil {
+		respondWithListError(w, err)
+		return
+	}
+
+	items, err := cfg.Db.GetListItems(req.Context(), listID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load list items", err)
+		return
+	}
+
+	current := newListResponse(listFromAccessRow(list), items)
+	current.Role = list.Role
+
+	w.Header().Set("ETag", listETag(list.Version))
+	respondWithJSON(w, http.StatusPreconditionFailed, staleResponse{
+		Error:   errPreconditionFailed.Error(),
+		Current: current,
+	})
+}
+
+// bumpListVersion increments the list version inside a write transaction.
+// It fails with errPreconditionFailed when another write got there first.
+func bumpListVersion(ctx context.Context, qtx *database.Queries, listID uuid.UUID, version int64) (int64, error) {
+	newVersion, err := qtx.BumpListVersion(ctx, database.BumpListVersionParams{
+		ID:      listID,
+		Version: version,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errPreconditionFailed
+		}
+		return 0, err
+	}
+	return newVersion, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// bumpFrom simulates BumpListVersion for a list currently at version current.
+func bumpFrom(current int64) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		if args[1].Value != current {
+			return fakeResult{cols: []string{"version"}}
+		}
+		return fakeRow([]string{"version"}, current+1)
+	}
+}
+
+func TestMatchesIfMatch(t *testing.T) {
+	cases := []struct {
+		header  string
+		version int64
+		want    bool
+	}{
+		{`"3"`, 3, true},
+		{`W/"3"`, 3, true},
+		{`"1", "3"`, 3, true},
+		{`*`, 9, true},
+		{`"2"`, 3, false},
+		{`3`, 3, false},
+	}
+	for _, c := range cases {
+		if got := matchesIfMatch(c.header, c.version); got != c.want {
+			t.Errorf("matchesIfMatch(%q, %d): want %v, got %v", c.header, c.version, c.want, got)
+		}
+	}
+}
+
+func itemRequest(listID uuid.UUID, ifMatch string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/lists/"+listID.String()+"/items", strings.NewReader(`{"name":"milk"}`))
+	req.SetPathValue("list_id", listID.String())
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	return req
+}
+
+func TestListWrite_MissingIfMatch_PreconditionRequired(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleCreateListItem(rr, itemRequest(listID, ""), owner)
+
+	if rr.Code != http.StatusPreconditionRequired {
+		t.Fatalf("status: want 428, got %d", rr.Code)
+	}
+	if fdb.called("CreateListItem") {
+		t.Fatalf("write without If-Match must not reach the database")
+	}
+}
+
+func TestListWrite_StaleIfMatch_ReturnsCurrentState(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+		"GetListItems": func([]driver.NamedValue) fakeResult {
+			return fakeResult{cols: listItemCols}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleCreateListItem(rr, itemRequest(listID, `"0"`), owner)
+
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status: want 412, got %d", rr.Code)
+	}
+	if rr.Header().Get("ETag") != `"1"` {
+		t.Fatalf("ETag: want current version, got %q", rr.Header().Get("ETag"))
+	}
+	var body struct {
+		Current listResponse `json:"current"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Current.ID != listID || body.Current.Version != 1 {
+		t.Fatalf("412 body should carry the current list: %+v", body.Current)
+	}
+	if fdb.called("CreateListItem") {
+		t.Fatalf("stale write must not reach the database")
+	}
+}
+
+func TestListWrite_LostRace_PreconditionFailed(t *testing.T) {
+	listID, owner := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetListAccess": listOwnedBy(listID, owner),
+		// another writer bumped the version between the check and the write
+		"BumpListVersion": bumpFrom(2),
+		"GetListItems": func([]driver.NamedValue) fakeResult {
+			return fakeResult{cols: listItemCols}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleCreateListItem(rr, itemRequest(listID, `"1"`), owner)
+
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status: want 412, got %d", rr.Code)
+	}
+	if fdb.called("CreateListItem") {
+		t.Fatalf("item must not be written after losing the version race")
+	}
+}
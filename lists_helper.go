@@ -58,10 +58,13 @@ type UserList struct {
 	Price         int
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
+	Checked       bool
 	ListName      string
 	ListFreq      string
 	TargetDate    time.Time
 	ListUpdatedAt time.Time
+	ListVersion   int64
+	ListRole      string
 }
 
 func (cfg *apiConfig) LoadUserLists(req *http.Request, userID uuid.UUID) ([]UserList, error) {
@@ -81,10 +84,13 @@ func (cfg *apiConfig) LoadUserLists(req *http.Request, userID uuid.UUID) ([]User
 			Price:         int(rows.Price.Int16),
 			CreatedAt:     rows.ItemCreatedAt.Time,
 			UpdatedAt:     rows.ItemUpdatedAt.Time,
+			Checked:       rows.Checked.Bool,
 			ListName:      rows.ListName,
 			ListFreq:      rows.Frequency.String,
 			TargetDate:    rows.TargetDate.Time,
 			ListUpdatedAt: rows.ListUpdatedAt.Time,
+			ListVersion:   rows.ListVersion,
+			ListRole:      rows.ListRole,
 		})
 	}
 
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
+	"log"
 	"net/http"
-	"time"
 
+	"github.com/google/uuid"
 	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
 )
 
 func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, req *http.Request) {
@@ -53,9 +55,17 @@ func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	attempts := cfg.loginAttempts(req, email)
+	if wait := attemptWait(req.Context(), attempts); wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
 	user, err := cfg.Db.GetUserByEmail(req.Context(), email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			cfg.failAttempt(req, uuid.Nil, attempts)
+			cfg.audit(req.Context(), req, uuid.Nil, "login.password", cfg.emailTarget(email), "unknown_user")
 			respondWithError(w, http.StatusUnauthorized, "invalid credentials", nil)
 			return
 		}
@@ -64,26 +74,76 @@ func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if !user.IsActive {
+		cfg.audit(req.Context(), req, user.ID, "login.password", user.ID.String(), "inactive")
 		respondWithError(w, http.StatusBadRequest, "inactive user", nil)
 		return
 	}
 
 	err = auth.CheckPasswordHash(user.HashedPassword, pwd)
 	if err != nil {
+		cfg.failAttempt(req, user.ID, attempts)
+		cfg.audit(req.Context(), req, user.ID, "login.password", user.ID.String(), "failure")
 		respondWithError(w, http.StatusBadRequest, "invalid email or password", nil)
 		return
 	}
 
-	jwt, err := auth.MakeJWT(user.ID, cfg.JWTKey, (2 * time.Hour))
+	// the address keeps its count, or one account of its own would buy it
+	// fresh guesses at everyone else's
+	resetAttempts(req.Context(), attempts, "login-email")
+
+	cfg.rehashPassword(req.Context(), user.ID, user.HashedPassword, pwd)
+
+	if cfg.EmailVerification == verifyLogin && !user.EmailVerifiedAt.Valid {
+		cfg.audit(req.Context(), req, user.ID, "login.password", user.ID.String(), "unverified")
+		respondWithError(w, http.StatusForbidden, errEmailUnverified.Error(), nil)
+		return
+	}
+
+	mfa, err := cfg.mfaEnabled(req.Context(), user.ID)
 	if err != nil {
-		fmt.Println(err)
-		respondWithError(w, http.StatusInternalServerError, "failed to login", nil)
+		respondWithError(w, http.StatusInternalServerError, "failed to login", err)
+		return
+	}
+	if mfa {
+		if err = cfg.startMFALogin(w, user.ID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to login", err)
+			return
+		}
+		cfg.audit(req.Context(), req, user.ID, "login.password", user.ID.String(), "mfa_required")
+		http.Redirect(w, req, "/mfa.html", http.StatusSeeOther)
 		return
 	}
 
-	jwtCookie := auth.MakeAuthCookie(jwt, (2 * time.Hour), cfg.CookieSecure)
+	if err = cfg.startSession(w, req, user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to login", err)
+		return
+	}
+	cfg.audit(req.Context(), req, user.ID, "login.password", user.ID.String(), "success")
 
-	http.SetCookie(w, jwtCookie)
 	http.Redirect(w, req, "/main", http.StatusSeeOther)
 
 }
+
+// rehashPassword moves a password hashed with an older algorithm or weaker
+// settings to the current ones, now that the password is at hand. It only
+// replaces the hash it was given, so a password changed in the meantime
+// stays changed. Failing is logged, the old hash keeps working.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, oldHash, pwd string) {
+	if !auth.NeedsRehash(oldHash) {
+		return
+	}
+
+	newHash, err := auth.HashPassword(pwd)
+	if err != nil {
+		log.Printf("passwords: failed to rehash for user %s: %v", userID, err)
+		return
+	}
+
+	if _, err := cfg.Db.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+		NewHash: newHash,
+		ID:      userID,
+		OldHash: oldHash,
+	}); err != nil {
+		log.Printf("passwords: failed to rehash for user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHandleLogin_RehashesBcrypt(t *testing.T) {
+	userID := uuid.New()
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+
+	var newHash, oldHash driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByEmail": unverifiedUser(userID, "jane@example.com", string(legacy)),
+		"RehashUserPassword": func(args []driver.NamedValue) fakeResult {
+			newHash, oldHash = args[0].Value, args[2].Value
+			return fakeResult{affected: 1}
+		},
+		"GetTOTP":       func([]driver.NamedValue) fakeResult { return fakeResult{cols: totpCols} },
+		"CreateSession": createdSession,
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleLogin(rr, formRequest("/login", url.Values{"email": {"jane@example.com"}, "password": {"correct horse"}}))
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if oldHash != string(legacy) {
+		t.Fatalf("only the hash that was checked may be replaced, got %v", oldHash)
+	}
+	hash, _ := newHash.(string)
+	if !strings.HasPrefix(hash, "$argon2id$") || auth.CheckPasswordHash(hash, "correct horse") != nil {
+		t.Fatalf("want an argon2id hash of the password, got %q", hash)
+	}
+}
+
+func TestHandleLogin_CurrentHashKept(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByEmail": unverifiedUser(uuid.New(), "jane@example.com", hash),
+		"GetTOTP":        func([]driver.NamedValue) fakeResult { return fakeResult{cols: totpCols} },
+		"CreateSession":  createdSession,
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleLogin(rr, formRequest("/login", url.Values{"email": {"jane@example.com"}, "password": {"correct horse"}}))
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if fdb.called("RehashUserPassword") {
+		t.Fatalf("a current hash must not be rewritten")
+	}
+}
+
+func TestHandleLogin_AuditsOutcome(t *testing.T) {
+	userID := uuid.New()
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	cases := map[string]struct {
+		password string
+		outcome  string
+	}{
+		"wrong password": {"guess", "failure"},
+		"success":        {"correct horse", "success"},
+	}
+
+	for name, c := range cases {
+		var actor, action, target, outcome driver.Value
+		cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+			"GetUserByEmail": unverifiedUser(userID, "jane@example.com", hash),
+			"GetTOTP":        func([]driver.NamedValue) fakeResult { return fakeResult{cols: totpCols} },
+			"CreateSession":  createdSession,
+			"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+				actor, action, target, outcome = args[0].Value, args[1].Value, args[2].Value, args[5].Value
+				return fakeResult{affected: 1}
+			},
+		})
+
+		rr := httptest.NewRecorder()
+		cfg.HandleLogin(rr, formRequest("/login", url.Values{"email": {"jane@example.com"}, "password": {c.password}}))
+
+		if action != "login.password" || outcome != c.outcome {
+			t.Fatalf("%s: want login.password %s audited, got %v %v", name, c.outcome, action, outcome)
+		}
+		if actor != userID.String() || target != userID.String() {
+			t.Fatalf("%s: the event should name the account, not the address, got %v %v", name, actor, target)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/throttle"
+)
+
+var (
+	// an account is locked after a handful of wrong passwords, whoever
+	// tries them
+	loginEmailPolicy = throttle.Policy{
+		Free:         3,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockoutAfter: 10,
+		Lockout:      15 * time.Minute,
+		Window:       24 * time.Hour,
+	}
+
+	// one address may try many accounts, but not endlessly; shared
+	// addresses (offices, mobile carriers) get more room
+	loginIPPolicy = throttle.Policy{
+		Free:         20,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockoutAfter: 100,
+		Lockout:      time.Hour,
+		Window:       24 * time.Hour,
+	}
+
+	// every signup counts, successful or not
+	signupIPPolicy = throttle.Policy{
+		Free:         5,
+		BaseDelay:    10 * time.Second,
+		MaxDelay:     10 * time.Minute,
+		LockoutAfter: 30,
+		Lockout:      time.Hour,
+		Window:       time.Hour,
+	}
+)
+
+const throttleSweepInterval = time.Hour
+
+// attempt is one key an attempt is counted under.
+type attempt struct {
+	limiter *throttle.Limiter
+	kind    string
+	key     string
+}
+
+func (cfg *apiConfig) attempt(kind, key string, policy throttle.Policy) attempt {
+	return attempt{
+		limiter: throttle.New(cfg.Throttle, kind, policy),
+		kind:    kind,
+		key:     key,
+	}
+}
+
+func (cfg *apiConfig) loginAttempts(req *http.Request, email string) []attempt {
+	if cfg.Throttle == nil {
+		return nil
+	}
+	return []attempt{
+		cfg.attempt("login-email", strings.ToLower(email), loginEmailPolicy),
+		cfg.attempt("login-ip", clientIP(req), loginIPPolicy),
+	}
+}
+
+// mfaAttempts counts wrong second factors per account, like wrong passwords.
+func (cfg *apiConfig) mfaAttempts(userID uuid.UUID) []attempt {
+	if cfg.Throttle == nil {
+		return nil
+	}
+	return []attempt{cfg.attempt("login-mfa", userID.String(), loginEmailPolicy)}
+}
+
+// passwordAttempts counts wrong current passwords given to change the
+// password or email address of a signed-in account.
+func (cfg *apiConfig) passwordAttempts(userID uuid.UUID) []attempt {
+	if cfg.Throttle == nil {
+		return nil
+	}
+	return []attempt{cfg.attempt("password-check", userID.String(), loginEmailPolicy)}
+}
+
+func (cfg *apiConfig) signupAttempts(req *http.Request) []attempt {
+	if cfg.Throttle == nil {
+		return nil
+	}
+	return []attempt{cfg.attempt("signup-ip", clientIP(req), signupIPPolicy)}
+}
+
+// attemptWait is how long until an attempt counted under all of attempts may
+// go ahead. A failing store lets it through rather than lock everyone out.
+func attemptWait(ctx context.Context, attempts []attempt) time.Duration {
+	var wait time.Duration
+	now := time.Now()
+	for _, a := range attempts {
+		w, err := a.limiter.Wait(ctx, a.key, now)
+		if err != nil {
+			log.Printf("throttle: %s: %v", a.kind, err)
+			continue
+		}
+		wait = max(wait, w)
+	}
+	return wait
+}
+
+// failAttempt records a failed attempt under each of attempts, and audits
+// every lockout it starts. actorID is the account concerned, if known.
+func (cfg *apiConfig) failAttempt(req *http.Request, actorID uuid.UUID, attempts []attempt) {
+	now := time.Now()
+	for _, a := range attempts {
+		_, locked, err := a.limiter.Fail(req.Context(), a.key, now)
+		if err != nil {
+			log.Printf("throttle: %s: %v", a.kind, err)
+			continue
+		}
+		if locked {
+			target := a.kind + ":" + a.key
+			if a.kind == "login-email" {
+				target = a.kind + ":" + cfg.emailTarget(a.key)
+			}
+			cfg.audit(req.Context(), req, actorID, "throttle.lockout", target, "locked")
+		}
+	}
+}
+
+// resetAttempts forgets the failures counted under kind.
+func resetAttempts(ctx context.Context, attempts []attempt, kind string) {
+	for _, a := range attempts {
+		if a.kind != kind {
+			continue
+		}
+		if err := a.limiter.Reset(ctx, a.key); err != nil {
+			log.Printf("throttle: %s: %v", a.kind, err)
+		}
+	}
+}
+
+func respondTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	seconds := ceilSeconds(wait)
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+	respondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("too many attempts, try again in %d seconds", seconds), nil)
+}
+
+// runThrottleSweep drops expired attempt state every interval until ctx is
+// done.
+func (cfg *apiConfig) runThrottleSweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := cfg.Throttle.Sweep(ctx, time.Now()); err != nil {
+			log.Printf("throttle: sweep: %v", err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/throttle"
+)
+
+func TestHandleLogin_LocksOutAfterRepeatedFailures(t *testing.T) {
+	var actions []driver.Value
+	lookups := 0
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByEmail": func([]driver.NamedValue) fakeResult {
+			lookups++
+			return fakeResult{cols: userByEmailCols}
+		},
+		"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+			actions = append(actions, args[1].Value)
+			return fakeResult{affected: 1}
+		},
+	})
+	cfg.Throttle = throttle.NewMemory()
+
+	login := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		cfg.HandleLogin(rr, formRequest("/login", url.Values{"email": {"jane@example.com"}, "password": {"guess"}}))
+		return rr
+	}
+
+	for i := 1; i <= loginEmailPolicy.Free; i++ {
+		if rr := login(); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: want 401, got %d", i, rr.Code)
+		}
+	}
+
+	// the next failure earns a delay, the attempt after it has to wait
+	if rr := login(); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("want 401, got %d", rr.Code)
+	}
+	before := lookups
+	rr := login()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("want 429, got %d", rr.Code)
+	}
+	if s, err := strconv.Atoi(rr.Header().Get("Retry-After")); err != nil || s < 1 {
+		t.Fatalf("Retry-After should be a number of seconds, got %q", rr.Header().Get("Retry-After"))
+	}
+	for _, a := range actions {
+		if a == "throttle.lockout" {
+			t.Fatalf("backoff alone is no lockout: %v", actions)
+		}
+	}
+	if len(actions) != loginEmailPolicy.Free+1 {
+		t.Fatalf("each failed sign-in should be audited, got %v", actions)
+	}
+	if lookups != before {
+		t.Fatalf("a throttled attempt must not reach the password check")
+	}
+}
+
+func TestFailAttempt_AuditsLockout(t *testing.T) {
+	var target driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+			target = args[2].Value
+			return fakeResult{affected: 1}
+		},
+	})
+	cfg.Throttle = throttle.NewMemory()
+
+	req := formRequest("/login", nil)
+	attempts := cfg.loginAttempts(req, "Jane@Example.com")
+	for range loginEmailPolicy.LockoutAfter {
+		cfg.failAttempt(req, uuid.Nil, attempts)
+	}
+
+	// the address itself stays out of the audit log
+	if want := "login-email:" + cfg.emailTarget("jane@example.com"); target != want {
+		t.Fatalf("the lockout should be audited as %s, got %v", want, target)
+	}
+}
@@ -1,13 +1,25 @@
 package main
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/google/uuid"
-	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
 )
 
 func (cfg *apiConfig) HandleLogout(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
-	http.SetCookie(w, auth.ClearAuthCookie(cfg.CookieSecure))
+	if sessionID := sessionIDFromContext(req.Context()); sessionID != uuid.Nil {
+		_, err := cfg.Db.RevokeSession(req.Context(), database.RevokeSessionParams{
+			ID:     sessionID,
+			UserID: userID,
+		})
+		if err != nil {
+			log.Printf("sessions: failed to revoke session %s: %v", sessionID, err)
+		}
+		cfg.audit(req.Context(), req, userID, "logout", sessionID.String(), "success")
+	}
+
+	cfg.clearSessionCookies(w)
 	http.Redirect(w, req, "/?session=logout", http.StatusSeeOther)
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+)
+
+const mailTimeout = 30 * time.Second
+
+// sendMail sends msg in the background, so the response doesn't wait on the
+// mail server and its timing doesn't give away whether an address is known.
+func (cfg *apiConfig) sendMail(msg mailer.Message) {
+	if cfg.Mailer == nil {
+		log.Printf("mailer: no mailer configured, dropping %q to %s", msg.Subject, msg.To)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := cfg.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("mailer: failed to send %q: %v", msg.Subject, err)
+		}
+	}()
+}
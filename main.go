@@ -4,16 +4,23 @@ package main
 // server shutdonw
 // db close
 // write tests
-// rate limit.
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
+	"github.com/henrique-godinho/smart-list/internal/auth"
 	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/events"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+	"github.com/henrique-godinho/smart-list/internal/oidc"
+	"github.com/henrique-godinho/smart-list/internal/ratelimit"
+	"github.com/henrique-godinho/smart-list/internal/throttle"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -21,9 +28,17 @@ import (
 type apiConfig struct {
 	Sql          *sql.DB
 	Db           *database.Queries
-	JWTKey       string
+	Keys         *auth.Keyring
 	CookieSecure bool
 	Origin       string
+	Events       *events.Broker
+	Mailer       mailer.Mailer
+	Throttle     throttle.Store
+	OIDC         []*oidc.Provider
+
+	// EmailVerification is what unverified accounts are kept from: nothing,
+	// list sharing or signing in
+	EmailVerification string
 }
 
 func main() {
@@ -48,13 +63,106 @@ func main() {
 
 	Origin := os.Getenv("APP_ORIGIN")
 
+	broker := events.NewBroker()
+	// with several app instances list events have to go through postgres
+	if os.Getenv("EVENTS_BACKEND") == "postgres" {
+		if _, err := events.NewPGBridge(DbURL, database.New(db), broker); err != nil {
+			log.Fatal("failed to listen for list events")
+		}
+	}
+
+	// attempt counts have to be shared when several app instances run
+	var throttleStore throttle.Store = throttle.NewMemory()
+	switch os.Getenv("THROTTLE_BACKEND") {
+	case "postgres":
+		throttleStore = throttle.NewPGStore(db, database.New(db))
+	case "", "memory":
+	default:
+		log.Fatal("failed to load throttle config")
+	}
+
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "Smart List <no-reply@localhost>"
+	}
+
+	// without a mail server, emails are only logged
+	var mail mailer.Mailer = mailer.Log{From: mailFrom}
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		mail = mailer.NewSMTP(os.Getenv("SMTP_HOST"), port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), mailFrom)
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		mail = &mailer.File{Dir: dir, From: mailFrom}
+	case "", "log":
+	default:
+		log.Fatal("failed to load mailer config")
+	}
+
+	emailVerification := os.Getenv("EMAIL_VERIFICATION")
+	switch emailVerification {
+	case "":
+		emailVerification = verifyNone
+	case verifyNone, verifySharing, verifyLogin:
+	default:
+		log.Fatal("failed to load email verification config")
+	}
+
+	keys, err := loadKeyring(JWTkey)
+	if err != nil {
+		log.Fatalf("failed to load jwt keys: %v", err)
+	}
+
+	// cost of new password hashes; older ones are rehashed on login
+	if err := auth.SetPasswordParams(loadPasswordParams()); err != nil {
+		log.Fatalf("failed to load password hashing config: %v", err)
+	}
+	if err := auth.SetPasswordMemoryLimit(loadPasswordMemoryLimit()); err != nil {
+		log.Fatalf("failed to load password hashing config: %v", err)
+	}
+
+	// sign-in providers, see loadOIDCProviders
+	oidcProviders, err := loadOIDCProviders(Origin)
+	if err != nil {
+		log.Fatalf("failed to load oidc config: %v", err)
+	}
+
 	apiConfig := apiConfig{
 		Sql:          db,
 		Db:           database.New(db),
-		JWTKey:       JWTkey,
+		Keys:         keys,
 		CookieSecure: CookieSecure,
 		Origin:       Origin,
+		Events:       broker,
+		Mailer:       mail,
+		Throttle:     throttleStore,
+		OIDC:         oidcProviders,
+
+		EmailVerification: emailVerification,
+	}
+
+	recurrenceInterval := 15 * time.Minute
+	if v := os.Getenv("RECURRENCE_INTERVAL"); v != "" {
+		recurrenceInterval, err = time.ParseDuration(v)
+		if err != nil || recurrenceInterval <= 0 {
+			log.Fatal("failed to load recurrence interval")
+		}
 	}
+	go apiConfig.runRecurrence(context.Background(), recurrenceInterval)
+	go apiConfig.runThrottleSweep(context.Background(), throttleSweepInterval)
+	go apiConfig.runAccountDeletion(context.Background(), accountDeletionInterval)
+
+	// requests per client in each route group, e.g. RATE_LIMIT_WRITE=60/1m
+	apiReads := loadRateLimit("RATE_LIMIT_READ", "300/1m")
+	apiWrites := loadRateLimit("RATE_LIMIT_WRITE", "120/1m")
+	authLimit := loadRateLimit("RATE_LIMIT_AUTH", "30/1m")
 
 	mux := http.NewServeMux()
 
@@ -64,12 +172,138 @@ func main() {
 	}
 
 	mux.Handle("GET /", http.FileServer(http.Dir("./static")))
-	mux.HandleFunc("POST /register", apiConfig.HandleCreateUser)
-	mux.HandleFunc("POST /login", apiConfig.HandleLogin)
-	mux.Handle("GET /main", apiConfig.middlewareAuth(apiConfig.HandleAppMain))
-	mux.Handle("GET /logout", apiConfig.middlewareAuth(apiConfig.HandleLogout))
-	mux.Handle("POST /api/lists/{list_id}", apiConfig.middlewareAuth(apiConfig.middlewareApi(apiConfig.HandleAddToList)))
-	mux.Handle("POST /api/lists/", apiConfig.middlewareAuth(apiConfig.middlewareApi(apiConfig.CreateNewList)))
+	mux.HandleFunc("GET /.well-known/jwks.json", apiConfig.HandleJWKS)
+	mux.HandleFunc("POST /register", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleCreateUser))
+	mux.HandleFunc("POST /login", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleLogin))
+	mux.HandleFunc("POST /login/mfa", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleLoginMFA))
+	mux.HandleFunc("GET /auth/oidc", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleOIDCProviders))
+	mux.HandleFunc("GET /auth/oidc/{provider}", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleOIDCStart))
+	mux.HandleFunc("GET /auth/oidc/{provider}/callback", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleOIDCCallback))
+	mux.HandleFunc("POST /forgot-password", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleForgotPassword))
+	mux.HandleFunc("POST /reset-password", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleResetPassword))
+	mux.HandleFunc("GET /verify-email", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleVerifyEmail))
+	mux.HandleFunc("GET /confirm-email", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleConfirmEmailChange))
+	mux.HandleFunc("GET /restore-account", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleRestoreAccount))
+	mux.HandleFunc("POST /resend-verification", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleResendVerification))
+	mux.Handle("GET /main", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleAppMain)))
+	mux.HandleFunc("POST /auth/refresh", apiConfig.middlewareRateLimitIP(authLimit, apiConfig.HandleRefresh))
+	mux.Handle("GET /logout", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.middlewareSessionOnly(apiConfig.HandleLogout))))
+	mux.Handle("GET /api/sessions", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.middlewareSessionOnly(apiConfig.HandleGetSessions))))
+	mux.Handle("DELETE /api/sessions", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleLogoutAll)))))
+	mux.Handle("DELETE /api/sessions/{session_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleRevokeSession)))))
+	mux.Handle("GET /api/mfa", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.middlewareSessionOnly(apiConfig.HandleGetMFA))))
+	mux.Handle("POST /api/mfa/totp", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleEnrollTOTP)))))
+	mux.Handle("POST /api/mfa/totp/confirm", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleConfirmTOTP)))))
+	mux.Handle("POST /api/mfa/totp/disable", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleDisableTOTP)))))
+	mux.Handle("POST /api/mfa/recovery-codes", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleRegenerateRecoveryCodes)))))
+	mux.Handle("GET /api/tokens", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.middlewareSessionOnly(apiConfig.HandleGetAccessTokens))))
+	mux.Handle("POST /api/tokens", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleCreateAccessToken)))))
+	mux.Handle("DELETE /api/tokens/{token_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleRevokeAccessToken)))))
+	mux.Handle("GET /api/account", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleGetAccount)))
+	mux.Handle("PATCH /api/account", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleUpdateProfile))))
+	mux.Handle("POST /api/account/password", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleChangePassword)))))
+	mux.Handle("POST /api/account/email", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleChangeEmail)))))
+	mux.Handle("GET /api/account/export", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.HandleExportAccount))))
+	mux.Handle("DELETE /api/account", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareApi(apiConfig.HandleDeleteAccount)))))
+	mux.Handle("GET /api/account/activity", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.middlewareSessionOnly(apiConfig.HandleGetSecurityActivity))))
+	mux.Handle("GET /api/admin/audit-events", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.middlewareSessionOnly(apiConfig.middlewareAdmin(apiConfig.HandleAdminSearchAuditEvents)))))
+	mux.Handle("GET /api/admin/users", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.middlewareSessionOnly(apiConfig.middlewareAdmin(apiConfig.HandleAdminSearchUsers)))))
+	mux.Handle("POST /api/admin/users/{user_id}/deactivate", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareAdmin(apiConfig.middlewareApi(apiConfig.HandleAdminDeactivateUser))))))
+	mux.Handle("POST /api/admin/users/{user_id}/reactivate", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareAdmin(apiConfig.middlewareApi(apiConfig.HandleAdminReactivateUser))))))
+	mux.Handle("POST /api/admin/users/{user_id}/password-reset", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareAdmin(apiConfig.middlewareApi(apiConfig.HandleAdminResetPassword))))))
+	mux.Handle("DELETE /api/admin/users/{user_id}/sessions", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareAdmin(apiConfig.middlewareApi(apiConfig.HandleAdminRevokeSessions))))))
+	mux.Handle("PATCH /api/admin/users/{user_id}/role", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareSessionOnly(apiConfig.middlewareAdmin(apiConfig.middlewareApi(apiConfig.HandleAdminSetRole))))))
+	mux.Handle("POST /api/lists/{list_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleAddToList))))
+	mux.Handle("POST /api/lists/", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.CreateNewList))))
+	mux.Handle("GET /api/lists", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleGetLists)))
+	mux.Handle("GET /api/lists/{list_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleGetList)))
+	mux.Handle("PATCH /api/lists/{list_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleUpdateList))))
+	mux.Handle("DELETE /api/lists/{list_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleDeleteList))))
+	mux.Handle("POST /api/lists/{list_id}/items", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleCreateListItem))))
+	mux.Handle("PATCH /api/lists/{list_id}/items/{item_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleUpdateListItem))))
+	mux.Handle("DELETE /api/lists/{list_id}/items/{item_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleDeleteListItem))))
+	mux.Handle("POST /api/lists/{list_id}/items/{item_id}/toggle", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleToggleListItem))))
+	mux.Handle("DELETE /api/lists/{list_id}/items/checked", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleClearCheckedItems))))
+	mux.Handle("GET /api/lists/{list_id}/events", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleListEvents)))
+	mux.Handle("GET /api/lists/{list_id}/members", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleGetListMembers)))
+	mux.Handle("POST /api/lists/{list_id}/members", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleInviteListMember))))
+	mux.Handle("PATCH /api/lists/{list_id}/members/{member_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleUpdateListMember))))
+	mux.Handle("DELETE /api/lists/{list_id}/members/{member_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleRemoveListMember))))
+	mux.Handle("GET /api/templates", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleGetTemplates)))
+	mux.Handle("POST /api/templates", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleCreateTemplate))))
+	mux.Handle("GET /api/templates/{template_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleGetTemplate)))
+	mux.Handle("PATCH /api/templates/{template_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleUpdateTemplate))))
+	mux.Handle("DELETE /api/templates/{template_id}", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleDeleteTemplate))))
+	mux.Handle("GET /api/suggestions", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleGetSuggestions)))
+	mux.Handle("GET /api/invitations", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiReads, apiConfig.HandleGetInvitations)))
+	mux.Handle("POST /api/invitations/{list_id}/accept", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleAcceptInvitation))))
+	mux.Handle("POST /api/invitations/{list_id}/decline", apiConfig.middlewareAuth(apiConfig.middlewareRateLimit(apiWrites, apiConfig.middlewareApi(apiConfig.HandleDeclineInvitation))))
 
 	server.ListenAndServe()
 }
+
+// loadRateLimit reads the rate of a route group from env, falling back to
+// fallback; "off" turns limiting off.
+func loadRateLimit(env, fallback string) *ratelimit.Limiter {
+	v := os.Getenv(env)
+	if v == "off" {
+		return nil
+	}
+	if v == "" {
+		v = fallback
+	}
+
+	rate, err := ratelimit.ParseRate(v)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", env, err)
+	}
+	limiter, err := ratelimit.New(rate)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", env, err)
+	}
+	return limiter
+}
+
+// loadPasswordParams reads the Argon2id settings from ARGON2_MEMORY (KiB),
+// ARGON2_ITERATIONS and ARGON2_PARALLELISM, keeping the defaults for unset
+// ones.
+func loadPasswordParams() auth.PasswordParams {
+	p := auth.DefaultPasswordParams
+
+	for env, field := range map[string]*uint32{
+		"ARGON2_MEMORY":     &p.Memory,
+		"ARGON2_ITERATIONS": &p.Iterations,
+	} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				log.Fatalf("failed to load %s: %v", env, err)
+			}
+			*field = uint32(n)
+		}
+	}
+
+	if v := os.Getenv("ARGON2_PARALLELISM"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			log.Fatalf("failed to load ARGON2_PARALLELISM: %v", err)
+		}
+		p.Parallelism = uint8(n)
+	}
+
+	return p
+}
+
+// loadPasswordMemoryLimit reads ARGON2_MAX_MEMORY, the KiB the password hashes
+// running at once may use together.
+func loadPasswordMemoryLimit() uint32 {
+	v := os.Getenv("ARGON2_MAX_MEMORY")
+	if v == "" {
+		return auth.DefaultPasswordMemoryLimit
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		log.Fatalf("failed to load ARGON2_MAX_MEMORY: %v", err)
+	}
+	return uint32(n)
+}
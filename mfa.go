@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	mfaPendingTTL     = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "Smart List"
+	totpQRSize        = 256
+)
+
+var (
+	errMFAInvalidCode = errors.New("invalid code")
+	errMFANotEnabled  = errors.New("two-factor authentication is not on")
+)
+
+// mfaEnabled reports whether the user has a confirmed authenticator, i.e.
+// whether signing in takes a second step.
+func (cfg *apiConfig) mfaEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	totp, err := cfg.Db.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totp.ConfirmedAt.Valid, nil
+}
+
+// useSecondFactor accepts either a code from the authenticator or one of the
+// recovery codes, and uses it up. totp must have been read FOR UPDATE in the
+// same transaction as qtx.
+func useSecondFactor(ctx context.Context, qtx *database.Queries, totp database.UserTotp, code string) error {
+	if step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now()); ok {
+		n, err := qtx.UseTOTPStep(ctx, database.UseTOTPStepParams{
+			UserID:       totp.UserID,
+			LastUsedStep: step,
+		})
+		if err != nil {
+			return err
+		}
+		// a code that was already used is as good as a wrong one
+		if n == 0 {
+			return errMFAInvalidCode
+		}
+		return nil
+	}
+
+	n, err := qtx.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   totp.UserID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errMFAInvalidCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes throws away the user's recovery codes and makes new
+// ones. The plain codes are only ever in this return value.
+func replaceRecoveryCodes(ctx context.Context, qtx *database.Queries, userID uuid.UUID) ([]string, error) {
+	if err := qtx.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		err := qtx.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// startMFALogin is the end of the password step for accounts with two-factor
+// authentication: instead of a session, the browser gets a pending token that
+// only HandleLoginMFA accepts.
+func (cfg *apiConfig) startMFALogin(w http.ResponseWriter, userID uuid.UUID) error {
+	token, err := cfg.Keys.MakeMFAPendingJWT(userID, mfaPendingTTL)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, auth.MakeMFACookie(token, mfaPendingTTL, cfg.CookieSecure))
+	return nil
+}
+
+// HandleLoginMFA is the second step of signing in, taking a code from the
+// authenticator or a recovery code.
+func (cfg *apiConfig) HandleLoginMFA(w http.ResponseWriter, req *http.Request) {
+	if !cfg.parseAuthForm(w, req) {
+		return
+	}
+
+	pending, err := auth.GetMFACookie(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "sign-in expired, please start again", nil)
+		return
+	}
+	userID, err := cfg.Keys.ValidateMFAPendingJWT(pending)
+	if err != nil {
+		http.SetCookie(w, auth.ClearMFACookie(cfg.CookieSecure))
+		respondWithError(w, http.StatusUnauthorized, "sign-in expired, please start again", nil)
+		return
+	}
+
+	attempts := cfg.mfaAttempts(userID)
+	if wait := attemptWait(req.Context(), attempts); wait > 0 {
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	totp, err := qtx.GetTOTPForUpdate(req.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "failed to login", err)
+		return
+	}
+	if err != nil || !totp.ConfirmedAt.Valid {
+		// two-factor authentication was turned off in the meantime
+		http.SetCookie(w, auth.ClearMFACookie(cfg.CookieSecure))
+		respondWithError(w, http.StatusUnauthorized, "sign-in expired, please start again", nil)
+		return
+	}
+
+	if err := useSecondFactor(req.Context(), qtx, totp, req.PostForm.Get("code")); err != nil {
+		if errors.Is(err, errMFAInvalidCode) {
+			cfg.failAttempt(req, userID, attempts)
+			cfg.audit(req.Context(), req, userID, "login.mfa", userID.String(), "failure")
+			respondWithError(w, http.StatusUnauthorized, err.Error(), nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to login", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to login", err)
+		return
+	}
+
+	resetAttempts(req.Context(), attempts, "login-mfa")
+	http.SetCookie(w, auth.ClearMFACookie(cfg.CookieSecure))
+	if err := cfg.startSession(w, req, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to login", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "login.mfa", userID.String(), "success")
+
+	http.Redirect(w, req, "/main", http.StatusSeeOther)
+}
+
+type mfaStatusResponse struct {
+	TOTPEnabled       bool  `json:"totp_enabled"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+func (cfg *apiConfig) HandleGetMFA(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	enabled, err := cfg.mfaEnabled(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load two-factor authentication", err)
+		return
+	}
+
+	resp := mfaStatusResponse{TOTPEnabled: enabled}
+	if enabled {
+		resp.RecoveryCodesLeft, err = cfg.Db.CountRecoveryCodes(req.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to load two-factor authentication", err)
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// HandleEnrollTOTP starts setting up an authenticator app. Nothing changes
+// for signing in until the first code is confirmed with HandleConfirmTOTP;
+// enrolling again before that starts over with a new secret.
+func (cfg *apiConfig) HandleEnrollTOTP(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	user, err := cfg.Db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start two-factor authentication", err)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start two-factor authentication", err)
+		return
+	}
+
+	n, err := cfg.Db.StartTOTPEnrollment(req.Context(), database.StartTOTPEnrollmentParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start two-factor authentication", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already on", nil)
+		return
+	}
+
+	uri := auth.TOTPURI(secret, totpIssuer, user.Email)
+	png, err := qrcode.Encode(uri, qrcode.Medium, totpQRSize)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_png":      "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+func decodeMFACode(w http.ResponseWriter, req *http.Request) (string, bool) {
+	var body mfaCodeRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		respondWithError(w, http.StatusBadRequest, "failed to decode code payload", err)
+		return "", false
+	}
+	if body.Code == "" {
+		respondWithError(w, http.StatusBadRequest, "code is required", nil)
+		return "", false
+	}
+	return body.Code, true
+}
+
+// HandleConfirmTOTP turns two-factor authentication on with a first code from
+// the app, and returns the recovery codes. They are not shown again.
+func (cfg *apiConfig) HandleConfirmTOTP(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	code, ok := decodeMFACode(w, req)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	totp, err := qtx.GetTOTPForUpdate(req.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "no authenticator is being set up", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm two-factor authentication", err)
+		return
+	}
+	if totp.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already on", nil)
+		return
+	}
+
+	// recovery codes don't exist yet, so only a code from the app will do
+	step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, errMFAInvalidCode.Error(), nil)
+		return
+	}
+	if _, err := qtx.UseTOTPStep(req.Context(), database.UseTOTPStepParams{UserID: userID, LastUsedStep: step}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm two-factor authentication", err)
+		return
+	}
+	if _, err := qtx.ConfirmTOTP(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm two-factor authentication", err)
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(req.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm two-factor authentication", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm two-factor authentication", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "mfa.enable", userID.String(), "success")
+
+	respondWithJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+// tooManyAttemptsError holds off a code check until wait has passed.
+type tooManyAttemptsError struct {
+	wait time.Duration
+}
+
+func (e tooManyAttemptsError) Error() string {
+	return "too many attempts"
+}
+
+// withConfirmedTOTP runs fn in a transaction after checking code against the
+// user's authenticator, for changes that must not be made with a stolen
+// session alone. Wrong codes count like those at sign-in, and are audited
+// under action.
+func (cfg *apiConfig) withConfirmedTOTP(req *http.Request, userID uuid.UUID, action, code string, fn func(qtx *database.Queries) error) error {
+	ctx := req.Context()
+	attempts := cfg.mfaAttempts(userID)
+	if wait := attemptWait(ctx, attempts); wait > 0 {
+		return tooManyAttemptsError{wait: wait}
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	totp, err := qtx.GetTOTPForUpdate(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errMFANotEnabled
+		}
+		return err
+	}
+	if !totp.ConfirmedAt.Valid {
+		return errMFANotEnabled
+	}
+
+	if err := useSecondFactor(ctx, qtx, totp, code); err != nil {
+		if errors.Is(err, errMFAInvalidCode) {
+			cfg.failAttempt(req, userID, attempts)
+			cfg.audit(ctx, req, userID, action, userID.String(), "failure")
+		}
+		return err
+	}
+	if err := fn(qtx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	resetAttempts(ctx, attempts, "login-mfa")
+	return nil
+}
+
+func respondWithMFAError(w http.ResponseWriter, err error, msg string) {
+	var tooMany tooManyAttemptsError
+	switch {
+	case errors.As(err, &tooMany):
+		respondTooManyAttempts(w, tooMany.wait)
+	case errors.Is(err, errMFANotEnabled):
+		respondWithError(w, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, errMFAInvalidCode):
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+	default:
+		respondWithError(w, http.StatusInternalServerError, msg, err)
+	}
+}
+
+// HandleDisableTOTP turns two-factor authentication off. It takes a current
+// code or a recovery code.
+func (cfg *apiConfig) HandleDisableTOTP(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	code, ok := decodeMFACode(w, req)
+	if !ok {
+		return
+	}
+
+	err := cfg.withConfirmedTOTP(req, userID, "mfa.disable", code, func(qtx *database.Queries) error {
+		if _, err := qtx.DeleteTOTP(req.Context(), userID); err != nil {
+			return err
+		}
+		return qtx.DeleteRecoveryCodes(req.Context(), userID)
+	})
+	if err != nil {
+		respondWithMFAError(w, err, "failed to turn off two-factor authentication")
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "mfa.disable", userID.String(), "success")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (cfg *apiConfig) HandleRegenerateRecoveryCodes(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	code, ok := decodeMFACode(w, req)
+	if !ok {
+		return
+	}
+
+	var codes []string
+	err := cfg.withConfirmedTOTP(req, userID, "mfa.recovery_codes", code, func(qtx *database.Queries) error {
+		var err error
+		codes, err = replaceRecoveryCodes(req.Context(), qtx, userID)
+		return err
+	})
+	if err != nil {
+		respondWithMFAError(w, err, "failed to make new recovery codes")
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "mfa.recovery_codes", userID.String(), "success")
+
+	respondWithJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
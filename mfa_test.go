@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/throttle"
+)
+
+var totpCols = []string{"user_id", "secret", "created_at", "confirmed_at", "last_used_step"}
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+func totpFor(userID uuid.UUID, confirmedAt driver.Value) fakeHandler {
+	return func([]driver.NamedValue) fakeResult {
+		return fakeRow(totpCols, userID.String(), testTOTPSecret, time.Now(), confirmedAt, int64(0))
+	}
+}
+
+func createdSession(args []driver.NamedValue) fakeResult {
+	now := time.Now()
+	return fakeRow(sessionCols, uuid.NewString(), args[0].Value, args[1].Value, nil, now, nil, args[2].Value, nil, args[3].Value, args[4].Value, now)
+}
+
+func mfaLoginRequest(t *testing.T, userID uuid.UUID, code string) *http.Request {
+	t.Helper()
+	pending, err := auth.MakeMFAPendingJWT(userID, testJWTKey, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMFAPendingJWT: %v", err)
+	}
+	req := formRequest("/login/mfa", url.Values{"code": {code}})
+	req.AddCookie(&http.Cookie{Name: "sl_mfa", Value: pending})
+	return req
+}
+
+func TestHandleLogin_TOTPEnabled_AsksForCode(t *testing.T) {
+	userID := uuid.New()
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByEmail": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(userByEmailCols, userID.String(), "jane@example.com", "Jane", "Doe", true, now, now, hash, now)
+		},
+		"GetTOTP": totpFor(userID, time.Now()),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleLogin(rr, formRequest("/login", url.Values{"email": {"jane@example.com"}, "password": {"correct horse"}}))
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/mfa.html" {
+		t.Fatalf("want a redirect to the code page, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if fdb.called("CreateSession") || responseCookie(rr, "sl_auth") != nil {
+		t.Fatalf("no session before the second factor")
+	}
+	if responseCookie(rr, "sl_mfa") == nil {
+		t.Fatalf("a pending token should be set")
+	}
+}
+
+func TestMiddleware_MFAPendingToken_Rejected(t *testing.T) {
+	userID := uuid.New()
+	cfg, _ := newFakeConfig(t, nil)
+
+	pending, err := auth.MakeMFAPendingJWT(userID, testJWTKey, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMFAPendingJWT: %v", err)
+	}
+
+	called := false
+	h := cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
+		called = true
+	})
+
+	req := httptest.NewRequest("GET", "/main", nil)
+	req.AddCookie(&http.Cookie{Name: "sl_auth", Value: pending})
+	req.AddCookie(&http.Cookie{Name: "sl_mfa", Value: pending})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if called || rr.Code != http.StatusSeeOther {
+		t.Fatalf("a pending token must not get past the middleware, got %d", rr.Code)
+	}
+}
+
+func TestHandleLoginMFA_ValidCode_StartsSession(t *testing.T) {
+	userID := uuid.New()
+	var usedStep driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetTOTPForUpdate": totpFor(userID, time.Now()),
+		"UseTOTPStep": func(args []driver.NamedValue) fakeResult {
+			usedStep = args[1].Value
+			return fakeResult{affected: 1}
+		},
+		"CreateSession": createdSession,
+	})
+
+	code, err := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	cfg.HandleLoginMFA(rr, mfaLoginRequest(t, userID, code))
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/main" {
+		t.Fatalf("want a redirect to the app, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if usedStep == nil || !fdb.called("CreateSession") {
+		t.Fatalf("the code should be used up and a session started")
+	}
+	if c := responseCookie(rr, "sl_mfa"); c == nil || c.MaxAge >= 0 {
+		t.Fatalf("the pending token should be cleared, got %+v", c)
+	}
+}
+
+func TestHandleLoginMFA_ReplayedCode_Rejected(t *testing.T) {
+	userID := uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetTOTPForUpdate": totpFor(userID, time.Now()),
+		"UseTOTPStep":      func([]driver.NamedValue) fakeResult { return fakeResult{affected: 0} },
+	})
+
+	code, _ := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(time.Now()))
+	rr := httptest.NewRecorder()
+	cfg.HandleLoginMFA(rr, mfaLoginRequest(t, userID, code))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("status: want 401, got %d", rr.Code)
+	}
+	if fdb.called("CreateSession") {
+		t.Fatalf("a code that was already used must not sign in")
+	}
+}
+
+func TestHandleLoginMFA_RecoveryCode(t *testing.T) {
+	userID := uuid.New()
+	var usedHash driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetTOTPForUpdate": totpFor(userID, time.Now()),
+		"UseRecoveryCode": func(args []driver.NamedValue) fakeResult {
+			usedHash = args[1].Value
+			return fakeResult{affected: 1}
+		},
+		"CreateSession": createdSession,
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleLoginMFA(rr, mfaLoginRequest(t, userID, "ABCD-efgh-ijkl-mnop"))
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if usedHash != auth.HashToken("abcdefghijklmnop") {
+		t.Fatalf("the normalized recovery code should be looked up by hash, got %v", usedHash)
+	}
+}
+
+func TestHandleConfirmTOTP_ReturnsRecoveryCodes(t *testing.T) {
+	userID := uuid.New()
+	var stored []driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetTOTPForUpdate":    totpFor(userID, nil),
+		"UseTOTPStep":         func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"ConfirmTOTP":         func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"DeleteRecoveryCodes": func([]driver.NamedValue) fakeResult { return fakeResult{} },
+		"CreateRecoveryCode": func(args []driver.NamedValue) fakeResult {
+			stored = append(stored, args[1].Value)
+			return fakeResult{affected: 1}
+		},
+	})
+
+	code, _ := auth.TOTPCode(testTOTPSecret, auth.TOTPStep(time.Now()))
+	req := httptest.NewRequest("POST", "/api/mfa/totp/confirm", strings.NewReader(`{"code":"`+code+`"}`))
+	rr := httptest.NewRecorder()
+	cfg.HandleConfirmTOTP(rr, req, userID)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if !fdb.called("ConfirmTOTP") {
+		t.Fatalf("two-factor authentication should be turned on")
+	}
+
+	var got struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.RecoveryCodes) != recoveryCodeCount || len(stored) != recoveryCodeCount {
+		t.Fatalf("want %d codes returned and stored, got %d and %d", recoveryCodeCount, len(got.RecoveryCodes), len(stored))
+	}
+	for i, c := range got.RecoveryCodes {
+		if stored[i] != auth.HashToken(auth.NormalizeRecoveryCode(c)) {
+			t.Fatalf("only hashes of the recovery codes should be stored")
+		}
+	}
+}
+
+func TestHandleConfirmTOTP_WrongCode(t *testing.T) {
+	userID := uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetTOTPForUpdate": totpFor(userID, nil),
+	})
+
+	req := httptest.NewRequest("POST", "/api/mfa/totp/confirm", strings.NewReader(`{"code":"abcdef"}`))
+	rr := httptest.NewRecorder()
+	cfg.HandleConfirmTOTP(rr, req, userID)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status: want 400, got %d", rr.Code)
+	}
+	if fdb.called("ConfirmTOTP") {
+		t.Fatalf("a wrong code must not turn two-factor authentication on")
+	}
+}
+
+func TestHandleDisableTOTP_WrongCodesThrottled(t *testing.T) {
+	userID := uuid.New()
+	var outcomes []driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetTOTPForUpdate": totpFor(userID, time.Now()),
+		"UseRecoveryCode":  func([]driver.NamedValue) fakeResult { return fakeResult{affected: 0} },
+		"CreateAuditEvent": func(args []driver.NamedValue) fakeResult {
+			if args[1].Value == "mfa.disable" {
+				outcomes = append(outcomes, args[5].Value)
+			}
+			return fakeResult{affected: 1}
+		},
+	})
+	cfg.Throttle = throttle.NewMemory()
+
+	disable := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/mfa/totp/disable", strings.NewReader(`{"code":"guess-guess-guess"}`))
+		rr := httptest.NewRecorder()
+		cfg.HandleDisableTOTP(rr, req, userID)
+		return rr
+	}
+
+	for i := 1; i <= loginEmailPolicy.Free+1; i++ {
+		if rr := disable(); rr.Code != http.StatusBadRequest {
+			t.Fatalf("attempt %d: want 400, got %d", i, rr.Code)
+		}
+	}
+	if rr := disable(); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("want 429 with Retry-After, got %d", rr.Code)
+	}
+	if len(outcomes) != loginEmailPolicy.Free+1 || outcomes[0] != "failure" {
+		t.Fatalf("each wrong code should be audited, got %v", outcomes)
+	}
+	if fdb.called("DeleteTOTP") {
+		t.Fatalf("two-factor authentication must stay on")
+	}
+}
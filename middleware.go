@@ -1,51 +1,131 @@
 package main
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/ratelimit"
 )
 
 type authedHandler func(w http.ResponseWriter, req *http.Request, userID uuid.UUID)
 
 func (cfg *apiConfig) middlewareAuth(handler authedHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		token, err := auth.GetJWTCookie(req)
-		if err != nil {
-			clearCookie := auth.ClearAuthCookie(cfg.CookieSecure)
-			http.SetCookie(w, clearCookie)
-			http.Redirect(w, req, "/?session=expired", http.StatusSeeOther)
+		if req.Header.Get("Authorization") != "" {
+			cfg.authenticateBearer(w, req, handler)
 			return
 		}
 
-		userID, err := auth.ValidateJWT(token, cfg.JWTKey)
+		var userID, sessionID uuid.UUID
+
+		token, err := auth.GetJWTCookie(req)
+		if err == nil {
+			userID, sessionID, err = cfg.Keys.ValidateSessionJWT(token)
+		}
+		if err == nil {
+			err = cfg.checkSession(req.Context(), userID, sessionID)
+			if err != nil && !errors.Is(err, errSessionInvalid) {
+				respondWithError(w, http.StatusInternalServerError, "failed to check session", err)
+				return
+			}
+		}
+
+		// access tokens are short-lived, renew them from the refresh token
 		if err != nil {
-			clearCookie := auth.ClearAuthCookie(cfg.CookieSecure)
-			http.SetCookie(w, clearCookie)
-			http.Redirect(w, req, "/?session=expired", http.StatusSeeOther)
-			return
+			session, err := cfg.refreshSession(w, req)
+			if errors.Is(err, errRefreshRaced) {
+				respondWithError(w, http.StatusUnauthorized, "session is being refreshed, retry", nil)
+				return
+			}
+			if err != nil {
+				if !errors.Is(err, errSessionInvalid) {
+					log.Printf("sessions: failed to refresh: %v", err)
+				}
+				cfg.clearSessionCookies(w)
+				http.Redirect(w, req, "/?session=expired", http.StatusSeeOther)
+				return
+			}
+			userID, sessionID = session.UserID, session.ID
 		}
 
-		handler(w, req, userID)
+		handler(w, req.WithContext(withSessionID(req.Context(), sessionID)), userID)
 	}
 }
 
 func (cfg *apiConfig) middlewareApi(next authedHandler) authedHandler {
 	return func(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
-		err := auth.CheckOrigin(cfg.Origin, req)
-		if err != nil {
-			respondWithError(w, http.StatusForbidden, "invalid request", nil)
+		// browsers send cookies along on their own, bearer tokens never are,
+		// so only cookie requests can be forged from another site
+		if tokenScopeFromContext(req.Context()) == "" {
+			if err := auth.CheckOrigin(cfg.Origin, req); err != nil {
+				respondWithError(w, http.StatusForbidden, "invalid request", nil)
+				return
+			}
+		}
+
+		// requests without a body (reads, deletes) have no media type to check
+		if req.Method != http.MethodGet && req.Method != http.MethodHead && req.Method != http.MethodDelete {
+			if err := auth.EnforceMediaType("json", req); err != nil {
+				respondWithError(w, http.StatusUnsupportedMediaType, "invalid media type", nil)
+				return
+			}
+		}
+
+		next(w, req, userID)
+	}
+
+}
+
+// middlewareRateLimit holds each user to the rate of limiter. It goes after
+// middlewareAuth, so that it knows who is asking. A nil limiter lets
+// everything through.
+func (cfg *apiConfig) middlewareRateLimit(limiter *ratelimit.Limiter, next authedHandler) authedHandler {
+	return func(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+		if !allowRequest(w, limiter, "user:"+userID.String()) {
 			return
 		}
+		next(w, req, userID)
+	}
+}
 
-		err = auth.EnforceMediaType("json", req)
-		if err != nil {
-			respondWithError(w, http.StatusUnsupportedMediaType, "invalid media type", nil)
+// middlewareRateLimitIP is middlewareRateLimit for routes used before signing
+// in, keyed by client address.
+func (cfg *apiConfig) middlewareRateLimitIP(limiter *ratelimit.Limiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !allowRequest(w, limiter, "ip:"+clientIP(req)) {
 			return
 		}
+		next(w, req)
+	}
+}
 
-		next(w, req, userID)
+// allowRequest takes a token for key and sets the RateLimit headers. When
+// there is none left it responds with 429 itself.
+func allowRequest(w http.ResponseWriter, limiter *ratelimit.Limiter, key string) bool {
+	if limiter == nil {
+		return true
 	}
 
+	res := limiter.Allow(key, time.Now())
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		respondWithError(w, http.StatusTooManyRequests, "rate limit exceeded", nil)
+		return false
+	}
+	return true
+}
+
+// ceilSeconds rounds d up to whole seconds, for headers like Retry-After.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
@@ -1,18 +1,21 @@
 package main
 
 import (
+	"database/sql/driver"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/ratelimit"
 )
 
 const testJWTKey = "0123456789abcdef0123456789abcdef" // HS256 secret
 
-// helper to mint a token that your ValidateJWT accepts (issuer = smart-list)
+// helper to mint an access token for a fresh session (issuer = smart-list)
 func makeToken(t *testing.T, uid uuid.UUID, key string, ttl time.Duration) string {
 	t.Helper()
 	claims := jwt.RegisteredClaims{
@@ -20,6 +23,7 @@ func makeToken(t *testing.T, uid uuid.UUID, key string, ttl time.Duration) strin
 		Subject:   uid.String(),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		ID:        uuid.NewString(),
 	}
 	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
 	if err != nil {
@@ -28,17 +32,17 @@ func makeToken(t *testing.T, uid uuid.UUID, key string, ttl time.Duration) strin
 	return s
 }
 
-func wrap(next authedHandler) http.HandlerFunc {
-	cfg := &apiConfig{
-		JWTKey:       testJWTKey,
-		CookieSecure: false,
-		Origin:       "http://website.com",
-	}
+// wrap puts next behind middlewareAuth, with every session still active.
+func wrap(t *testing.T, next authedHandler) http.HandlerFunc {
+	t.Helper()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"TouchSession": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
 	return cfg.middlewareAuth(next)
 }
 
 func TestMiddleware_NoCookie_RedirectsAndClears(t *testing.T) {
-	h := wrap(func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
+	h := wrap(t, func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
 		w.WriteHeader(http.StatusOK)
 	})
 	req := httptest.NewRequest("GET", "/main", nil)
@@ -75,7 +79,7 @@ func TestMiddleware_NoCookie_RedirectsAndClears(t *testing.T) {
 }
 
 func TestMiddleware_InvalidJWT_RedirectsAndClears(t *testing.T) {
-	h := wrap(func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
+	h := wrap(t, func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
 		w.WriteHeader(http.StatusOK)
 	})
 	req := httptest.NewRequest("GET", "/main", nil)
@@ -91,7 +95,7 @@ func TestMiddleware_InvalidJWT_RedirectsAndClears(t *testing.T) {
 }
 
 func TestMiddleware_ExpiredJWT_Redirects(t *testing.T) {
-	h := wrap(func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
+	h := wrap(t, func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
 		w.WriteHeader(http.StatusOK)
 	})
 	uid := uuid.New()
@@ -110,7 +114,7 @@ func TestMiddleware_ExpiredJWT_Redirects(t *testing.T) {
 
 func TestMiddleware_ValidJWT_PassesUserID(t *testing.T) {
 	var got uuid.UUID
-	h := wrap(func(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	h := wrap(t, func(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
 		got = userID
 		w.WriteHeader(http.StatusOK)
 	})
@@ -131,17 +135,42 @@ func TestMiddleware_ValidJWT_PassesUserID(t *testing.T) {
 	}
 }
 
-func TestMiddleware_Api_Origin(t *testing.T) {
-	cfg := &apiConfig{
-		JWTKey: testJWTKey,
+func TestMiddleware_TokenWithoutSession_Redirects(t *testing.T) {
+	h := wrap(t, func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
+		t.Fatalf("handler must not run")
+	})
+	// signed with the right key, but nothing to revoke it by
+	claims := jwt.RegisteredClaims{
+		Issuer:    "smart-list",
+		Subject:   uuid.NewString(),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(2 * time.Minute)),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTKey))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/main", nil)
+	req.AddCookie(&http.Cookie{Name: "sl_auth", Value: token, Path: "/"})
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d", rr.Code)
 	}
+}
+
+func TestMiddleware_Api_Origin(t *testing.T) {
+	cfg := &apiConfig{}
 	userID := uuid.New()
-	token := makeToken(t, userID, cfg.JWTKey, 2*time.Minute)
+	token := makeToken(t, userID, testJWTKey, 2*time.Minute)
 	authandler := cfg.middlewareApi(func(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	h := wrap(authandler)
+	h := wrap(t, authandler)
 
 	req := httptest.NewRequest("POST", "/api/lists", nil)
 	req.Header.Set("Origin", "http://website.com")
@@ -158,16 +187,15 @@ func TestMiddleware_Api_Origin(t *testing.T) {
 
 func TestMiddleware_Api_MediaType(t *testing.T) {
 	cfg := &apiConfig{
-		JWTKey: testJWTKey,
 		Origin: "http:localhost:8888",
 	}
 	userID := uuid.New()
-	token := makeToken(t, userID, cfg.JWTKey, 2*time.Minute)
+	token := makeToken(t, userID, testJWTKey, 2*time.Minute)
 	authandler := cfg.middlewareApi(func(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	h := wrap(authandler)
+	h := wrap(t, authandler)
 
 	req := httptest.NewRequest("POST", "/api/lists", nil)
 	req.Header.Set("Origin", cfg.Origin)
@@ -181,3 +209,89 @@ func TestMiddleware_Api_MediaType(t *testing.T) {
 		t.Fatalf("expected %d Unsupported MediaType, got %d", http.StatusUnsupportedMediaType, rr.Code)
 	}
 }
+
+func TestMiddleware_Api_DeleteWithoutBody(t *testing.T) {
+	cfg := &apiConfig{
+		Origin: "http://website.com",
+	}
+	userID := uuid.New()
+	token := makeToken(t, userID, testJWTKey, 2*time.Minute)
+	authandler := cfg.middlewareApi(func(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	h := wrap(t, authandler)
+
+	req := httptest.NewRequest("DELETE", "/api/lists/"+uuid.NewString(), nil)
+	req.Header.Set("Origin", cfg.Origin)
+	req.AddCookie(&http.Cookie{Name: "sl_auth", Value: token, Path: "/"})
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected %d No Content, got %d", http.StatusNoContent, rr.Code)
+	}
+}
+
+func TestMiddleware_RateLimit_PerUser(t *testing.T) {
+	cfg := &apiConfig{}
+	limiter, err := ratelimit.New(ratelimit.Rate{Limit: 2, Period: time.Minute})
+	if err != nil {
+		t.Fatalf("ratelimit.New: %v", err)
+	}
+	h := cfg.middlewareRateLimit(limiter, func(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	call := func(userID uuid.UUID) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h(rr, httptest.NewRequest("GET", "/api/lists", nil), userID)
+		return rr
+	}
+
+	alice, bob := uuid.New(), uuid.New()
+	call(alice)
+	rr := call(alice)
+	if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "2" || rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("second request: %d %v", rr.Code, rr.Header())
+	}
+
+	rr = call(alice)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "30" || rr.Header().Get("RateLimit-Reset") != "60" {
+		t.Fatalf("unexpected headers: %v", rr.Header())
+	}
+	if !strings.Contains(rr.Body.String(), `"error"`) {
+		t.Fatalf("expected a JSON error, got %s", rr.Body.String())
+	}
+
+	if rr := call(bob); rr.Code != http.StatusOK {
+		t.Fatalf("another user must not be limited, got %d", rr.Code)
+	}
+}
+
+func TestMiddleware_RateLimitIP(t *testing.T) {
+	cfg := &apiConfig{}
+	limiter, _ := ratelimit.New(ratelimit.Rate{Limit: 1, Period: time.Minute})
+	h := cfg.middlewareRateLimitIP(limiter, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	call := func(addr string) int {
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr.Code
+	}
+
+	if call("10.0.0.1:1234") != http.StatusOK || call("10.0.0.1:5678") != http.StatusTooManyRequests {
+		t.Fatalf("the second request from one address should be limited, whatever the port")
+	}
+	if call("10.0.0.2:1234") != http.StatusOK {
+		t.Fatalf("other addresses must not be limited")
+	}
+}
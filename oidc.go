@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/oidc"
+)
+
+const (
+	// how long the user has to sign in at the provider
+	oidcStateTTL = 10 * time.Minute
+
+	// same limit as the signup form
+	maxOIDCNameLen = 50
+)
+
+var errOIDCEmailUnverified = errors.New("the provider has no verified email address for this account")
+
+var oidcProviderID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS, e.g.
+// "google,work", each configured with OIDC_<ID>_ISSUER, _CLIENT_ID,
+// _CLIENT_SECRET and optionally _NAME and _SCOPES.
+func loadOIDCProviders(origin string) ([]*oidc.Provider, error) {
+	var providers []*oidc.Provider
+
+	for _, id := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if !oidcProviderID.MatchString(id) {
+			return nil, fmt.Errorf("invalid provider id %q", id)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		cfg := oidc.Config{
+			ID:           id,
+			Name:         os.Getenv(prefix + "NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  strings.TrimSuffix(origin, "/") + "/auth/oidc/" + id + "/callback",
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("provider %q needs %sISSUER and %sCLIENT_ID", id, prefix, prefix)
+		}
+		if cfg.Name == "" {
+			cfg.Name = id
+		}
+
+		providers = append(providers, oidc.New(cfg))
+	}
+
+	return providers, nil
+}
+
+func (cfg *apiConfig) oidcProvider(id string) *oidc.Provider {
+	for _, p := range cfg.OIDC {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// HandleOIDCProviders lists the providers for the sign-in page.
+func (cfg *apiConfig) HandleOIDCProviders(w http.ResponseWriter, req *http.Request) {
+	type providerResponse struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	resp := make([]providerResponse, 0, len(cfg.OIDC))
+	for _, p := range cfg.OIDC {
+		resp = append(resp, providerResponse{ID: p.ID, Name: p.Name})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// HandleOIDCStart sends the user to the provider to sign in. What is needed
+// to check the answer goes along in a short-lived cookie.
+func (cfg *apiConfig) HandleOIDCStart(w http.ResponseWriter, req *http.Request) {
+	provider := cfg.oidcProvider(req.PathValue("provider"))
+	if provider == nil {
+		respondWithError(w, http.StatusNotFound, "unknown provider", nil)
+		return
+	}
+
+	state := auth.OIDCState{Provider: provider.ID}
+	for _, v := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		s, err := oidc.RandomString()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to start sign-in", err)
+			return
+		}
+		*v = s
+	}
+
+	authURL, err := provider.AuthCodeURL(req.Context(), state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		http.Redirect(w, req, "/login.html?oidc=failed", http.StatusSeeOther)
+		return
+	}
+
+	token, err := cfg.Keys.MakeOIDCStateJWT(state, oidcStateTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start sign-in", err)
+		return
+	}
+
+	http.SetCookie(w, auth.MakeOIDCCookie(token, oidcStateTTL, cfg.CookieSecure))
+	http.Redirect(w, req, authURL, http.StatusSeeOther)
+}
+
+// HandleOIDCCallback is where the provider sends the user back. It checks
+// the answer against the cookie set by HandleOIDCStart, trades the code for
+// an ID token and signs in the user it belongs to.
+func (cfg *apiConfig) HandleOIDCCallback(w http.ResponseWriter, req *http.Request) {
+	provider := cfg.oidcProvider(req.PathValue("provider"))
+	if provider == nil {
+		respondWithError(w, http.StatusNotFound, "unknown provider", nil)
+		return
+	}
+
+	// every state works once, whatever happens next
+	http.SetCookie(w, auth.ClearOIDCCookie(cfg.CookieSecure))
+
+	fail := func(reason string, err error) {
+		if err != nil {
+			log.Printf("oidc: sign-in with %s failed: %v", provider.ID, err)
+		}
+		http.Redirect(w, req, "/login.html?oidc="+reason, http.StatusSeeOther)
+	}
+
+	query := req.URL.Query()
+	if query.Get("error") != "" {
+		fail("cancelled", nil)
+		return
+	}
+
+	raw, err := auth.GetOIDCCookie(req)
+	if err != nil {
+		fail("failed", nil)
+		return
+	}
+	state, err := cfg.Keys.ValidateOIDCStateJWT(raw)
+	if err != nil || state.Provider != provider.ID ||
+		subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+		fail("failed", nil)
+		return
+	}
+
+	idToken, err := provider.Exchange(req.Context(), query.Get("code"), state.Verifier)
+	if err != nil {
+		fail("failed", err)
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(req.Context(), idToken, state.Nonce)
+	if err != nil {
+		fail("failed", err)
+		return
+	}
+
+	userID, outcome, err := cfg.oidcUser(req.Context(), provider.ID, claims)
+	if err != nil {
+		if errors.Is(err, errOIDCEmailUnverified) {
+			fail("unverified", nil)
+			return
+		}
+		fail("failed", err)
+		return
+	}
+
+	user, err := cfg.Db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		fail("failed", err)
+		return
+	}
+	if !user.IsActive {
+		fail("inactive", nil)
+		return
+	}
+
+	cfg.audit(req.Context(), req, userID, "login.oidc", provider.ID, outcome)
+
+	mfa, err := cfg.mfaEnabled(req.Context(), userID)
+	if err != nil {
+		fail("failed", err)
+		return
+	}
+	if mfa {
+		if err = cfg.startMFALogin(w, userID); err != nil {
+			fail("failed", err)
+			return
+		}
+		http.Redirect(w, req, "/mfa.html", http.StatusSeeOther)
+		return
+	}
+
+	if err = cfg.startSession(w, req, userID); err != nil {
+		fail("failed", err)
+		return
+	}
+
+	http.Redirect(w, req, "/main", http.StatusSeeOther)
+}
+
+// oidcUser finds the user an external identity belongs to. An identity seen
+// for the first time is linked to the account with the same email address,
+// or gets a new account, but only if the provider verified that address. An
+// account that never verified the address is claimed rather than just linked,
+// see claimUnverifiedAccount. outcome says which of these happened.
+func (cfg *apiConfig) oidcUser(ctx context.Context, providerID string, claims *oidc.Claims) (uuid.UUID, string, error) {
+	email := strings.TrimSpace(claims.Email)
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	identity, err := qtx.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Provider: providerID,
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		if err := qtx.TouchUserIdentity(ctx, database.TouchUserIdentityParams{ID: identity.ID, Email: email}); err != nil {
+			return uuid.Nil, "", err
+		}
+		return identity.UserID, "success", tx.Commit()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, "", err
+	}
+
+	// anyone can claim any address at some providers, so an unverified one
+	// must never open an existing account
+	if email == "" || !claims.EmailVerified {
+		return uuid.Nil, "", errOIDCEmailUnverified
+	}
+
+	outcome := "linked"
+	var userID uuid.UUID
+	var userEmail string
+
+	existing, err := qtx.GetUserByEmail(ctx, email)
+	switch {
+	case err == nil:
+		userID, userEmail = existing.ID, existing.Email
+		if !existing.EmailVerifiedAt.Valid {
+			if err := claimUnverifiedAccount(ctx, qtx, existing.ID); err != nil {
+				return uuid.Nil, "", err
+			}
+			outcome = "claimed"
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		user, err := createOIDCUser(ctx, qtx, email, claims)
+		if err != nil {
+			return uuid.Nil, "", err
+		}
+		userID, userEmail, outcome = user.ID, user.Email, "created"
+	default:
+		return uuid.Nil, "", err
+	}
+
+	if _, err := qtx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		UserID:   userID,
+		Provider: providerID,
+		Subject:  claims.Subject,
+		Email:    email,
+	}); err != nil {
+		return uuid.Nil, "", err
+	}
+
+	// the provider just vouched for the address
+	if _, err := qtx.SetEmailVerified(ctx, database.SetEmailVerifiedParams{ID: userID, Email: userEmail}); err != nil {
+		return uuid.Nil, "", err
+	}
+
+	return userID, outcome, tx.Commit()
+}
+
+// claimUnverifiedAccount hands an account whose address was never verified
+// to whoever the provider says owns it. Someone else may have signed up with
+// that address first, so everything they could still sign in with goes: the
+// password, sessions, access tokens, two-factor and pending links.
+func claimUnverifiedAccount(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+	secret, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+	hashedPwd, err := auth.HashPassword(secret)
+	if err != nil {
+		return err
+	}
+
+	if err := qtx.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{ID: userID, HashedPassword: hashedPwd}); err != nil {
+		return err
+	}
+	if err := qtx.UsePasswordResets(ctx, userID); err != nil {
+		return err
+	}
+	if err := qtx.UseEmailChanges(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := qtx.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+	if err := qtx.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	return revokeUserCredentials(ctx, qtx, userID)
+}
+
+// createOIDCUser makes an account for someone who signed up through a
+// provider. It gets a random password nobody knows; "Forgot your password?"
+// sets a real one.
+func createOIDCUser(ctx context.Context, qtx *database.Queries, email string, claims *oidc.Claims) (database.User, error) {
+	secret, err := auth.MakeToken()
+	if err != nil {
+		return database.User{}, err
+	}
+	hashedPwd, err := auth.HashPassword(secret)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	firstName, lastName := strings.TrimSpace(claims.GivenName), strings.TrimSpace(claims.FamilyName)
+	if firstName == "" {
+		firstName, lastName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(email, "@")
+	}
+
+	return qtx.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hashedPwd,
+		FirstName:      truncateRunes(firstName, maxOIDCNameLen),
+		LastName:       truncateRunes(strings.TrimSpace(lastName), maxOIDCNameLen),
+	})
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
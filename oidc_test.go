@@ -0,0 +1,257 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/oidc"
+	"github.com/henrique-godinho/smart-list/internal/oidc/oidctest"
+)
+
+var userCols = []string{"id", "created_at", "updated_at", "email", "hashed_password", "is_active", "first_name", "last_name", "email_verified_at", "verification_sent_at", "role"}
+
+var userIdentityCols = []string{"id", "user_id", "provider", "subject", "email", "created_at", "last_login_at"}
+
+func activeUser(args []driver.NamedValue) fakeResult {
+	now := time.Now()
+	return fakeRow(userCols, args[0].Value, now, now, "ana@example.com", "hash", true, "Ana", "Silva", now, nil, "user")
+}
+
+func createdIdentity(args []driver.NamedValue) fakeResult {
+	now := time.Now()
+	return fakeRow(userIdentityCols, uuid.NewString(), args[0].Value, args[1].Value, args[2].Value, args[3].Value, now, now)
+}
+
+// oidcConfig is newFakeConfig with a single provider "test" backed by a mock
+// provider. handlers come on top of those every successful sign-in needs.
+func oidcConfig(t *testing.T, handlers map[string]fakeHandler) (*apiConfig, *fakeDB, *oidctest.Server) {
+	t.Helper()
+	srv := oidctest.NewServer()
+	t.Cleanup(srv.Close)
+
+	base := map[string]fakeHandler{
+		"GetUserByID":      activeUser,
+		"GetTOTP":          func([]driver.NamedValue) fakeResult { return fakeResult{cols: totpCols} },
+		"CreateSession":    createdSession,
+		"CreateAuditEvent": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	}
+	for name, h := range handlers {
+		base[name] = h
+	}
+
+	cfg, fdb := newFakeConfig(t, base)
+	cfg.OIDC = []*oidc.Provider{oidc.New(oidc.Config{
+		ID:           "test",
+		Name:         "Test",
+		Issuer:       srv.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://website.com/auth/oidc/test/callback",
+	})}
+	return cfg, fdb, srv
+}
+
+// signInWith goes through the whole round trip: our start handler, the
+// provider's authorization endpoint and our callback. tamper can change the
+// callback request before it is handled.
+func signInWith(t *testing.T, cfg *apiConfig, tamper func(*http.Request)) *httptest.ResponseRecorder {
+	t.Helper()
+
+	start := httptest.NewRequest("GET", "/auth/oidc/test", nil)
+	start.SetPathValue("provider", "test")
+	rr := httptest.NewRecorder()
+	cfg.HandleOIDCStart(rr, start)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("start: want 303, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	stateCookie := responseCookie(rr, "sl_oidc")
+	if stateCookie == nil {
+		t.Fatalf("start: want the state cookie")
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: want a redirect, got %s", resp.Status)
+	}
+
+	callback := httptest.NewRequest("GET", back.RequestURI(), nil)
+	callback.SetPathValue("provider", "test")
+	callback.AddCookie(stateCookie)
+	if tamper != nil {
+		tamper(callback)
+	}
+	rr = httptest.NewRecorder()
+	cfg.HandleOIDCCallback(rr, callback)
+	return rr
+}
+
+func TestOIDC_KnownIdentity_SignsIn(t *testing.T) {
+	userID := uuid.New()
+	cfg, fdb, srv := oidcConfig(t, map[string]fakeHandler{
+		"GetUserIdentity": func(args []driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(userIdentityCols, uuid.NewString(), userID.String(), args[0].Value, args[1].Value, "old@example.com", now, now)
+		},
+		"TouchUserIdentity": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+	// the address may have changed at the provider, the subject never does
+	srv.SetUser(oidctest.User{Subject: "sub-1", Email: "new@example.com"})
+
+	rr := signInWith(t, cfg, nil)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/main" {
+		t.Fatalf("want a redirect to /main, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if responseCookie(rr, "sl_auth") == nil {
+		t.Fatalf("a session should be started")
+	}
+	if fdb.called("GetUserByEmail") || fdb.called("CreateUser") {
+		t.Fatalf("a known identity must not be matched by email")
+	}
+}
+
+func TestOIDC_VerifiedEmail_LinksExistingUser(t *testing.T) {
+	userID := uuid.New()
+	var linkedTo, verified driver.Value
+	cfg, fdb, srv := oidcConfig(t, map[string]fakeHandler{
+		"GetUserIdentity": func([]driver.NamedValue) fakeResult { return fakeResult{cols: userIdentityCols} },
+		"GetUserByEmail":  userWithEmail(userID, "ana@example.com"),
+		"CreateUserIdentity": func(args []driver.NamedValue) fakeResult {
+			linkedTo = args[0].Value
+			return createdIdentity(args)
+		},
+		"SetEmailVerified": func(args []driver.NamedValue) fakeResult {
+			verified = args[0].Value
+			return fakeResult{affected: 1}
+		},
+	})
+	srv.SetUser(oidctest.User{Subject: "sub-1", Email: "ana@example.com", EmailVerified: true})
+
+	rr := signInWith(t, cfg, nil)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/main" {
+		t.Fatalf("want a redirect to /main, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if linkedTo != userID.String() || verified != userID.String() {
+		t.Fatalf("identity should be linked to %s, got %v (verified %v)", userID, linkedTo, verified)
+	}
+	if fdb.called("CreateUser") {
+		t.Fatalf("an existing account must not be duplicated")
+	}
+}
+
+func TestOIDC_UnverifiedAccount_Claimed(t *testing.T) {
+	userID := uuid.New()
+	var oldHash driver.Value = "attacker's hash"
+	cfg, fdb, srv := oidcConfig(t, map[string]fakeHandler{
+		"GetUserIdentity": func([]driver.NamedValue) fakeResult { return fakeResult{cols: userIdentityCols} },
+		// someone signed up with the address but never verified it
+		"GetUserByEmail": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(userByEmailCols, userID.String(), "ana@example.com", "Ana", "Silva", true, now, now, "attacker's hash", nil)
+		},
+		"UpdateUserPassword": func(args []driver.NamedValue) fakeResult {
+			oldHash = args[1].Value
+			return fakeResult{affected: 1}
+		},
+		"UsePasswordResets":              func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"UseEmailChanges":                func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"DeleteTOTP":                     func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"DeleteRecoveryCodes":            func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeUserSessions":             func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeUserPersonalAccessTokens": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"CreateUserIdentity":             createdIdentity,
+		"SetEmailVerified":               func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+	srv.SetUser(oidctest.User{Subject: "sub-1", Email: "ana@example.com", EmailVerified: true})
+
+	rr := signInWith(t, cfg, nil)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/main" {
+		t.Fatalf("want a redirect to /main, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if oldHash == "attacker's hash" {
+		t.Fatalf("the password set before the address was verified must stop working")
+	}
+	for _, q := range []string{"RevokeUserSessions", "RevokeUserPersonalAccessTokens", "DeleteTOTP"} {
+		if !fdb.called(q) {
+			t.Fatalf("%s: whoever signed up first must be locked out", q)
+		}
+	}
+}
+
+func TestOIDC_UnverifiedEmail_Refused(t *testing.T) {
+	cfg, fdb, srv := oidcConfig(t, map[string]fakeHandler{
+		"GetUserIdentity": func([]driver.NamedValue) fakeResult { return fakeResult{cols: userIdentityCols} },
+	})
+	srv.SetUser(oidctest.User{Subject: "sub-1", Email: "ana@example.com", EmailVerified: false})
+
+	rr := signInWith(t, cfg, nil)
+
+	if rr.Header().Get("Location") != "/login.html?oidc=unverified" {
+		t.Fatalf("want the unverified page, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if fdb.called("GetUserByEmail") || fdb.called("CreateSession") {
+		t.Fatalf("an unverified address must not reach any account")
+	}
+}
+
+func TestOIDC_NewUser_CreatesAccount(t *testing.T) {
+	newID := uuid.New()
+	var firstName driver.Value
+	cfg, _, srv := oidcConfig(t, map[string]fakeHandler{
+		"GetUserIdentity": func([]driver.NamedValue) fakeResult { return fakeResult{cols: userIdentityCols} },
+		"GetUserByEmail":  func([]driver.NamedValue) fakeResult { return fakeResult{cols: userByEmailCols} },
+		"CreateUser": func(args []driver.NamedValue) fakeResult {
+			firstName = args[2].Value
+			now := time.Now()
+			return fakeRow(userCols, newID.String(), now, now, args[0].Value, args[1].Value, true, args[2].Value, args[3].Value, nil, nil, "user")
+		},
+		"CreateUserIdentity": createdIdentity,
+		"SetEmailVerified":   func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+	srv.SetUser(oidctest.User{Subject: "sub-2", Email: "bo@example.com", EmailVerified: true, GivenName: "Bo"})
+
+	rr := signInWith(t, cfg, nil)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/main" {
+		t.Fatalf("want a redirect to /main, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if firstName != "Bo" {
+		t.Fatalf("want the name from the provider, got %v", firstName)
+	}
+}
+
+func TestOIDC_StateMismatch_Refused(t *testing.T) {
+	cfg, fdb, srv := oidcConfig(t, nil)
+	srv.SetUser(oidctest.User{Subject: "sub-1", Email: "ana@example.com", EmailVerified: true})
+
+	rr := signInWith(t, cfg, func(req *http.Request) {
+		q := req.URL.Query()
+		q.Set("state", "forged")
+		req.URL.RawQuery = q.Encode()
+	})
+
+	if rr.Header().Get("Location") != "/login.html?oidc=failed" {
+		t.Fatalf("want the failure page, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if fdb.called("GetUserIdentity") || responseCookie(rr, "sl_auth") != nil {
+		t.Fatalf("a forged state must not sign anyone in")
+	}
+	if c := responseCookie(rr, "sl_oidc"); c == nil || c.MaxAge >= 0 {
+		t.Fatalf("the state cookie should be cleared, got %+v", c)
+	}
+}
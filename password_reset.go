@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+)
+
+const passwordResetTTL = time.Hour
+
+var errResetTokenInvalid = errors.New("this reset link is invalid or has expired")
+
+// parseAuthForm applies the checks shared by the account forms: size limit,
+// form media type, same origin. It responds itself when they fail.
+func (cfg *apiConfig) parseAuthForm(w http.ResponseWriter, req *http.Request) bool {
+	const maxFormSize = 4 * 1024
+	req.Body = http.MaxBytesReader(w, req.Body, maxFormSize)
+
+	if err := auth.EnforceMediaType("form", req); err != nil {
+		respondWithError(w, http.StatusUnsupportedMediaType, "invalid content type", err)
+		return false
+	}
+
+	if err := auth.CheckOrigin(cfg.Origin, req); err != nil {
+		respondWithError(w, http.StatusForbidden, "invalid request", err)
+		return false
+	}
+
+	if err := req.ParseForm(); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		respondWithError(w, http.StatusBadRequest, "invalid form data", err)
+		return false
+	}
+
+	return true
+}
+
+// HandleForgotPassword emails a reset link to the address if it belongs to an
+// active account. The response is the same either way.
+func (cfg *apiConfig) HandleForgotPassword(w http.ResponseWriter, req *http.Request) {
+	if !cfg.parseAuthForm(w, req) {
+		return
+	}
+
+	_, _, email, err := auth.ValidateInput("login", map[string]string{
+		"email": req.PostForm.Get("email"),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if err := cfg.startPasswordReset(req, email); err != nil {
+		log.Printf("password reset: %v", err)
+	}
+
+	http.Redirect(w, req, "/login.html?reset=requested", http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) startPasswordReset(req *http.Request, email string) error {
+	user, err := cfg.Db.GetUserByEmail(req.Context(), email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	link, err := cfg.createPasswordReset(req.Context(), cfg.Db, user.ID)
+	if err != nil {
+		return err
+	}
+
+	cfg.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Smart List password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your Smart List account. To choose a
+new password, open this link within the next hour:
+
+%s
+
+If it wasn't you, you can ignore this email; your password stays the same.
+`, user.FirstName, link),
+	})
+	return nil
+}
+
+// createPasswordReset stores a new reset token for the user and returns the
+// link to mail them.
+func (cfg *apiConfig) createPasswordReset(ctx context.Context, q *database.Queries, userID uuid.UUID) (string, error) {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = q.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+		UserID:    userID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return cfg.Origin + "/reset-password.html?token=" + url.QueryEscape(token), nil
+}
+
+// HandleResetPassword sets a new password from an emailed reset link. The
+// link works once, and every session of the account is signed out.
+func (cfg *apiConfig) HandleResetPassword(w http.ResponseWriter, req *http.Request) {
+	if !cfg.parseAuthForm(w, req) {
+		return
+	}
+
+	token := req.PostForm.Get("token")
+	pwd := req.PostForm.Get("password")
+
+	if token == "" {
+		respondWithError(w, http.StatusBadRequest, errResetTokenInvalid.Error(), nil)
+		return
+	}
+
+	hashedPwd, err := auth.HashPassword(pwd)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	reset, err := qtx.GetPasswordResetForUpdate(req.Context(), auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusBadRequest, errResetTokenInvalid.Error(), nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+	if reset.UsedAt.Valid || time.Now().After(reset.ExpiresAt) {
+		respondWithError(w, http.StatusBadRequest, errResetTokenInvalid.Error(), nil)
+		return
+	}
+
+	err = qtx.UpdateUserPassword(req.Context(), database.UpdateUserPasswordParams{
+		ID:             reset.UserID,
+		HashedPassword: hashedPwd,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+
+	// other links sent before this one must not work either
+	if err = qtx.UsePasswordResets(req.Context(), reset.UserID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+
+	if err = revokeUserCredentials(req.Context(), qtx, reset.UserID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password", err)
+		return
+	}
+	cfg.audit(req.Context(), req, reset.UserID, "password.reset", reset.UserID.String(), "success")
+
+	cfg.clearSessionCookies(w)
+	http.Redirect(w, req, "/login.html?reset=done", http.StatusSeeOther)
+}
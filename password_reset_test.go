@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/mailer"
+)
+
+// chanMailer hands sent messages to the test.
+type chanMailer chan mailer.Message
+
+func (c chanMailer) Send(_ context.Context, msg mailer.Message) error {
+	c <- msg
+	return nil
+}
+
+func userWithEmail(userID uuid.UUID, email string) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		if args[0].Value != email {
+			return fakeResult{cols: userByEmailCols}
+		}
+		now := time.Now()
+		return fakeRow(userByEmailCols, userID.String(), email, "Jane", "Doe", true, now, now, "hash", now)
+	}
+}
+
+func formRequest(path string, form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://website.com")
+	return req
+}
+
+func TestHandleForgotPassword_KnownEmail_SendsLink(t *testing.T) {
+	userID := uuid.New()
+	var storedHash driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByEmail": userWithEmail(userID, "jane@example.com"),
+		"CreatePasswordReset": func(args []driver.NamedValue) fakeResult {
+			storedHash = args[1].Value
+			return fakeResult{affected: 1}
+		},
+	})
+	sent := make(chanMailer, 1)
+	cfg.Mailer = sent
+
+	rr := httptest.NewRecorder()
+	cfg.HandleForgotPassword(rr, formRequest("/forgot-password", url.Values{"email": {"jane@example.com"}}))
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	var msg mailer.Message
+	select {
+	case msg = <-sent:
+	case <-time.After(time.Second):
+		t.Fatalf("no email sent")
+	}
+	if msg.To != "jane@example.com" {
+		t.Fatalf("email sent to %q", msg.To)
+	}
+
+	i := strings.Index(msg.Body, "token=")
+	if i < 0 {
+		t.Fatalf("email has no reset link:\n%s", msg.Body)
+	}
+	token := strings.Fields(msg.Body[i+len("token="):])[0]
+	if auth.HashToken(token) != storedHash {
+		t.Fatalf("only the hash of the emailed token should be stored")
+	}
+}
+
+func TestHandleForgotPassword_UnknownEmail_SameResponse(t *testing.T) {
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserByEmail": userWithEmail(uuid.New(), "jane@example.com"),
+	})
+	cfg.Mailer = make(chanMailer)
+
+	rr := httptest.NewRecorder()
+	cfg.HandleForgotPassword(rr, formRequest("/forgot-password", url.Values{"email": {"nobody@example.com"}}))
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/login.html?reset=requested" {
+		t.Fatalf("want the usual redirect, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if fdb.called("CreatePasswordReset") {
+		t.Fatalf("no reset should be created for unknown addresses")
+	}
+}
+
+var passwordResetCols = []string{"id", "user_id", "token_hash", "created_at", "expires_at", "used_at"}
+
+func passwordReset(userID uuid.UUID, token string, expiresAt time.Time, usedAt driver.Value) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		if args[0].Value != auth.HashToken(token) {
+			return fakeResult{cols: passwordResetCols}
+		}
+		return fakeRow(passwordResetCols, uuid.NewString(), userID.String(), auth.HashToken(token), time.Now(), expiresAt, usedAt)
+	}
+}
+
+func TestHandleResetPassword_ValidToken(t *testing.T) {
+	userID := uuid.New()
+	var revokedFor driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetPasswordResetForUpdate": passwordReset(userID, "tok", time.Now().Add(time.Hour), nil),
+		"UpdateUserPassword":        func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"UsePasswordResets":         func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+		"RevokeUserSessions": func(args []driver.NamedValue) fakeResult {
+			revokedFor = args[0].Value
+			return fakeResult{affected: 2}
+		},
+		"RevokeUserPersonalAccessTokens": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 1} },
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleResetPassword(rr, formRequest("/reset-password", url.Values{"token": {"tok"}, "password": {"new password"}}))
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if !fdb.called("UpdateUserPassword") || !fdb.called("UsePasswordResets") {
+		t.Fatalf("password should be changed and the link used up")
+	}
+	if revokedFor != userID.String() {
+		t.Fatalf("all sessions of the user should be revoked, got %v", revokedFor)
+	}
+	if !fdb.called("RevokeUserPersonalAccessTokens") {
+		t.Fatalf("access tokens of the user should be revoked")
+	}
+}
+
+func TestHandleResetPassword_UnusableToken(t *testing.T) {
+	cases := map[string]fakeHandler{
+		"unknown": passwordReset(uuid.New(), "other", time.Now().Add(time.Hour), nil),
+		"used":    passwordReset(uuid.New(), "tok", time.Now().Add(time.Hour), time.Now()),
+		"expired": passwordReset(uuid.New(), "tok", time.Now().Add(-time.Minute), nil),
+	}
+
+	for name, handler := range cases {
+		cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+			"GetPasswordResetForUpdate": handler,
+		})
+
+		rr := httptest.NewRecorder()
+		cfg.HandleResetPassword(rr, formRequest("/reset-password", url.Values{"token": {"tok"}, "password": {"new password"}}))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s token: want 400, got %d", name, rr.Code)
+		}
+		if fdb.called("UpdateUserPassword") {
+			t.Fatalf("%s token: password must not change", name)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+	"github.com/henrique-godinho/smart-list/internal/database"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	// a rotated refresh token replayed this soon is most likely a second tab
+	// racing the first one, not a stolen token
+	refreshReuseGrace = 30 * time.Second
+)
+
+var (
+	errSessionInvalid = errors.New("session expired or revoked")
+	errRefreshRaced   = errors.New("session was just refreshed by another request")
+)
+
+type sessionKey struct{}
+
+func withSessionID(ctx context.Context, sessionID uuid.UUID) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sessionID)
+}
+
+// sessionIDFromContext returns the session of the current request, or
+// uuid.Nil for requests made with a personal access token.
+func sessionIDFromContext(ctx context.Context) uuid.UUID {
+	sessionID, _ := ctx.Value(sessionKey{}).(uuid.UUID)
+	return sessionID
+}
+
+func (cfg *apiConfig) setSessionCookies(w http.ResponseWriter, session database.Session, refreshToken string) error {
+	jwt, err := cfg.Keys.MakeSessionJWT(session.UserID, session.ID, accessTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, auth.MakeAuthCookie(jwt, accessTokenTTL, cfg.CookieSecure))
+	http.SetCookie(w, auth.MakeRefreshCookie(refreshToken, time.Until(session.ExpiresAt), cfg.CookieSecure))
+	return nil
+}
+
+func (cfg *apiConfig) clearSessionCookies(w http.ResponseWriter) {
+	http.SetCookie(w, auth.ClearAuthCookie(cfg.CookieSecure))
+	http.SetCookie(w, auth.ClearRefreshCookie(cfg.CookieSecure))
+}
+
+const maxUserAgentLen = 512
+
+// clientIP is the address the request came from, without the port.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func clientUserAgent(req *http.Request) string {
+	ua := strings.ToValidUTF8(req.UserAgent(), "")
+	if len(ua) > maxUserAgentLen {
+		ua = strings.ToValidUTF8(ua[:maxUserAgentLen], "")
+	}
+	return ua
+}
+
+// startSession records a new login and hands out its first token pair.
+func (cfg *apiConfig) startSession(w http.ResponseWriter, req *http.Request, userID uuid.UUID) error {
+	refreshToken, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+
+	session, err := cfg.Db.CreateSession(req.Context(), database.CreateSessionParams{
+		UserID:           userID,
+		RefreshTokenHash: auth.HashToken(refreshToken),
+		ExpiresAt:        time.Now().Add(refreshTokenTTL),
+		UserAgent:        clientUserAgent(req),
+		Ip:               clientIP(req),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.setSessionCookies(w, session, refreshToken)
+}
+
+// refreshSession trades the refresh token cookie for a new access and refresh
+// token pair. Every refresh token works once: presenting one that was already
+// rotated means it leaked, and the whole session is revoked.
+func (cfg *apiConfig) refreshSession(w http.ResponseWriter, req *http.Request) (database.Session, error) {
+	refreshToken, err := auth.GetRefreshCookie(req)
+	if err != nil || refreshToken == "" {
+		return database.Session{}, errSessionInvalid
+	}
+	hash := auth.HashToken(refreshToken)
+
+	tx, err := cfg.Sql.Begin()
+	if err != nil {
+		return database.Session{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	session, err := qtx.GetSessionForRefresh(req.Context(), hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Session{}, errSessionInvalid
+		}
+		return database.Session{}, err
+	}
+
+	now := time.Now()
+	if session.RevokedAt.Valid || now.After(session.ExpiresAt) {
+		return database.Session{}, errSessionInvalid
+	}
+
+	if session.RefreshTokenHash != hash {
+		if session.RotatedAt.Valid && now.Sub(session.RotatedAt.Time) < refreshReuseGrace {
+			return database.Session{}, errRefreshRaced
+		}
+
+		if _, err := qtx.RevokeSession(req.Context(), database.RevokeSessionParams{
+			ID:     session.ID,
+			UserID: session.UserID,
+		}); err != nil {
+			return database.Session{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return database.Session{}, err
+		}
+		log.Printf("sessions: refresh token of session %s was reused, session revoked", session.ID)
+		return database.Session{}, errSessionInvalid
+	}
+
+	next, err := auth.MakeToken()
+	if err != nil {
+		return database.Session{}, err
+	}
+
+	session, err = qtx.RotateSession(req.Context(), database.RotateSessionParams{
+		ID:               session.ID,
+		RefreshTokenHash: auth.HashToken(next),
+		ExpiresAt:        now.Add(refreshTokenTTL),
+		UserAgent:        clientUserAgent(req),
+		Ip:               clientIP(req),
+	})
+	if err != nil {
+		return database.Session{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.Session{}, err
+	}
+
+	if err := cfg.setSessionCookies(w, session, next); err != nil {
+		return database.Session{}, err
+	}
+
+	return session, nil
+}
+
+// HandleRefresh lets the client renew its access token ahead of time; the
+// auth middleware otherwise does it on the first request after expiry.
+func (cfg *apiConfig) HandleRefresh(w http.ResponseWriter, req *http.Request) {
+	if err := auth.CheckOrigin(cfg.Origin, req); err != nil {
+		respondWithError(w, http.StatusForbidden, "invalid request", nil)
+		return
+	}
+
+	_, err := cfg.refreshSession(w, req)
+	if err != nil {
+		if errors.Is(err, errRefreshRaced) {
+			respondWithError(w, http.StatusConflict, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, errSessionInvalid) {
+			cfg.clearSessionCookies(w)
+			respondWithError(w, http.StatusUnauthorized, err.Error(), nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to refresh session", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleLogoutAll signs the user out on every device.
+func (cfg *apiConfig) HandleLogoutAll(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	if _, err := cfg.Db.RevokeUserSessions(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to sign out", err)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "session.revoke_all", userID.String(), "success")
+
+	cfg.clearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkSession makes sure the session behind an access token hasn't been
+// revoked, and notes that it was just used.
+func (cfg *apiConfig) checkSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	n, err := cfg.Db.TouchSession(ctx, database.TouchSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errSessionInvalid
+	}
+	return nil
+}
+
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func (cfg *apiConfig) HandleGetSessions(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	sessions, err := cfg.Db.GetUserSessions(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to load sessions", err)
+		return
+	}
+
+	current := sessionIDFromContext(req.Context())
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, sessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.Ip,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == current,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// HandleRevokeSession signs the user out on one device. Revoking the current
+// session also clears its cookies.
+func (cfg *apiConfig) HandleRevokeSession(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	sessionID, err := uuid.Parse(req.PathValue("session_id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "session not found", nil)
+		return
+	}
+
+	n, err := cfg.Db.RevokeSession(req.Context(), database.RevokeSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke session", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "session not found", nil)
+		return
+	}
+	cfg.audit(req.Context(), req, userID, "session.revoke", sessionID.String(), "success")
+
+	if sessionID == sessionIDFromContext(req.Context()) {
+		cfg.clearSessionCookies(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henrique-godinho/smart-list/internal/auth"
+)
+
+var sessionCols = []string{"id", "user_id", "refresh_token_hash", "previous_token_hash", "created_at", "rotated_at", "expires_at", "revoked_at", "user_agent", "ip", "last_seen_at"}
+
+// sessionWithToken simulates GetSessionForRefresh for a live session whose
+// current refresh token is current and whose previous one is previous.
+func sessionWithToken(sessionID, userID uuid.UUID, current, previous string, rotatedAt time.Time) fakeHandler {
+	return func(args []driver.NamedValue) fakeResult {
+		hash := args[0].Value
+		if hash != auth.HashToken(current) && hash != auth.HashToken(previous) {
+			return fakeResult{cols: sessionCols}
+		}
+		now := time.Now()
+		return fakeRow(sessionCols, sessionID.String(), userID.String(), auth.HashToken(current), auth.HashToken(previous), now, rotatedAt, now.Add(time.Hour), nil, "Firefox", "10.0.0.1", now)
+	}
+}
+
+func refreshRequest(token string) *http.Request {
+	req := httptest.NewRequest("POST", "/auth/refresh", nil)
+	req.Header.Set("Origin", "http://website.com")
+	req.AddCookie(&http.Cookie{Name: "sl_refresh", Value: token})
+	return req
+}
+
+func responseCookie(rr *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestHandleRefresh_RotatesToken(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	var rotatedTo driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetSessionForRefresh": sessionWithToken(sessionID, userID, "current", "old", time.Now().Add(-time.Hour)),
+		"RotateSession": func(args []driver.NamedValue) fakeResult {
+			rotatedTo = args[1].Value
+			now := time.Now()
+			return fakeRow(sessionCols, sessionID.String(), userID.String(), args[1].Value, auth.HashToken("current"), now, now, args[2].Value, nil, args[3].Value, args[4].Value, now)
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleRefresh(rr, refreshRequest("current"))
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("status: want 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	refresh := responseCookie(rr, "sl_refresh")
+	if refresh == nil || refresh.Value == "current" || auth.HashToken(refresh.Value) != rotatedTo {
+		t.Fatalf("a new refresh token should be stored and sent, got %+v", refresh)
+	}
+
+	access := responseCookie(rr, "sl_auth")
+	if access == nil {
+		t.Fatalf("a new access token should be sent")
+	}
+	gotUser, gotSession, err := auth.ValidateSessionJWT(access.Value, testJWTKey)
+	if err != nil || gotUser != userID || gotSession != sessionID {
+		t.Fatalf("access token: got %s/%s, %v", gotUser, gotSession, err)
+	}
+}
+
+func TestHandleRefresh_ReusedToken_RevokesSession(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	var revoked driver.Value
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetSessionForRefresh": sessionWithToken(sessionID, userID, "current", "old", time.Now().Add(-time.Hour)),
+		"RevokeSession": func(args []driver.NamedValue) fakeResult {
+			revoked = args[0].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleRefresh(rr, refreshRequest("old"))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("status: want 401, got %d", rr.Code)
+	}
+	if revoked != sessionID.String() {
+		t.Fatalf("session should be revoked, got %v", revoked)
+	}
+	if fdb.called("RotateSession") {
+		t.Fatalf("a reused token must not be rotated")
+	}
+	if c := responseCookie(rr, "sl_refresh"); c == nil || c.MaxAge >= 0 {
+		t.Fatalf("refresh cookie should be cleared, got %+v", c)
+	}
+}
+
+func TestHandleRefresh_ConcurrentRefresh_KeepsSession(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"GetSessionForRefresh": sessionWithToken(sessionID, userID, "current", "old", time.Now().Add(-time.Second)),
+	})
+
+	rr := httptest.NewRecorder()
+	cfg.HandleRefresh(rr, refreshRequest("old"))
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("status: want 409, got %d", rr.Code)
+	}
+	if fdb.called("RevokeSession") || responseCookie(rr, "sl_refresh") != nil {
+		t.Fatalf("a token rotated a moment ago by another request must not end the session")
+	}
+}
+
+func TestMiddleware_ExpiredJWT_RefreshesSession(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetSessionForRefresh": sessionWithToken(sessionID, userID, "current", "old", time.Now().Add(-time.Hour)),
+		"RotateSession": func(args []driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeRow(sessionCols, sessionID.String(), userID.String(), args[1].Value, auth.HashToken("current"), now, now, args[2].Value, nil, args[3].Value, args[4].Value, now)
+		},
+	})
+
+	var gotUser, gotSession uuid.UUID
+	h := cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+		gotUser, gotSession = userID, sessionIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/main", nil)
+	req.AddCookie(&http.Cookie{Name: "sl_auth", Value: makeToken(t, userID, testJWTKey, -time.Minute)})
+	req.AddCookie(&http.Cookie{Name: "sl_refresh", Value: "current"})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d", rr.Code)
+	}
+	if gotUser != userID || gotSession != sessionID {
+		t.Fatalf("want %s/%s, got %s/%s", userID, sessionID, gotUser, gotSession)
+	}
+	if responseCookie(rr, "sl_auth") == nil {
+		t.Fatalf("a fresh access token should be sent")
+	}
+}
+
+func TestHandleLogout_RevokesSession(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	var scopedTo driver.Value
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"RevokeSession": func(args []driver.NamedValue) fakeResult {
+			scopedTo = args[1].Value
+			return fakeResult{affected: 1}
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/logout", nil)
+	req = req.WithContext(withSessionID(req.Context(), sessionID))
+	rr := httptest.NewRecorder()
+	cfg.HandleLogout(rr, req, userID)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d", rr.Code)
+	}
+	if scopedTo != userID.String() {
+		t.Fatalf("revocation must be scoped to the caller, got %v", scopedTo)
+	}
+	if c := responseCookie(rr, "sl_refresh"); c == nil || c.MaxAge >= 0 {
+		t.Fatalf("refresh cookie should be cleared, got %+v", c)
+	}
+}
+
+func TestMiddleware_RevokedSession_Redirects(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	cfg, fdb := newFakeConfig(t, map[string]fakeHandler{
+		"TouchSession": func([]driver.NamedValue) fakeResult { return fakeResult{affected: 0} },
+	})
+
+	h := cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request, _ uuid.UUID) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	token, err := auth.MakeSessionJWT(userID, sessionID, testJWTKey, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/main", nil)
+	req.AddCookie(&http.Cookie{Name: "sl_auth", Value: token})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("status: want 303, got %d", rr.Code)
+	}
+	if !fdb.called("TouchSession") {
+		t.Fatalf("the session behind the token should be checked")
+	}
+}
+
+func TestHandleGetSessions_MarksCurrent(t *testing.T) {
+	current, other, userID := uuid.New(), uuid.New(), uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"GetUserSessions": func([]driver.NamedValue) fakeResult {
+			now := time.Now()
+			return fakeResult{cols: sessionCols, rows: [][]driver.Value{
+				{current.String(), userID.String(), "h1", nil, now, nil, now.Add(time.Hour), nil, "Firefox", "10.0.0.1", now},
+				{other.String(), userID.String(), "h2", nil, now, nil, now.Add(time.Hour), nil, "Safari", "10.0.0.2", now},
+			}}
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/api/sessions", nil)
+	req = req.WithContext(withSessionID(req.Context(), current))
+	rr := httptest.NewRecorder()
+	cfg.HandleGetSessions(rr, req, userID)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: want 200, got %d", rr.Code)
+	}
+	var got []sessionResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got) != 2 || !got[0].Current || got[1].Current || got[1].UserAgent != "Safari" {
+		t.Fatalf("unexpected sessions: %+v", got)
+	}
+	if strings.Contains(rr.Body.String(), "h1") {
+		t.Fatalf("token hashes must not be exposed: %s", rr.Body.String())
+	}
+}
+
+func TestHandleRevokeSession(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	cfg, _ := newFakeConfig(t, map[string]fakeHandler{
+		"RevokeSession": func(args []driver.NamedValue) fakeResult {
+			if args[0].Value != sessionID.String() || args[1].Value != userID.String() {
+				return fakeResult{affected: 0}
+			}
+			return fakeResult{affected: 1}
+		},
+	})
+
+	revoke := func(id string, caller uuid.UUID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/api/sessions/"+id, nil)
+		req.SetPathValue("session_id", id)
+		rr := httptest.NewRecorder()
+		cfg.HandleRevokeSession(rr, req, caller)
+		return rr
+	}
+
+	if rr := revoke(sessionID.String(), uuid.New()); rr.Code != http.StatusNotFound {
+		t.Fatalf("another user's session: want 404, got %d", rr.Code)
+	}
+	if rr := revoke("nope", userID); rr.Code != http.StatusNotFound {
+		t.Fatalf("malformed id: want 404, got %d", rr.Code)
+	}
+	rr := revoke(sessionID.String(), userID)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("own session: want 204, got %d", rr.Code)
+	}
+	if responseCookie(rr, "sl_auth") != nil {
+		t.Fatalf("revoking another device must keep this device signed in")
+	}
+}
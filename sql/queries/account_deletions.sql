@@ -0,0 +1,22 @@
+-- name: ScheduleAccountDeletion :exec
+INSERT INTO account_deletions (user_id, token_hash, delete_after)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET token_hash = EXCLUDED.token_hash,
+    requested_at = NOW(),
+    delete_after = EXCLUDED.delete_after;
+
+-- name: GetAccountDeletionForUpdate :one
+SELECT * FROM account_deletions
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: CancelAccountDeletion :exec
+DELETE FROM account_deletions
+WHERE user_id = $1;
+
+-- name: GetDueAccountDeletions :many
+SELECT user_id FROM account_deletions
+WHERE delete_after <= @now
+ORDER BY delete_after
+LIMIT @max_users;
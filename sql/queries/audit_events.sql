@@ -0,0 +1,34 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (actor_id, action, target, ip, user_agent, outcome)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetUserAuditEvents :many
+SELECT * FROM audit_events
+WHERE actor_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetRecentSecurityActivity :many
+SELECT * FROM audit_events
+WHERE actor_id = @user_id::uuid
+   OR target = (@user_id::uuid)::text
+ORDER BY id DESC
+LIMIT @max_events;
+
+-- name: SearchAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id))
+  AND (sqlc.narg(target)::text IS NULL OR target = sqlc.narg(target))
+  AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action) OR action LIKE sqlc.narg(action) || '.%')
+  AND (sqlc.narg(outcome)::text IS NULL OR outcome = sqlc.narg(outcome))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamptz IS NULL OR created_at < sqlc.narg(until))
+  AND (sqlc.narg(before_id)::bigint IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT @max_events;
+
+-- name: RedactUserAuditEvents :exec
+UPDATE audit_events
+SET ip = '',
+    user_agent = '',
+    target = CASE WHEN target LIKE '%@%' THEN '' ELSE target END
+WHERE actor_id = $1;
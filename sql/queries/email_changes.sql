@@ -0,0 +1,19 @@
+-- name: CreateEmailChange :exec
+INSERT INTO email_changes (user_id, new_email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetEmailChangeForUpdate :one
+SELECT * FROM email_changes
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: GetPendingEmailChange :one
+SELECT * FROM email_changes
+WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: UseEmailChanges :exec
+UPDATE email_changes
+SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL;
@@ -0,0 +1,2 @@
+-- name: NotifyListEvent :exec
+SELECT pg_notify('list_events', @payload::text);
@@ -5,23 +5,43 @@ SELECT
   l.frequency,
   l.target_date,
   l.updated_at  AS list_updated_at,
+  l.version     AS list_version,
+  m.role        AS list_role,
+  l.spawn_copy,
   li.id         AS item_id,
   li.name       AS item_name,
   li.qty,
   li.unit,
   li.price,
   li.created_at AS item_created_at,
-  li.updated_at AS item_updated_at
+  li.updated_at AS item_updated_at,
+  li.checked,
+  li.checked_at,
+  li.checked_by
 FROM list l
+JOIN list_members m ON m.list_id = l.id AND m.user_id = $1 AND m.status = 'accepted'
 LEFT JOIN list_items li ON li.list_id = l.id
-WHERE l.user_id = $1
-ORDER BY l.updated_at DESC;
+ORDER BY l.updated_at DESC, l.id, li.id;
 
 
+-- name: GetListAccess :one
+SELECT l.id, l.user_id, l.name, l.frequency, l.target_date, l.created_at, l.updated_at, l.version, l.spawn_copy,
+  COALESCE((
+    SELECT m.role FROM list_members m
+    WHERE m.list_id = l.id AND m.user_id = @user_id::uuid AND m.status = 'accepted'
+  ), '')::text AS role
+FROM list l
+WHERE l.id = @list_id::uuid;
+
 -- name: UpdateUserList :exec
 INSERT INTO list_items (list_id, name, qty, updated_at)
 SELECT @list_id::uuid, x.name, x.qty, NOW()
 FROM jsonb_to_recordset(@items::jsonb) AS x(name text, qty smallint)
+WHERE EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = @list_id::uuid AND m.user_id = @user_id::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
 ON CONFLICT (list_id, name) DO UPDATE
 SET qty = EXCLUDED.qty,
     name = EXCLUDED.name,
@@ -34,21 +54,132 @@ AND NOT EXISTS (
 SELECT 1
 FROM jsonb_to_recordset(@items::jsonb) AS x(name text)
 WHERE lower(trim(x.name)) = lower(trim(li.name))
+)
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = @user_id::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
 );
 
 -- name: GetUpdatedListById :many
-SELECT li.id as item_id, li.list_id, li.name, li.qty, li.unit, li.price, li.updated_at, l.name as list_name, l.frequency, l.target_date, l.updated_at as list_updated_at
+SELECT li.id as item_id, li.list_id, li.name, li.qty, li.unit, li.price, li.updated_at, li.checked, l.name as list_name, l.frequency, l.target_date, l.updated_at as list_updated_at
 from list_items li
 join list l on l.id = li.list_id
 where list_id = $1
 order by li.id;
 
 -- name: CreateNewList :one
-INSERT INtO list (user_id, name,  frequency, target_date)
+INSERT INtO list (user_id, name,  frequency, target_date, spawn_copy, recurrence_start)
 VALUES (
   $1,
   $2,
   $3,
+  $4,
+  $5,
   $4
 )
-RETURNING id, name, frequency, target_date;
+RETURNING id, name, frequency, target_date, version, spawn_copy;
+
+-- name: GetListItems :many
+SELECT * FROM list_items
+WHERE list_id = $1
+ORDER BY id;
+
+-- name: UpdateListMetadata :one
+UPDATE list
+SET name = COALESCE(sqlc.narg('name')::text, name),
+    frequency = CASE WHEN @set_frequency::boolean THEN sqlc.narg('frequency')::text ELSE frequency END,
+    target_date = CASE WHEN @set_target_date::boolean THEN sqlc.narg('target_date')::date ELSE target_date END,
+    spawn_copy = COALESCE(sqlc.narg('spawn_copy')::boolean, spawn_copy),
+    recurrence_start = CASE
+      WHEN @set_frequency::boolean OR @set_target_date::boolean
+      THEN CASE WHEN @set_target_date::boolean THEN sqlc.narg('target_date')::date ELSE target_date END
+      ELSE recurrence_start
+    END,
+    version = version + 1,
+    updated_at = NOW()
+WHERE id = @id AND user_id = @user_id AND version = @version
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM list
+WHERE id = $1 AND user_id = $2 AND version = $3;
+
+-- name: CreateListItem :one
+INSERT INTO list_items (list_id, name, qty, unit, price)
+SELECT @list_id::uuid, @name::text, sqlc.narg('qty')::smallint, sqlc.narg('unit')::text, sqlc.narg('price')::smallint
+WHERE EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = @list_id::uuid AND m.user_id = @user_id::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING *;
+
+-- name: UpdateListItem :one
+UPDATE list_items li
+SET name = COALESCE(sqlc.narg('name')::text, li.name),
+    qty = COALESCE(sqlc.narg('qty')::smallint, li.qty),
+    unit = CASE WHEN @set_unit::boolean THEN sqlc.narg('unit')::text ELSE li.unit END,
+    price = CASE WHEN @set_price::boolean THEN sqlc.narg('price')::smallint ELSE li.price END,
+    updated_at = NOW()
+WHERE li.id = @id AND li.list_id = @list_id
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = @user_id::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING *;
+
+-- name: DeleteListItem :one
+DELETE FROM list_items li
+WHERE li.id = @id AND li.list_id = @list_id
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = @user_id::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING *;
+
+-- name: BumpListVersion :one
+UPDATE list
+SET version = version + 1,
+    updated_at = NOW()
+WHERE id = $1 AND version = $2
+RETURNING version;
+
+-- name: SetListItemChecked :one
+UPDATE list_items li
+SET checked = COALESCE(sqlc.narg('checked')::boolean, NOT li.checked),
+    checked_at = CASE
+      WHEN NOT COALESCE(sqlc.narg('checked')::boolean, NOT li.checked) THEN NULL
+      WHEN li.checked THEN li.checked_at
+      ELSE NOW()
+    END,
+    checked_by = CASE
+      WHEN NOT COALESCE(sqlc.narg('checked')::boolean, NOT li.checked) THEN NULL
+      WHEN li.checked THEN li.checked_by
+      ELSE @user_id::uuid
+    END,
+    updated_at = NOW()
+WHERE li.id = @id AND li.list_id = @list_id
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = @user_id::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING *;
+
+-- name: DeleteCheckedListItems :many
+DELETE FROM list_items li
+WHERE li.list_id = @list_id AND li.checked
+AND EXISTS (
+  SELECT 1 FROM list_members m
+  WHERE m.list_id = li.list_id AND m.user_id = @user_id::uuid
+    AND m.status = 'accepted' AND m.role IN ('owner', 'editor')
+)
+RETURNING *;
+
+-- name: GetListItemForUpdate :one
+SELECT * FROM list_items
+WHERE id = $1 AND list_id = $2
+FOR UPDATE;
@@ -0,0 +1,40 @@
+-- name: AddListMember :one
+INSERT INTO list_members (list_id, user_id, role, status, invited_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetListMembers :many
+SELECT m.user_id, u.email, u.first_name, u.last_name, m.role, m.status, m.created_at
+FROM list_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.list_id = $1
+ORDER BY m.created_at, u.email;
+
+-- name: UpdateListMemberRole :one
+UPDATE list_members
+SET role = $1,
+    updated_at = NOW()
+WHERE list_id = $2 AND user_id = $3 AND role <> 'owner'
+RETURNING *;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2 AND role <> 'owner';
+
+-- name: GetPendingInvitations :many
+SELECT m.list_id, l.name AS list_name, m.role, m.created_at, inviter.email AS invited_by_email
+FROM list_members m
+JOIN list l ON l.id = m.list_id
+LEFT JOIN users inviter ON inviter.id = m.invited_by
+WHERE m.user_id = $1 AND m.status = 'pending'
+ORDER BY m.created_at DESC;
+
+-- name: AcceptListInvitation :execrows
+UPDATE list_members
+SET status = 'accepted',
+    updated_at = NOW()
+WHERE list_id = $1 AND user_id = $2 AND status = 'pending';
+
+-- name: DeclineListInvitation :execrows
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2 AND status = 'pending';
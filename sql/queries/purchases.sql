@@ -0,0 +1,29 @@
+-- name: RecordPurchase :exec
+INSERT INTO purchase_events (user_id, list_id, item_id, item_name, qty, unit, kind, purchased_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE(sqlc.narg('purchased_at')::timestamptz, NOW()));
+
+-- name: DiscardLatestPurchase :exec
+DELETE FROM purchase_events
+WHERE id = (
+  SELECT pe.id FROM purchase_events pe
+  WHERE pe.item_id = $1 AND pe.kind = 'checked'
+  ORDER BY pe.purchased_at DESC
+  LIMIT 1
+);
+
+-- name: GetPurchaseHistory :many
+SELECT item_name, purchased_at
+FROM purchase_events
+WHERE user_id = $1 AND purchased_at > $2
+ORDER BY purchased_at;
+
+-- name: GetOpenItemNames :many
+SELECT DISTINCT li.name
+FROM list_items li
+JOIN list_members m ON m.list_id = li.list_id
+WHERE m.user_id = $1 AND m.status = 'accepted' AND NOT li.checked;
+
+-- name: GetUserPurchaseEvents :many
+SELECT * FROM purchase_events
+WHERE user_id = $1
+ORDER BY purchased_at;
@@ -0,0 +1,55 @@
+-- name: GetDueRecurringLists :many
+SELECT id FROM list
+WHERE frequency IS NOT NULL AND target_date < @today::date
+ORDER BY target_date
+LIMIT @max_lists;
+
+-- name: LockDueList :one
+SELECT * FROM list
+WHERE id = @id AND frequency IS NOT NULL AND target_date < @today::date
+FOR UPDATE SKIP LOCKED;
+
+-- name: RollListForward :one
+UPDATE list
+SET target_date = @target_date,
+    version = version + 1,
+    updated_at = NOW()
+WHERE id = @id
+RETURNING version;
+
+-- name: UncheckListItems :exec
+UPDATE list_items
+SET checked = false,
+    checked_at = NULL,
+    checked_by = NULL,
+    updated_at = NOW()
+WHERE list_id = $1 AND checked;
+
+-- name: EndListRecurrence :one
+UPDATE list
+SET frequency = NULL,
+    spawn_copy = false,
+    version = version + 1,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING version;
+
+-- name: CopyRecurringList :one
+INSERT INTO list (user_id, name, frequency, target_date, spawn_copy, recurrence_start)
+SELECT user_id, name, frequency, @target_date, spawn_copy, recurrence_start
+FROM list
+WHERE id = @id
+RETURNING *;
+
+-- name: CopyListItems :exec
+INSERT INTO list_items (list_id, name, qty, unit, price)
+SELECT @new_list_id::uuid, name, qty, unit, price
+FROM list_items
+WHERE list_id = @list_id
+ORDER BY id;
+
+-- name: CopyListMembers :exec
+INSERT INTO list_members (list_id, user_id, role, status, invited_by)
+SELECT @new_list_id::uuid, user_id, role, status, invited_by
+FROM list_members
+WHERE list_id = @list_id AND status = 'accepted';
@@ -0,0 +1,46 @@
+-- name: CreateSession :one
+INSERT INTO sessions (user_id, refresh_token_hash, expires_at, user_agent, ip)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetSessionForRefresh :one
+SELECT * FROM sessions
+WHERE refresh_token_hash = @token_hash OR previous_token_hash = @token_hash
+FOR UPDATE;
+
+-- name: RotateSession :one
+UPDATE sessions
+SET previous_token_hash = refresh_token_hash,
+    refresh_token_hash = $2,
+    rotated_at = NOW(),
+    expires_at = $3,
+    user_agent = $4,
+    ip = $5,
+    last_seen_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL
+RETURNING *;
+
+-- name: TouchSession :execrows
+UPDATE sessions
+SET last_seen_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: GetUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY last_seen_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :execrows
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :execrows
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL;
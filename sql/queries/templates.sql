@@ -0,0 +1,63 @@
+-- name: CreateTemplate :one
+INSERT INTO list_templates (user_id, name)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: AddTemplateItems :exec
+INSERT INTO list_template_items (template_id, name, qty, unit)
+SELECT @template_id::uuid, x.name, x.qty, x.unit
+FROM jsonb_to_recordset(@items::jsonb) AS x(name text, qty smallint, unit text);
+
+-- name: CopyListItemsToTemplate :exec
+INSERT INTO list_template_items (template_id, name, qty, unit)
+SELECT @template_id::uuid, name, qty, unit
+FROM list_items
+WHERE list_id = @list_id
+ORDER BY id;
+
+-- name: GetTemplatesByUserId :many
+SELECT
+  t.id          AS template_id,
+  t.name        AS template_name,
+  t.created_at  AS template_created_at,
+  t.updated_at  AS template_updated_at,
+  ti.id         AS item_id,
+  ti.name       AS item_name,
+  ti.qty,
+  ti.unit
+FROM list_templates t
+LEFT JOIN list_template_items ti ON ti.template_id = t.id
+WHERE t.user_id = $1
+ORDER BY t.name, t.id, ti.id;
+
+-- name: GetTemplate :one
+SELECT * FROM list_templates
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetTemplateItems :many
+SELECT * FROM list_template_items
+WHERE template_id = $1
+ORDER BY id;
+
+-- name: RenameTemplate :one
+UPDATE list_templates
+SET name = COALESCE(sqlc.narg('name')::text, name),
+    updated_at = NOW()
+WHERE id = @id AND user_id = @user_id
+RETURNING *;
+
+-- name: DeleteTemplateItems :exec
+DELETE FROM list_template_items
+WHERE template_id = $1;
+
+-- name: DeleteTemplate :execrows
+DELETE FROM list_templates
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateListItemsFromTemplate :exec
+INSERT INTO list_items (list_id, name, qty, unit)
+SELECT @list_id::uuid, ti.name, ti.qty, ti.unit
+FROM list_template_items ti
+JOIN list_templates t ON t.id = ti.template_id
+WHERE t.id = @template_id AND t.user_id = @user_id
+ORDER BY ti.id;
@@ -0,0 +1,29 @@
+-- name: GetThrottle :one
+SELECT * FROM throttles
+WHERE key = $1;
+
+-- name: EnsureThrottle :exec
+INSERT INTO throttles (key)
+VALUES ($1)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: GetThrottleForUpdate :one
+SELECT * FROM throttles
+WHERE key = $1
+FOR UPDATE;
+
+-- name: SaveThrottle :exec
+UPDATE throttles
+SET failures = $2,
+    last_failure_at = $3,
+    blocked_until = $4,
+    expires_at = $5
+WHERE key = $1;
+
+-- name: DeleteThrottle :exec
+DELETE FROM throttles
+WHERE key = $1;
+
+-- name: DeleteExpiredThrottles :exec
+DELETE FROM throttles
+WHERE expires_at < $1;
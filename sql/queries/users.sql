@@ -7,7 +7,86 @@ INSERT INTO users (
 RETURNING *;
 
 -- name: GetUserByEmail :one
-SELECT id, email, first_name, last_name, is_active, created_at, updated_at, hashed_password
+SELECT id, email, first_name, last_name, is_active, created_at, updated_at, hashed_password, email_verified_at
 FROM users
 WHERE email = $1;
 
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetEmailVerifiedAt :one
+SELECT email_verified_at
+FROM users
+WHERE id = $1;
+
+-- name: SetEmailVerified :execrows
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1
+  AND email = $2;
+
+-- name: ClaimVerificationSend :execrows
+UPDATE users
+SET verification_sent_at = NOW()
+WHERE id = $1
+  AND email_verified_at IS NULL
+  AND (verification_sent_at IS NULL OR verification_sent_at < $2);
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: RehashUserPassword :execrows
+UPDATE users
+SET hashed_password = @new_hash
+WHERE id = @id
+  AND hashed_password = @old_hash;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET first_name = $2,
+    last_name = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserEmail :exec
+UPDATE users
+SET email = $2,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserActive :exec
+UPDATE users
+SET is_active = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteInactiveUser :execrows
+DELETE FROM users
+WHERE id = $1 AND NOT is_active;
+
+-- name: GetActiveUserRole :one
+SELECT role FROM users
+WHERE id = $1 AND is_active;
+
+-- name: SetUserRole :execrows
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE @query::text = ''
+   OR email ILIKE '%' || @query || '%'
+   OR first_name ILIKE '%' || @query || '%'
+   OR last_name ILIKE '%' || @query || '%'
+ORDER BY created_at DESC
+LIMIT @max_users OFFSET @skip;
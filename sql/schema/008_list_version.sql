@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE list ADD COLUMN version bigint NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE list DROP COLUMN version;
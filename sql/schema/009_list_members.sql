@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE list_members (
+    list_id UUID not null references list(id) on delete cascade,
+    user_id UUID not null references users(id) on delete cascade,
+    role text not null check (role in ('owner', 'editor', 'viewer')),
+    status text not null DEFAULT 'pending' check (status in ('pending', 'accepted')),
+    invited_by UUID references users(id) on delete set null,
+    created_at timestamptz not null DEFAULT now(),
+    updated_at timestamptz not null DEFAULT now(),
+    primary key (list_id, user_id)
+);
+
+CREATE INDEX idx_list_members_user_id ON list_members(user_id);
+
+INSERT INTO list_members (list_id, user_id, role, status)
+SELECT id, user_id, 'owner', 'accepted' FROM list;
+
+-- +goose Down
+DROP TABLE list_members;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE list_items
+    ADD COLUMN checked boolean NOT NULL DEFAULT false,
+    ADD COLUMN checked_at timestamptz,
+    ADD COLUMN checked_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE list_items
+    DROP COLUMN checked_by,
+    DROP COLUMN checked_at,
+    DROP COLUMN checked;
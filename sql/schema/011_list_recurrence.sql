@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE list ADD COLUMN spawn_copy boolean NOT NULL DEFAULT false;
+
+UPDATE list SET frequency = NULL WHERE trim(frequency) = '';
+UPDATE list SET frequency = 'biweekly' WHERE frequency = 'bi-weekly';
+
+CREATE INDEX idx_list_recurring_target_date ON list(target_date) WHERE frequency IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_list_recurring_target_date;
+ALTER TABLE list DROP COLUMN spawn_copy;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE list_templates (
+    id UUID primary key default gen_random_uuid(),
+    user_id UUID not null references users(id) on delete cascade,
+    name text not null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    unique (user_id, name)
+);
+
+CREATE TABLE list_template_items (
+    id bigserial primary key,
+    template_id UUID not null references list_templates(id) on delete cascade,
+    name text not null,
+    qty smallint,
+    unit text,
+    unique (template_id, name)
+);
+
+-- +goose Down
+DROP TABLE list_template_items;
+DROP TABLE list_templates;
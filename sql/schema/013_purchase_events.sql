@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE purchase_events (
+    id bigserial primary key,
+    user_id UUID not null references users(id) on delete cascade,
+    list_id UUID references list(id) on delete set null,
+    item_id bigint,
+    item_name text not null,
+    qty smallint,
+    unit text,
+    kind text not null check (kind in ('checked', 'removed')),
+    purchased_at timestamptz not null default now()
+);
+
+CREATE INDEX idx_purchase_events_user_purchased_at ON purchase_events(user_id, purchased_at);
+CREATE INDEX idx_purchase_events_item_id ON purchase_events(item_id);
+
+-- +goose Down
+DROP TABLE purchase_events;
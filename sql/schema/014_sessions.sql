@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE sessions (
+    id UUID primary key default gen_random_uuid(),
+    user_id UUID not null references users(id) on delete cascade,
+    refresh_token_hash text not null unique,
+    previous_token_hash text unique,
+    created_at timestamptz not null default now(),
+    rotated_at timestamptz,
+    expires_at timestamptz not null,
+    revoked_at timestamptz
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+
+-- +goose Down
+DROP TABLE sessions;
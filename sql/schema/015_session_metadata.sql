@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE sessions
+    ADD COLUMN user_agent text not null default '',
+    ADD COLUMN ip text not null default '',
+    ADD COLUMN last_seen_at timestamptz not null default now();
+
+-- +goose Down
+ALTER TABLE sessions
+    DROP COLUMN last_seen_at,
+    DROP COLUMN ip,
+    DROP COLUMN user_agent;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE password_resets (
+    id UUID primary key default gen_random_uuid(),
+    user_id UUID not null references users(id) on delete cascade,
+    token_hash text not null unique,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    used_at timestamptz
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
+
+-- +goose Down
+DROP TABLE password_resets;
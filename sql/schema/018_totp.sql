@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE user_totp (
+    user_id UUID primary key references users(id) on delete cascade,
+    secret text not null,
+    created_at timestamptz not null default now(),
+    confirmed_at timestamptz,
+    -- the time step of the last code accepted, so no code works twice
+    last_used_step bigint not null default 0
+);
+
+CREATE TABLE recovery_codes (
+    id UUID primary key default gen_random_uuid(),
+    user_id UUID not null references users(id) on delete cascade,
+    code_hash text not null,
+    created_at timestamptz not null default now(),
+    used_at timestamptz,
+    UNIQUE (user_id, code_hash)
+);
+
+-- +goose Down
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE throttles (
+    key text primary key,
+    failures integer not null default 0,
+    last_failure_at timestamptz not null default to_timestamp(0),
+    blocked_until timestamptz not null default to_timestamp(0),
+    expires_at timestamptz not null default now()
+);
+
+CREATE INDEX idx_throttles_expires_at ON throttles(expires_at);
+
+-- +goose Down
+DROP TABLE throttles;
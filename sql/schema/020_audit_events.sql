@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE audit_events (
+    id bigserial primary key,
+    created_at timestamptz not null default now(),
+    actor_id UUID references users(id) on delete set null,
+    action text not null,
+    target text not null default '',
+    ip text not null default '',
+    user_agent text not null default '',
+    outcome text not null
+);
+
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id, created_at);
+
+-- +goose Down
+DROP TABLE audit_events;
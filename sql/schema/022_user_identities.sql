@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE user_identities (
+    id UUID primary key default gen_random_uuid(),
+    user_id UUID not null references users(id) on delete cascade,
+    provider text not null,
+    subject text not null,
+    email citext not null,
+    created_at timestamptz not null default now(),
+    last_login_at timestamptz not null default now(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
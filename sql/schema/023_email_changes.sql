@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE email_changes (
+    id UUID primary key default gen_random_uuid(),
+    user_id UUID not null references users(id) on delete cascade,
+    new_email citext not null,
+    token_hash text not null unique,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    used_at timestamptz
+);
+
+CREATE INDEX idx_email_changes_user_id ON email_changes(user_id);
+
+-- +goose Down
+DROP TABLE email_changes;
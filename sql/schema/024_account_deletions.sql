@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE account_deletions (
+    user_id UUID primary key references users(id) on delete cascade,
+    token_hash text not null unique,
+    requested_at timestamptz not null default now(),
+    delete_after timestamptz not null
+);
+
+CREATE INDEX idx_account_deletions_delete_after ON account_deletions(delete_after);
+
+-- +goose Down
+DROP TABLE account_deletions;
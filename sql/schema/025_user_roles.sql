@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN role text not null default 'user' check (role in ('user', 'admin'));
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN role;
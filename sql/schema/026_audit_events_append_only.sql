@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE INDEX idx_audit_events_target ON audit_events(target, created_at);
+CREATE INDEX idx_audit_events_action ON audit_events(action, created_at);
+
+-- audit events are never changed or removed. The only exception is the
+-- actor being set to NULL when their account is deleted.
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        IF NEW.actor_id IS NULL
+            AND (NEW.id, NEW.created_at, NEW.action, NEW.target, NEW.ip, NEW.user_agent, NEW.outcome)
+                IS NOT DISTINCT FROM
+                (OLD.id, OLD.created_at, OLD.action, OLD.target, OLD.ip, OLD.user_agent, OLD.outcome)
+        THEN
+            RETURN NEW;
+        END IF;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_no_change
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TRIGGER audit_events_no_truncate ON audit_events;
+DROP TRIGGER audit_events_no_change ON audit_events;
+DROP FUNCTION audit_events_append_only();
+DROP INDEX idx_audit_events_action;
+DROP INDEX idx_audit_events_target;
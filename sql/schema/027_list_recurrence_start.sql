@@ -0,0 +1,9 @@
+-- +goose Up
+-- occurrences are counted from the date the recurrence was set up, so a
+-- list due on the 31st is not pulled to the 28th for good by one February
+ALTER TABLE list ADD COLUMN recurrence_start DATE;
+
+UPDATE list SET recurrence_start = target_date WHERE frequency IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE list DROP COLUMN recurrence_start;
@@ -0,0 +1,40 @@
+-- +goose Up
+-- deleting an account also blanks the address, user agent and any email
+-- address its own events were recorded with; the rest stays as it was
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        IF (NEW.id, NEW.created_at, NEW.action, NEW.outcome)
+                IS NOT DISTINCT FROM
+                (OLD.id, OLD.created_at, OLD.action, OLD.outcome)
+            AND (NEW.actor_id IS NULL OR NEW.actor_id = OLD.actor_id)
+            AND NEW.target IN (OLD.target, '')
+            AND NEW.ip IN (OLD.ip, '')
+            AND NEW.user_agent IN (OLD.user_agent, '')
+        THEN
+            RETURN NEW;
+        END IF;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        IF NEW.actor_id IS NULL
+            AND (NEW.id, NEW.created_at, NEW.action, NEW.target, NEW.ip, NEW.user_agent, NEW.outcome)
+                IS NOT DISTINCT FROM
+                (OLD.id, OLD.created_at, OLD.action, OLD.target, OLD.ip, OLD.user_agent, OLD.outcome)
+        THEN
+            RETURN NEW;
+        END IF;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+(function () {
+  const params   = new URLSearchParams(window.location.search);
+  const reset    = params.get('reset');
+  const verified = params.get('verified');
+  if (reset === 'requested') {
+    alert('If an account exists for that address, a reset link is on its way.');
+  } else if (reset === 'done') {
+    alert('Your password was changed. Please sign in again.');
+  } else if (params.get('created')) {
+    alert('Your account was created. We sent you a link to confirm your email address.');
+  } else if (params.get('verification') === 'sent') {
+    alert('If that account still needs confirming, a new link is on its way.');
+  } else if (verified === '1') {
+    alert('Your email address is confirmed. You can sign in now.');
+  } else if (verified === 'invalid') {
+    alert('This confirmation link is invalid or has expired.');
+  } else if (params.get('email') === 'changed') {
+    alert('Your email address was changed. Sign in with the new one from now on.');
+  } else if (params.get('email') === 'taken') {
+    alert('That email address is now used by another account, so yours was not changed.');
+  } else if (params.get('email') === 'invalid') {
+    alert('This link is invalid or has expired.');
+  } else if (params.get('restore') === '1') {
+    alert('Your account was restored. You can sign in again.');
+  } else if (params.get('restore') === 'invalid') {
+    alert('This link is invalid, or the account was already deleted.');
+  } else if (params.get('oidc') === 'unverified') {
+    alert('That account has no confirmed email address. Please confirm it with the provider or sign in with your password.');
+  } else if (params.get('oidc') === 'inactive') {
+    alert('This account is inactive.');
+  } else if (params.get('oidc') === 'failed') {
+    alert('Signing in with that provider failed. Please try again.');
+  }
+  if (window.location.search) {
+    history.replaceState(null, '', window.location.pathname);
+  }
+})();
+
+(function () {
+  const container = document.getElementById('oidcProviders');
+  fetch('/auth/oidc')
+    .then(response => response.ok ? response.json() : [])
+    .then(providers => {
+      providers.forEach(provider => {
+        const link = document.createElement('a');
+        link.href = `/auth/oidc/${encodeURIComponent(provider.id)}`;
+        link.textContent = `Sign in with ${provider.name}`;
+        container.appendChild(link);
+      });
+    })
+    .catch(() => {});
+})();
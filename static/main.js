@@ -180,7 +180,7 @@ function addItem(button) {
     input.value = '';
 }
 
-function addItemToDOM(listId, itemName, qty = 1, itemId = null) {
+function addItemToDOM(listId, itemName, qty = 1, itemId = null, checked = false) {
     // Skip adding items with empty or invalid names
     if (!itemName || itemName.trim() === '') {
         console.warn('Skipping item with empty name for list:', listId);
@@ -206,12 +206,13 @@ function addItemToDOM(listId, itemName, qty = 1, itemId = null) {
     const listItems = targetListCard.querySelector('.list-items');
     
     const itemDiv = document.createElement('div');
-    itemDiv.className = 'list-item';
+    itemDiv.className = checked ? 'list-item checked' : 'list-item';
     if (itemId) {
         itemDiv.setAttribute('data-item-id', itemId);
     }
     
     itemDiv.innerHTML = `
+        <input type="checkbox" class="item-check" onchange="toggleItemChecked(this)" ${checked ? 'checked' : ''} ${itemId ? '' : 'disabled title="Save the list first"'}>
         <div class="item-info">
             <span class="item-name">${itemName}</span>
             <div class="item-details">
@@ -389,12 +390,41 @@ document.getElementById('catalogBtn').addEventListener('click', () => {
     openCatalogFromMenu();
 });
 
+// Devices button in menu
+document.getElementById('sessionsBtn').addEventListener('click', () => {
+    closeMenu();
+    openSessions();
+});
+
+// Account button in menu
+document.getElementById('accountBtn').addEventListener('click', () => {
+    closeMenu();
+    openAccount();
+});
+
+// Two-factor authentication button in menu
+document.getElementById('mfaBtn').addEventListener('click', () => {
+    closeMenu();
+    openMfa();
+});
+
+// Access tokens button in menu
+document.getElementById('tokensBtn').addEventListener('click', () => {
+    closeMenu();
+    openTokens();
+});
+
 // Logout button in menu
 document.getElementById('logoutBtn').addEventListener('click', () => {
     closeMenu();
     logout();
 });
 
+document.getElementById('logoutAllBtn').addEventListener('click', () => {
+    closeMenu();
+    logoutEverywhere();
+});
+
 function closeMenu() {
     burgerMenu.classList.remove('active');
     menuOverlay.classList.remove('active');
@@ -421,6 +451,8 @@ function saveList(listId) {
     
     if (!saveButton) return;
     
+    const versionInput = saveButton.closest('.list-card').querySelector('.list-version');
+    
     // Update button state to saving
     saveButton.textContent = '💾 Saving...';
     saveButton.classList.add('saving');
@@ -430,13 +462,26 @@ function saveList(listId) {
     
     fetch(`/api/lists/${listId}`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        headers: {
+            'Content-Type': 'application/json',
+            'If-Match': `"${versionInput.value}"`
+        },
         body: JSON.stringify(list)
     })
     .then(response => {
+        if (response.status === 412) {
+            alert('This list was changed somewhere else. The page will reload with the latest version.');
+            clearListData(listId);
+            window.location.reload();
+            throw new Error('List was modified by someone else');
+        }
         if (!response.ok) {
             throw new Error(`HTTP error! status: ${response.status}`);
         }
+        const etag = response.headers.get('ETag');
+        if (etag) {
+            versionInput.value = etag.replace(/"/g, '');
+        }
         return response.json();
     })
     .then(serverResponse => {
@@ -460,6 +505,7 @@ function saveList(listId) {
         setListData(listId, transformedList);
         
         // Update button state to saved
+        saveButton.closest('.list-card').classList.remove('unsaved');
         saveButton.textContent = '💾 Saved';
         saveButton.classList.remove('saving');
         saveButton.classList.add('saved');
@@ -496,6 +542,7 @@ function markListAsUnsaved(listId) {
     listCards.forEach(card => {
         const cardListId = card.querySelector('.list-id').value;
         if (cardListId === listId) {
+            card.classList.add('unsaved');
             const saveButton = card.querySelector('.save-list-btn');
             if (saveButton && !saveButton.classList.contains('saving')) {
                 saveButton.style.backgroundColor = '#fbbf24';
@@ -510,10 +557,27 @@ function logout() {
     window.location.replace("/logout")
 }
 
+function logoutEverywhere() {
+    if (!confirm('Sign out on all your devices?')) return;
+
+    fetch('/api/sessions', { method: 'DELETE' })
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            window.location.replace('/?session=logout');
+        })
+        .catch(error => {
+            console.error('Failed to sign out everywhere:', error);
+            alert('Failed to sign out everywhere. Please try again.');
+        });
+}
+
 // Create New List functionality
 function createNewList() {
     const createListModal = document.getElementById('createListModal');
     createListModal.classList.add('active');
+    loadTemplateOptions();
 }
 
 function closeCreateListModal() {
@@ -524,15 +588,20 @@ function closeCreateListModal() {
     document.getElementById('listNameInput').value = '';
     document.getElementById('listFrequencySelect').value = '';
     document.getElementById('listTargetDateInput').value = '';
+    document.getElementById('listSpawnCopyInput').checked = false;
+    document.getElementById('listTemplateSelect').value = '';
+    document.getElementById('listNameInput').required = true;
 }
 
 function submitNewList() {
     const listName = document.getElementById('listNameInput').value.trim();
     const frequency = document.getElementById('listFrequencySelect').value;
     const targetDate = document.getElementById('listTargetDateInput').value;
+    const spawnCopy = document.getElementById('listSpawnCopyInput').checked;
+    const templateId = document.getElementById('listTemplateSelect').value;
     
-    // Validate required fields
-    if (!listName) {
+    // Validate required fields; lists from a template default to its name
+    if (!listName && !templateId) {
         alert('List name is required');
         return;
     }
@@ -545,7 +614,9 @@ function submitNewList() {
     const newListData = {
         name: listName,
         frequency: frequency || null,
-        target_date: targetDate ? new Date(targetDate).toISOString() : null
+        target_date: targetDate ? new Date(targetDate).toISOString() : null,
+        spawn_copy: spawnCopy,
+        template_id: templateId || undefined
     };
     
     // Make API call to create new list
@@ -580,11 +651,14 @@ function submitNewList() {
         addNewListToDOM(newList);
         
         // Initialize localStorage for the new list
-        const emptyList = {
+        const items = newList.items || [];
+        items.forEach(item => {
+            addItemToDOM(newList.id, item.name, item.qty, item.id, item.checked);
+        });
+        setListData(newList.id, {
             list_id: newList.id,
-            items: []
-        };
-        setListData(newList.id, emptyList);
+            items: items.map(item => ({ id: item.id, name: item.name, qty: item.qty }))
+        });
         
         // Store list metadata
         const metadata = {
@@ -594,6 +668,8 @@ function submitNewList() {
         };
         setListMetadata(newList.id, metadata);
         
+        renderSuggestions(newList.suggestions || []);
+        
         console.log('New list added to DOM and localStorage');
     })
     .catch(error => {
@@ -627,6 +703,7 @@ function addNewListToDOM(listData) {
         <div class="list-card expanded">
             <div class="list-header" onclick="toggleList(this)">
                 <input type="hidden" class="list-id" value="${listData.id}">
+                <input type="hidden" class="list-version" value="${listData.version || 1}">
                 <h2 class="list-name">${listData.name}</h2>
                 <div class="list-meta">
                     ${targetDateDisplay ? `<span class="target-date">📅 ${targetDateDisplay}</span>` : ''}
@@ -655,6 +732,12 @@ function addNewListToDOM(listData) {
                 <button class="save-list-btn" onclick="saveListFromButton(this)">
                     💾 Save List
                 </button>
+                <button class="clear-checked-btn" onclick="clearCheckedItems(this)">
+                    🧹 Clear Checked
+                </button>
+                <button class="save-template-btn" onclick="saveListAsTemplate(this)">
+                    📋 Save as Template
+                </button>
             </div>
         </div>
     `;
@@ -679,6 +762,8 @@ function addNewListToDOM(listData) {
             newListCard.scrollIntoView({ behavior: 'smooth', block: 'center' });
         }
     }
+
+    subscribeToList(listData.id);
 }
 
 // Keyboard Navigation
@@ -847,8 +932,89 @@ document.addEventListener('DOMContentLoaded', () => {
     // Initialize existing items first, then load any saved items
     initializeExistingItems();
     loadSavedItems();
+
+    // Follow changes made by other people sharing the lists
+    document.querySelectorAll('.list-card').forEach(card => {
+        subscribeToList(card.querySelector('.list-id').value);
+    });
 });
 
+// Live updates
+const listStreams = {};
+
+function findListCard(listId) {
+    let found = null;
+    document.querySelectorAll('.list-card').forEach(card => {
+        if (card.querySelector('.list-id').value === listId) {
+            found = card;
+        }
+    });
+    return found;
+}
+
+function subscribeToList(listId) {
+    if (!listId || listStreams[listId] || !window.EventSource) return;
+
+    const source = new EventSource(`/api/lists/${listId}/events`);
+    listStreams[listId] = source;
+
+    const onChange = (e) => {
+        const event = JSON.parse(e.data);
+        const card = findListCard(listId);
+        if (!card) return;
+
+        const versionInput = card.querySelector('.list-version');
+        if (event.version <= parseInt(versionInput.value)) return;
+
+        if (event.type === 'list.deleted') {
+            source.close();
+            delete listStreams[listId];
+            clearListData(listId);
+            card.remove();
+            return;
+        }
+
+        // Keep local edits; the next save will report the conflict
+        if (card.classList.contains('unsaved')) {
+            return;
+        }
+
+        refreshListFromServer(listId);
+    };
+
+    ['ready', 'item.added', 'item.updated', 'item.removed', 'list.updated', 'list.deleted'].forEach(type => {
+        source.addEventListener(type, onChange);
+    });
+}
+
+function refreshListFromServer(listId) {
+    fetch(`/api/lists/${listId}`)
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            return response.json();
+        })
+        .then(list => {
+            const card = findListCard(listId);
+            if (!card || card.classList.contains('unsaved')) return;
+
+            card.querySelector('.list-name').textContent = list.name;
+            card.querySelector('.list-version').value = list.version;
+            card.querySelector('.list-items').innerHTML = '';
+            list.items.forEach(item => {
+                addItemToDOM(listId, item.name, item.qty, item.id, item.checked);
+            });
+            setListData(listId, {
+                list_id: listId,
+                items: list.items.map(item => ({ id: item.id, name: item.name, qty: item.qty }))
+            });
+        })
+        .catch(error => {
+            console.error('Failed to refresh list:', error);
+        });
+}
+
 // Fix zero dates that are already in the DOM from server rendering
 function fixZeroDatesInDOM() {
     const targetDateElements = document.querySelectorAll('.target-date');
@@ -924,4 +1090,600 @@ function loadListsFromLocalStorage() {
             });
         }
     });
-}
\ No newline at end of file
+}
+
+// Checking items off
+function listItemRequest(card, url, options) {
+    const listId = card.querySelector('.list-id').value;
+    const versionInput = card.querySelector('.list-version');
+
+    return fetch(url, {
+        ...options,
+        headers: {
+            'Content-Type': 'application/json',
+            'If-Match': `"${versionInput.value}"`
+        }
+    })
+    .then(response => {
+        if (response.status === 412) {
+            card.classList.remove('unsaved');
+            refreshListFromServer(listId);
+            throw new Error('List was modified by someone else');
+        }
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const etag = response.headers.get('ETag');
+        if (etag) {
+            versionInput.value = etag.replace(/"/g, '');
+        }
+        return response.json();
+    });
+}
+
+function toggleItemChecked(checkbox) {
+    const listItem = checkbox.closest('.list-item');
+    const listCard = checkbox.closest('.list-card');
+    const listId = listCard.querySelector('.list-id').value;
+    const itemId = listItem.getAttribute('data-item-id');
+
+    listItemRequest(listCard, `/api/lists/${listId}/items/${itemId}/toggle`, {
+        method: 'POST',
+        body: JSON.stringify({ checked: checkbox.checked })
+    })
+    .then(item => {
+        checkbox.checked = item.checked;
+        listItem.classList.toggle('checked', item.checked);
+    })
+    .catch(error => {
+        console.error('Failed to check item:', error);
+        checkbox.checked = !checkbox.checked;
+    });
+}
+
+function clearCheckedItems(button) {
+    const listCard = button.closest('.list-card');
+    const listId = listCard.querySelector('.list-id').value;
+
+    listItemRequest(listCard, `/api/lists/${listId}/items/checked`, {
+        method: 'DELETE'
+    })
+    .then(removed => {
+        removed.forEach(item => {
+            removeItemFromStorage(listId, item.name, item.id);
+            const listItem = listCard.querySelector(`.list-item[data-item-id="${item.id}"]`);
+            if (listItem) {
+                listItem.remove();
+            }
+        });
+    })
+    .catch(error => {
+        console.error('Failed to clear checked items:', error);
+    });
+}
+
+// Templates
+function loadTemplateOptions() {
+    const select = document.getElementById('listTemplateSelect');
+
+    fetch('/api/templates')
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            return response.json();
+        })
+        .then(templates => {
+            select.querySelectorAll('option:not([value=""])').forEach(option => option.remove());
+            templates.forEach(template => {
+                const option = document.createElement('option');
+                option.value = template.id;
+                option.textContent = `${template.name} (${template.items.length} items)`;
+                select.appendChild(option);
+            });
+        })
+        .catch(error => {
+            console.error('Failed to load templates:', error);
+        });
+}
+
+function onTemplateSelected(select) {
+    document.getElementById('listNameInput').required = !select.value;
+}
+
+function saveListAsTemplate(button) {
+    const listCard = button.closest('.list-card');
+    const listId = listCard.querySelector('.list-id').value;
+    const listName = listCard.querySelector('.list-name').textContent.trim();
+
+    if (listCard.classList.contains('unsaved')) {
+        alert('Save the list first so the template gets its latest items.');
+        return;
+    }
+
+    const name = prompt('Template name:', listName);
+    if (name === null) return;
+
+    fetch('/api/templates', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ name: name.trim(), list_id: listId })
+    })
+    .then(response => {
+        if (response.status === 409) {
+            throw new Error('A template with this name already exists');
+        }
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        return response.json();
+    })
+    .then(template => {
+        button.textContent = '📋 Saved';
+        setTimeout(() => {
+            button.textContent = '📋 Save as Template';
+        }, 2000);
+    })
+    .catch(error => {
+        console.error('Failed to save template:', error);
+        alert(error.message);
+    });
+}
+
+// Restock suggestions
+function renderSuggestions(suggestions) {
+    const section = document.getElementById('suggestions');
+    const chips = document.getElementById('suggestionChips');
+
+    chips.innerHTML = '';
+    suggestions.forEach(suggestion => {
+        const chip = document.createElement('button');
+        chip.className = 'suggestion-chip';
+        chip.textContent = suggestion.name;
+        chip.title = `Usually bought every ${suggestion.interval_days} days`;
+        chip.onclick = () => addSuggestion(chip);
+        chips.appendChild(chip);
+    });
+    section.classList.toggle('hidden', suggestions.length === 0);
+}
+
+function addSuggestion(chip) {
+    // suggestions go to the open list, or the newest one if none is open
+    const listCard = document.querySelector('.list-card.expanded') ||
+        document.querySelector('.lists-container .list-card:last-child');
+    if (!listCard) {
+        alert('Create a list first to add suggestions to it.');
+        return;
+    }
+
+    const listId = listCard.querySelector('.list-id').value;
+    const itemName = chip.textContent.trim();
+
+    addItemToDOM(listId, itemName, 1);
+    saveItemToStorage(listId, itemName, 1);
+    markListAsUnsaved(listId);
+
+    chip.remove();
+    const chips = document.getElementById('suggestionChips');
+    if (!chips.children.length) {
+        document.getElementById('suggestions').classList.add('hidden');
+    }
+}
+
+// Signed-in devices
+function openSessions() {
+    document.getElementById('sessionsModal').classList.add('active');
+    loadSessions();
+}
+
+function closeSessions() {
+    document.getElementById('sessionsModal').classList.remove('active');
+}
+
+function loadSessions() {
+    const sessionList = document.getElementById('sessionList');
+
+    fetch('/api/sessions')
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            return response.json();
+        })
+        .then(sessions => {
+            sessionList.innerHTML = '';
+            sessions.forEach(session => {
+                const item = document.createElement('div');
+                item.className = 'session-item';
+
+                const info = document.createElement('div');
+                const device = document.createElement('div');
+                device.className = 'session-device';
+                device.textContent = session.user_agent || 'Unknown device';
+                const meta = document.createElement('div');
+                meta.className = 'session-meta';
+                meta.textContent = `${session.ip} · last active ${new Date(session.last_seen_at).toLocaleString()}`;
+                info.append(device, meta);
+                item.appendChild(info);
+
+                if (session.current) {
+                    const current = document.createElement('span');
+                    current.className = 'session-current';
+                    current.textContent = 'This device';
+                    item.appendChild(current);
+                } else {
+                    const revoke = document.createElement('button');
+                    revoke.className = 'revoke-session-btn';
+                    revoke.textContent = 'Sign out';
+                    revoke.onclick = () => revokeSession(session.id, item);
+                    item.appendChild(revoke);
+                }
+
+                sessionList.appendChild(item);
+            });
+        })
+        .catch(error => {
+            console.error('Failed to load sessions:', error);
+            sessionList.textContent = 'Failed to load devices.';
+        });
+}
+
+function revokeSession(sessionId, item) {
+    fetch(`/api/sessions/${sessionId}`, { method: 'DELETE' })
+        .then(response => {
+            if (!response.ok && response.status !== 404) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            item.remove();
+        })
+        .catch(error => {
+            console.error('Failed to revoke session:', error);
+            alert('Failed to sign out that device. Please try again.');
+        });
+}
+
+// Two-factor authentication
+function openMfa() {
+    document.getElementById('mfaModal').classList.add('active');
+    loadMfa();
+}
+
+function closeMfa() {
+    document.getElementById('mfaModal').classList.remove('active');
+}
+
+function mfaRequest(url, body) {
+    return fetch(url, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body || {})
+    }).then(response => {
+        if (!response.ok) {
+            return response.json().catch(() => ({})).then(data => {
+                throw new Error(data.error || `HTTP error! status: ${response.status}`);
+            });
+        }
+        return response.status === 204 ? null : response.json();
+    });
+}
+
+function mfaCodeForm(label, buttonText, onSubmit) {
+    const form = document.createElement('form');
+    form.className = 'mfa-form';
+    const input = document.createElement('input');
+    input.className = 'form-input';
+    input.placeholder = label;
+    input.autocomplete = 'one-time-code';
+    input.required = true;
+    const button = document.createElement('button');
+    button.type = 'submit';
+    button.className = 'mfa-btn';
+    button.textContent = buttonText;
+    form.append(input, button);
+    form.onsubmit = event => {
+        event.preventDefault();
+        onSubmit(input.value.trim());
+    };
+    return form;
+}
+
+function loadMfa() {
+    const body = document.getElementById('mfaBody');
+
+    fetch('/api/mfa')
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            return response.json();
+        })
+        .then(status => {
+            body.innerHTML = '';
+            const text = document.createElement('p');
+
+            if (!status.totp_enabled) {
+                text.textContent = 'Protect your account with a code from an authenticator app when signing in.';
+                const start = document.createElement('button');
+                start.className = 'mfa-btn';
+                start.textContent = 'Set up authenticator';
+                start.onclick = enrollTotp;
+                body.append(text, start);
+                return;
+            }
+
+            text.textContent = `Two-factor authentication is on. ${status.recovery_codes_left} recovery codes left.`;
+            body.append(
+                text,
+                mfaCodeForm('Code or recovery code', 'New recovery codes', code => {
+                    mfaRequest('/api/mfa/recovery-codes', { code })
+                        .then(data => showRecoveryCodes(data.recovery_codes))
+                        .catch(error => alert(error.message));
+                }),
+                mfaCodeForm('Code or recovery code', 'Turn off', code => {
+                    mfaRequest('/api/mfa/totp/disable', { code })
+                        .then(loadMfa)
+                        .catch(error => alert(error.message));
+                })
+            );
+        })
+        .catch(error => {
+            console.error('Failed to load two-factor authentication:', error);
+            body.textContent = 'Failed to load two-factor authentication.';
+        });
+}
+
+function enrollTotp() {
+    const body = document.getElementById('mfaBody');
+
+    mfaRequest('/api/mfa/totp')
+        .then(enrollment => {
+            body.innerHTML = '';
+            const text = document.createElement('p');
+            text.textContent = 'Scan this code with your authenticator app, or enter the key by hand, then type the code it shows.';
+            const qr = document.createElement('img');
+            qr.className = 'mfa-qr';
+            qr.src = enrollment.qr_png;
+            qr.alt = enrollment.otpauth_uri;
+            const secret = document.createElement('code');
+            secret.className = 'mfa-secret';
+            secret.textContent = enrollment.secret;
+            body.append(text, qr, secret, mfaCodeForm('123456', 'Turn on', code => {
+                mfaRequest('/api/mfa/totp/confirm', { code })
+                    .then(data => showRecoveryCodes(data.recovery_codes))
+                    .catch(error => alert(error.message));
+            }));
+        })
+        .catch(error => alert(error.message));
+}
+
+function showRecoveryCodes(codes) {
+    const body = document.getElementById('mfaBody');
+    body.innerHTML = '';
+
+    const text = document.createElement('p');
+    text.textContent = 'Keep these recovery codes somewhere safe. Each one signs you in once if you lose your authenticator. They won\'t be shown again.';
+    const list = document.createElement('pre');
+    list.className = 'mfa-codes';
+    list.textContent = codes.join('\n');
+    const done = document.createElement('button');
+    done.className = 'mfa-btn';
+    done.textContent = 'Done';
+    done.onclick = loadMfa;
+    body.append(text, list, done);
+}
+
+// Account: profile, email address and password
+function openAccount() {
+    document.getElementById('accountModal').classList.add('active');
+    loadAccount();
+}
+
+function closeAccount() {
+    document.getElementById('accountModal').classList.remove('active');
+}
+
+function loadAccount() {
+    fetch('/api/account')
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            return response.json();
+        })
+        .then(showAccount)
+        .catch(error => {
+            console.error('Failed to load account:', error);
+            document.getElementById('emailNote').textContent = 'Failed to load account.';
+        });
+}
+
+function showAccount(account) {
+    document.getElementById('firstNameInput').value = account.first_name;
+    document.getElementById('lastNameInput').value = account.last_name;
+    let note = `Signed in as ${account.email}${account.email_verified ? '' : ' (not confirmed)'}.`;
+    if (account.pending_email) {
+        note += ` Waiting for ${account.pending_email} to be confirmed.`;
+    }
+    document.getElementById('emailNote').textContent = note;
+}
+
+function sendAccountForm(url, method, body) {
+    return fetch(url, {
+        method: method,
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+    }).then(response => response.json().catch(() => ({})).then(data => {
+        if (!response.ok) {
+            throw new Error(data.error || `HTTP error! status: ${response.status}`);
+        }
+        return data;
+    }));
+}
+
+document.getElementById('profileForm').addEventListener('submit', event => {
+    event.preventDefault();
+    sendAccountForm('/api/account', 'PATCH', {
+        first_name: document.getElementById('firstNameInput').value,
+        last_name: document.getElementById('lastNameInput').value
+    })
+        .then(account => {
+            showAccount(account);
+            alert('Your name was saved.');
+        })
+        .catch(error => alert(error.message));
+});
+
+document.getElementById('emailForm').addEventListener('submit', event => {
+    event.preventDefault();
+    const emailInput = document.getElementById('newEmailInput');
+    const passwordInput = document.getElementById('emailPasswordInput');
+    sendAccountForm('/api/account/email', 'POST', {
+        email: emailInput.value.trim(),
+        password: passwordInput.value
+    })
+        .then(data => {
+            emailInput.value = '';
+            passwordInput.value = '';
+            alert(`We sent a link to ${data.pending_email}. Your address changes once you open it.`);
+            loadAccount();
+        })
+        .catch(error => alert(error.message));
+});
+
+document.getElementById('passwordForm').addEventListener('submit', event => {
+    event.preventDefault();
+    const currentInput = document.getElementById('currentPasswordInput');
+    const newInput = document.getElementById('newPasswordInput');
+    sendAccountForm('/api/account/password', 'POST', {
+        current_password: currentInput.value,
+        new_password: newInput.value
+    })
+        .then(() => {
+            currentInput.value = '';
+            newInput.value = '';
+            alert('Your password was changed. Your other devices were signed out.');
+        })
+        .catch(error => alert(error.message));
+});
+
+document.getElementById('deleteAccountForm').addEventListener('submit', event => {
+    event.preventDefault();
+    if (!confirm('Delete your account? You will be signed out everywhere.')) {
+        return;
+    }
+    sendAccountForm('/api/account', 'DELETE', {
+        password: document.getElementById('deletePasswordInput').value
+    })
+        .then(data => {
+            alert(`Your account will be deleted on ${new Date(data.delete_after).toLocaleDateString()}. We emailed you a link to restore it until then.`);
+            window.location.href = '/login.html';
+        })
+        .catch(error => alert(error.message));
+});
+
+// Personal access tokens
+function openTokens() {
+    document.getElementById('tokensModal').classList.add('active');
+    document.getElementById('tokenCreated').classList.add('hidden');
+    loadTokens();
+}
+
+function closeTokens() {
+    document.getElementById('tokensModal').classList.remove('active');
+}
+
+function loadTokens() {
+    const tokenList = document.getElementById('tokenList');
+
+    fetch('/api/tokens')
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            return response.json();
+        })
+        .then(tokens => {
+            tokenList.innerHTML = '';
+            if (!tokens.length) {
+                tokenList.textContent = 'No access tokens yet.';
+                return;
+            }
+            tokens.forEach(token => {
+                const item = document.createElement('div');
+                item.className = 'session-item';
+
+                const info = document.createElement('div');
+                const name = document.createElement('div');
+                name.className = 'session-device';
+                name.textContent = `${token.name} (${token.scope})`;
+                const meta = document.createElement('div');
+                meta.className = 'session-meta';
+                const lastUsed = token.last_used_at ? new Date(token.last_used_at).toLocaleString() : 'never';
+                meta.textContent = `expires ${new Date(token.expires_at).toLocaleDateString()} · last used ${lastUsed}`;
+                info.append(name, meta);
+
+                const revoke = document.createElement('button');
+                revoke.className = 'revoke-session-btn';
+                revoke.textContent = 'Revoke';
+                revoke.onclick = () => revokeToken(token.id, item);
+                item.append(info, revoke);
+
+                tokenList.appendChild(item);
+            });
+        })
+        .catch(error => {
+            console.error('Failed to load access tokens:', error);
+            tokenList.textContent = 'Failed to load access tokens.';
+        });
+}
+
+document.getElementById('tokenForm').addEventListener('submit', event => {
+    event.preventDefault();
+    const nameInput = document.getElementById('tokenNameInput');
+
+    fetch('/api/tokens', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            name: nameInput.value.trim(),
+            scope: document.getElementById('tokenScopeSelect').value,
+            expires_in_days: parseInt(document.getElementById('tokenExpirySelect').value, 10)
+        })
+    })
+        .then(response => response.json().catch(() => ({})).then(data => {
+            if (!response.ok) {
+                throw new Error(data.error || `HTTP error! status: ${response.status}`);
+            }
+            return data;
+        }))
+        .then(token => {
+            nameInput.value = '';
+            const created = document.getElementById('tokenCreated');
+            created.innerHTML = '';
+            const text = document.createElement('p');
+            text.textContent = `Copy the token for "${token.name}" now, it won't be shown again:`;
+            const value = document.createElement('code');
+            value.className = 'mfa-secret';
+            value.textContent = token.token;
+            created.append(text, value);
+            created.classList.remove('hidden');
+            loadTokens();
+        })
+        .catch(error => alert(error.message));
+});
+
+function revokeToken(tokenId, item) {
+    fetch(`/api/tokens/${tokenId}`, { method: 'DELETE' })
+        .then(response => {
+            if (!response.ok && response.status !== 404) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            item.remove();
+        })
+        .catch(error => {
+            console.error('Failed to revoke access token:', error);
+            alert('Failed to revoke that token. Please try again.');
+        });
+}
@@ -0,0 +1,25 @@
+document.addEventListener('DOMContentLoaded', () => {
+  const params = new URLSearchParams(window.location.search);
+  const token = params.get('token');
+  const submit = document.getElementById('submit-btn');
+
+  if (!token) {
+    alert('This reset link is incomplete. Please request a new one.');
+    window.location.replace('/forgot-password.html');
+    return;
+  }
+
+  document.getElementById('token').value = token;
+  // keep the token out of the history and of referrers
+  history.replaceState(null, '', window.location.pathname);
+
+  const pwdInput = document.getElementById('password');
+  const update = () => {
+    const ok = (pwdInput.value || '').length >= 8;
+    submit.disabled = !ok;
+    submit.style.backgroundColor = ok ? '#4dabf7' : 'grey';
+  };
+
+  update();
+  pwdInput.addEventListener('input', update);
+});